@@ -0,0 +1,65 @@
+package sensitivity_labels
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// LabelStreamName is the NTFS alternate data stream mirroring the labels of
+// a document, e.g. file.docx:mip.json, readable without unzipping the document
+const LabelStreamName = "mip.json"
+
+// ErrStreamsUnsupported is returned outside of Windows, where files have no alternate data streams
+var ErrStreamsUnsupported = errors.New("alternate data streams are only supported on Windows (NTFS)")
+
+// LabelStream is the content of the label stream
+type LabelStream struct {
+	Updated string             `json:"updated"`
+	RunId   string             `json:"runId,omitempty"`
+	Labels  []LabelStreamEntry `json:"labels"`
+}
+
+type LabelStreamEntry struct {
+	Id         string `json:"id"`
+	SiteId     string `json:"siteId"`
+	Name       string `json:"name,omitempty"`
+	TenantName string `json:"tenantName,omitempty"`
+}
+
+// WriteLabelStream replaces the label stream of filePath with labels,
+// names are taken from Label.Name and Label.TenantName when resolved
+func WriteLabelStream(filePath string, labels []Label, runId string) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	stream := LabelStream{
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		RunId:   runId,
+		Labels:  []LabelStreamEntry{},
+	}
+	for _, label := range labels {
+		stream.Labels = append(stream.Labels, LabelStreamEntry{
+			Id:         NormalizeId(label.Id),
+			SiteId:     NormalizeId(label.SiteId),
+			Name:       label.Name,
+			TenantName: label.TenantName,
+		})
+	}
+	data, err := json.Marshal(stream)
+	if err != nil {
+		return err
+	}
+	return writeStream(filePath, LabelStreamName, data)
+}
+
+// ReadLabelStream reads the label stream written by WriteLabelStream
+func ReadLabelStream(filePath string) (LabelStream, error) {
+	var stream LabelStream
+	data, err := readStream(filePath, LabelStreamName)
+	if err != nil {
+		return stream, err
+	}
+	err = json.Unmarshal(data, &stream)
+	return stream, err
+}
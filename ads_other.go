@@ -0,0 +1,11 @@
+//go:build !windows
+
+package sensitivity_labels
+
+func writeStream(filePath, name string, data []byte) error {
+	return ErrStreamsUnsupported
+}
+
+func readStream(filePath, name string) ([]byte, error) {
+	return nil, ErrStreamsUnsupported
+}
@@ -0,0 +1,11 @@
+package sensitivity_labels
+
+import "os"
+
+func writeStream(filePath, name string, data []byte) error {
+	return os.WriteFile(LongPath(filePath)+":"+name, data, 0644)
+}
+
+func readStream(filePath, name string) ([]byte, error) {
+	return os.ReadFile(LongPath(filePath) + ":" + name)
+}
@@ -0,0 +1,50 @@
+package sensitivity_labels
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replaces filePath with data through a temporary file in the same
+// directory that is synced before it is renamed over filePath, so a crash or a kill
+// during the write leaves the original document or the new one, never a truncated one
+func writeFileAtomic(filePath string, data []byte) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(LongPath(filePath)); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(LongPath(filepath.Dir(filePath)), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpPath)
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, LongPath(filePath)); err != nil {
+		return err
+	}
+	renamed = true
+	return nil
+}
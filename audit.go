@@ -0,0 +1,217 @@
+package sensitivity_labels
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AuditRecord is a single JSONL entry describing a label modification
+type AuditRecord struct {
+	Timestamp      string     `json:"timestamp"`
+	Operator       string     `json:"operator"`
+	Host           string     `json:"host"`
+	Command        string     `json:"command"`
+	FilePath       string     `json:"filePath"`
+	LabelsBefore   []Label    `json:"labelsBefore"`
+	LabelsAfter    []Label    `json:"labelsAfter"`
+	BackupPath     string     `json:"backupPath,omitempty"`
+	QuarantinePath string     `json:"quarantinePath,omitempty"` // where quarantine moved or copied FilePath
+	HashBefore     string     `json:"hashBefore,omitempty"`     // sha256 of the file before and after the write
+	HashAfter      string     `json:"hashAfter,omitempty"`
+	PartChanges    []PartHash `json:"partChanges,omitempty"` // evidence that only the label part changed
+	DryRun         bool       `json:"dryRun"`
+	Result         string     `json:"result"`
+	Error          string     `json:"error,omitempty"`
+}
+
+func NewAuditRecord(command, filePath string, before, after []Label) AuditRecord {
+	operator := ""
+	if u, err := user.Current(); err == nil {
+		operator = u.Username
+	}
+	host, _ := os.Hostname()
+	return AuditRecord{
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Operator:     operator,
+		Host:         host,
+		Command:      command,
+		FilePath:     filePath,
+		LabelsBefore: before,
+		LabelsAfter:  after,
+	}
+}
+
+// AppendAuditRecord appends the record as a single line to the audit file,
+// the file is only ever opened in append mode
+func AppendAuditRecord(auditPath string, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := checkWrite(auditPath); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(LongPath(auditPath), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// AuditRotation rotates an audit log by size and age, zero values disable each limit
+type AuditRotation struct {
+	MaxSize   int64         // rotate once the log reaches this many bytes
+	MaxAge    time.Duration // rotate once the first record of the log is this old
+	Compress  bool          // gzip rotated logs
+	Retention time.Duration // remove rotated logs whose last record is older than this
+}
+
+// auditTimeFormat stamps rotated logs, audit.jsonl becomes audit-20240102T150405Z.jsonl(.gz)
+const auditTimeFormat = "20060102T150405Z"
+
+// RotateAuditLog renames auditPath aside when it reached MaxSize or MaxAge, so the
+// next record starts a new log, and prunes rotated logs past Retention, it returns
+// the path of the rotated log or "" when the log was not due
+func RotateAuditLog(auditPath string, r AuditRotation) (string, error) {
+	info, err := os.Stat(LongPath(auditPath))
+	if err != nil || info.Size() == 0 {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return "", err
+	}
+	due := r.MaxSize > 0 && info.Size() >= r.MaxSize
+	if !due && r.MaxAge > 0 {
+		first, err := firstAuditTime(auditPath)
+		due = err == nil && time.Since(first) >= r.MaxAge
+	}
+	if !due {
+		return "", nil
+	}
+	if err := checkWrite(auditPath); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(auditPath)
+	base := strings.TrimSuffix(auditPath, ext)
+	stamp := time.Now().UTC().Format(auditTimeFormat)
+	exists := func(path string) bool {
+		_, err := os.Lstat(LongPath(path))
+		return err == nil
+	}
+	rotated := base + "-" + stamp + ext
+	for n := 2; exists(rotated) || exists(rotated+".gz"); n++ {
+		rotated = fmt.Sprintf("%s-%s-%d%s", base, stamp, n, ext)
+	}
+	if err := os.Rename(LongPath(auditPath), LongPath(rotated)); err != nil {
+		return "", err
+	}
+	if r.Compress {
+		if err := gzipFile(rotated, info.ModTime()); err != nil {
+			return rotated, fmt.Errorf("unable to compress %s: %w", rotated, err)
+		}
+		rotated += ".gz"
+	}
+	_, err = PruneAuditLogs(auditPath, r.Retention)
+	return rotated, err
+}
+
+// PruneAuditLogs removes logs rotated from auditPath whose last record is older than
+// retention, zero keeps every log, it returns the paths removed
+func PruneAuditLogs(auditPath string, retention time.Duration) ([]string, error) {
+	if retention <= 0 {
+		return nil, nil
+	}
+	ext := filepath.Ext(auditPath)
+	prefix := strings.TrimSuffix(filepath.Base(auditPath), ext) + "-"
+	dir := filepath.Dir(auditPath)
+	entries, err := os.ReadDir(LongPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if len(stamp) < len(auditTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(auditTimeFormat, stamp[:len(auditTimeFormat)]); err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if err := os.Remove(LongPath(path)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// firstAuditTime reads the timestamp of the first record of an audit log
+func firstAuditTime(auditPath string) (time.Time, error) {
+	f, err := os.Open(LongPath(auditPath))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return time.Time{}, err
+	}
+	var record AuditRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, record.Timestamp)
+}
+
+// gzipFile replaces path with path.gz, keeping the modified time retention is based on
+func gzipFile(path string, modTime time.Time) error {
+	src, err := os.Open(LongPath(path))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(LongPath(path+".gz"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = modTime
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(LongPath(path + ".gz"))
+		return err
+	}
+	src.Close()
+	if err := os.Chtimes(LongPath(path+".gz"), modTime, modTime); err != nil {
+		return err
+	}
+	return os.Remove(LongPath(path))
+}
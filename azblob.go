@@ -0,0 +1,140 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AzureBlobStorage is a Storage of an Azure Blob Storage container, requests are
+// authorized with a shared access signature (SAS) token
+type AzureBlobStorage struct {
+	Account   string
+	Container string
+	Endpoint  string // default https://<account>.blob.core.windows.net
+	SAS       string // query string of the token, e.g. sv=...&sig=...
+	Client    *http.Client
+}
+
+// NewAzureBlobStorage returns the storage of a container with the SAS token of
+// AZURE_STORAGE_SAS_TOKEN and the endpoint of AZURE_STORAGE_BLOB_ENDPOINT
+func NewAzureBlobStorage(account, container string) *AzureBlobStorage {
+	endpoint := os.Getenv("AZURE_STORAGE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	return &AzureBlobStorage{
+		Account:   account,
+		Container: container,
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		SAS:       strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		Client:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// openAzureBlob opens azblob://account/container/prefix
+func openAzureBlob(u *url.URL) (Storage, string, error) {
+	container, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || container == "" {
+		return nil, "", fmt.Errorf("%s: expected azblob://account/container/prefix", u)
+	}
+	return NewAzureBlobStorage(u.Host, container), prefix, nil
+}
+
+type azureListResult struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string
+			Properties struct {
+				Size         int64  `xml:"Content-Length"`
+				LastModified string `xml:"Last-Modified"`
+			}
+		}
+	}
+	NextMarker string
+}
+
+func (a *AzureBlobStorage) List(prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+	for {
+		resp, err := a.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result azureListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list azblob://%s/%s/%s: %w", a.Account, a.Container, prefix, err)
+		}
+		for _, b := range result.Blobs.Blob {
+			modified, _ := http.ParseTime(b.Properties.LastModified)
+			objects = append(objects, StorageObject{Name: b.Name, Size: b.Properties.Size, Modified: modified})
+		}
+		if result.NextMarker == "" {
+			return objects, nil
+		}
+		query.Set("marker", result.NextMarker)
+	}
+}
+
+func (a *AzureBlobStorage) Open(name string) (io.ReadCloser, error) {
+	resp, err := a.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (a *AzureBlobStorage) Write(name string, data []byte) error {
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	resp, err := a.do(http.MethodPut, name, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (a *AzureBlobStorage) Stat(name string) (StorageObject, error) {
+	resp, err := a.do(http.MethodHead, name, nil, nil)
+	if err != nil {
+		return StorageObject{}, err
+	}
+	resp.Body.Close()
+	return objectInfo(name, resp), nil
+}
+
+func (a *AzureBlobStorage) do(method, name string, query url.Values, body []byte) (*http.Response, error) {
+	path := "/" + s3Escape(a.Container, false)
+	if name != "" {
+		path += "/" + s3Escape(name, false)
+	}
+	rawQuery := query.Encode()
+	if a.SAS != "" {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += a.SAS
+	}
+	req, err := http.NewRequest(method, a.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = rawQuery
+	req.ContentLength = int64(len(body))
+	req.Header.Set("x-ms-version", "2021-08-06")
+	if method == http.MethodPut {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+	}
+	return storageDo(a.Client, req, "azblob://"+a.Account+"/"+a.Container+"/"+name)
+}
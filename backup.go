@@ -0,0 +1,89 @@
+package sensitivity_labels
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NewRunId returns a sortable identifier for a single invocation, e.g. 20240102T150405Z-1a2b3c4d
+func NewRunId() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// BackupFile copies filePath into backupDir/runId, preserving the file's path
+// below the backup root so files with the same name in different directories don't collide
+func BackupFile(filePath, backupDir, runId string) (string, error) {
+	relPath := filepath.Clean(filePath)
+	relPath = strings.TrimPrefix(relPath, filepath.VolumeName(relPath))
+	relPath = strings.TrimLeft(relPath, `/\`)
+	// keep parent directory references inside the backup root
+	for strings.HasPrefix(relPath, ".."+string(os.PathSeparator)) {
+		relPath = relPath[3:]
+	}
+	backupPath := filepath.Join(backupDir, runId, relPath)
+	if err := checkWrite(backupPath); err != nil {
+		return "", err
+	}
+
+	err := os.MkdirAll(LongPath(filepath.Dir(backupPath)), 0755)
+	if err != nil {
+		return "", err
+	}
+	in, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	out, err := os.OpenFile(LongPath(backupPath), os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return backupPath, os.Chtimes(LongPath(backupPath), info.ModTime(), info.ModTime())
+}
+
+// RestoreBackup copies a backup made by BackupFile over filePath,
+// restoring its content and modification time
+func RestoreBackup(backupPath, filePath string) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	in, err := os.Open(LongPath(backupPath))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(LongPath(filePath), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(LongPath(filePath), info.ModTime(), info.ModTime())
+}
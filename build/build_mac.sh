@@ -1,6 +1,9 @@
 #!/bin/bash
 outFile="./bin/labels"
-entryFile="./cmd/labels/main.go"
+entryDir="./cmd/labels"
+version=$(git describe --tags --always --dirty)
+commit=$(git rev-parse HEAD)
+buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)
 GOOS=darwin
 GOARCH=amd64
-go build -o $outFile $entryFile
\ No newline at end of file
+go build -ldflags "-X main.version=$version -X main.commit=$commit -X main.buildDate=$buildDate" -o $outFile $entryDir
\ No newline at end of file
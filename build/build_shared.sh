@@ -0,0 +1,4 @@
+#!/bin/bash
+outFile="./bin/libsensitivitylabels.so"
+entryDir="./cmd/libsensitivitylabels"
+CGO_ENABLED=1 go build -buildmode=c-shared -o $outFile $entryDir
\ No newline at end of file
@@ -0,0 +1,6 @@
+$env:outFile="./bin/sensitivitylabels.dll"
+$env:entryDir="./cmd/libsensitivitylabels"
+$env:CGO_ENABLED="1"
+$env:GOOS="windows"
+$env:GOARCH="amd64"
+go build -buildmode=c-shared -o $env:outFile $env:entryDir
\ No newline at end of file
@@ -1,5 +1,8 @@
 $env:outFile="./bin/labels.exe"
-$env:entryFile="./cmd/labels/main.go"
+$env:entryDir="./cmd/labels"
+$version=git describe --tags --always --dirty
+$commit=git rev-parse HEAD
+$buildDate=(Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
 $env:GOOS="windows"
 $env:GOARCH="amd64"
-go build -o $env:outFile $env:entryFile
\ No newline at end of file
+go build -ldflags "-X main.version=$version -X main.commit=$commit -X main.buildDate=$buildDate" -o $env:outFile $env:entryDir
\ No newline at end of file
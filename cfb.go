@@ -0,0 +1,230 @@
+package sensitivity_labels
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// read only reader of OLE compound files (MS-CFB), the container of .msg files
+
+var errCFB = errors.New("malformed compound file")
+
+// special sector numbers
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbNoStream   = 0xFFFFFFFF
+)
+
+// directory entry types
+const (
+	cfbStorage = 1
+	cfbStream  = 2
+	cfbRoot    = 5
+)
+
+type cfbEntry struct {
+	name               string
+	typ                byte
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+type cfbFile struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+	miniFat    []uint32
+	miniStream []byte
+	cutoff     uint64
+	entries    []cfbEntry
+}
+
+func openCFB(data []byte) (*cfbFile, error) {
+	if len(data) < 512 || string(data[:8]) != string(cfbMagic) {
+		return nil, errCFB
+	}
+	shift := binary.LittleEndian.Uint16(data[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, errCFB
+	}
+	c := &cfbFile{data: data, sectorSize: 1 << shift, cutoff: uint64(binary.LittleEndian.Uint32(data[0x38:]))}
+
+	// the FAT sectors are listed in the header and a chain of DIFAT sectors
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		s := binary.LittleEndian.Uint32(data[0x4C+4*i:])
+		if s == cfbNoStream {
+			break
+		}
+		fatSectors = append(fatSectors, s)
+	}
+	difat := binary.LittleEndian.Uint32(data[0x44:])
+	for n := 0; difat != cfbEndOfChain && difat != cfbNoStream; n++ {
+		sector, err := c.sector(difat)
+		if err != nil || n > len(data)/c.sectorSize {
+			return nil, errCFB
+		}
+		last := c.sectorSize/4 - 1
+		for i := 0; i < last; i++ {
+			if s := binary.LittleEndian.Uint32(sector[4*i:]); s != cfbNoStream {
+				fatSectors = append(fatSectors, s)
+			}
+		}
+		difat = binary.LittleEndian.Uint32(sector[4*last:])
+	}
+	for _, s := range fatSectors {
+		sector, err := c.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		c.fat = append(c.fat, uint32s(sector)...)
+	}
+
+	dir, err := c.chain(binary.LittleEndian.Uint32(data[0x30:]), 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+128 <= len(dir); i += 128 {
+		c.entries = append(c.entries, parseCFBEntry(dir[i:i+128]))
+	}
+	if len(c.entries) == 0 || c.entries[0].typ != cfbRoot {
+		return nil, errCFB
+	}
+	if miniFat := binary.LittleEndian.Uint32(data[0x3C:]); miniFat != cfbEndOfChain {
+		data, err := c.chain(miniFat, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.miniFat = uint32s(data)
+	}
+	root := c.entries[0]
+	if root.start != cfbEndOfChain {
+		if c.miniStream, err = c.chain(root.start, root.size); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func parseCFBEntry(b []byte) cfbEntry {
+	nameLen := int(binary.LittleEndian.Uint16(b[0x40:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+	return cfbEntry{
+		name:  utf16String(b[:nameLen]),
+		typ:   b[0x42],
+		left:  binary.LittleEndian.Uint32(b[0x44:]),
+		right: binary.LittleEndian.Uint32(b[0x48:]),
+		child: binary.LittleEndian.Uint32(b[0x4C:]),
+		start: binary.LittleEndian.Uint32(b[0x74:]),
+		size:  binary.LittleEndian.Uint64(b[0x78:]),
+	}
+}
+
+func (c *cfbFile) sector(s uint32) ([]byte, error) {
+	offset := (int64(s) + 1) * int64(c.sectorSize)
+	if offset+int64(c.sectorSize) > int64(len(c.data)) {
+		return nil, errCFB
+	}
+	return c.data[offset : offset+int64(c.sectorSize)], nil
+}
+
+// chain reads a chain of sectors, size 0 reads the whole chain
+func (c *cfbFile) chain(start uint32, size uint64) ([]byte, error) {
+	var out []byte
+	for s, n := start, 0; s != cfbEndOfChain; n++ {
+		if int(s) >= len(c.fat) || n > len(c.fat) {
+			return nil, errCFB
+		}
+		sector, err := c.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sector...)
+		s = c.fat[s]
+	}
+	if size > 0 {
+		if size > uint64(len(out)) {
+			return nil, errCFB
+		}
+		out = out[:size]
+	}
+	return out, nil
+}
+
+// read returns the contents of a stream, small streams are stored in the mini stream
+func (c *cfbFile) read(e cfbEntry) ([]byte, error) {
+	if e.size == 0 {
+		return nil, nil
+	}
+	if e.size >= c.cutoff {
+		return c.chain(e.start, e.size)
+	}
+	var out []byte
+	for s, n := e.start, 0; s != cfbEndOfChain && uint64(len(out)) < e.size; n++ {
+		offset := int(s) * 64
+		if int(s) >= len(c.miniFat) || n > len(c.miniFat) || offset+64 > len(c.miniStream) {
+			return nil, errCFB
+		}
+		out = append(out, c.miniStream[offset:offset+64]...)
+		s = c.miniFat[s]
+	}
+	if e.size > uint64(len(out)) {
+		return nil, errCFB
+	}
+	return out[:e.size], nil
+}
+
+// children returns the entries of a storage, walking the sibling tree of its child
+func (c *cfbFile) children(e cfbEntry) []cfbEntry {
+	var out []cfbEntry
+	seen := map[uint32]bool{}
+	var walk func(i uint32)
+	walk = func(i uint32) {
+		if i == cfbNoStream || int(i) >= len(c.entries) || seen[i] {
+			return
+		}
+		seen[i] = true
+		walk(c.entries[i].left)
+		out = append(out, c.entries[i])
+		walk(c.entries[i].right)
+	}
+	walk(e.child)
+	return out
+}
+
+// stream returns the contents of the named stream of a storage, names ignore case
+func (c *cfbFile) stream(storage cfbEntry, name string) ([]byte, bool) {
+	for _, e := range c.children(storage) {
+		if e.typ == cfbStream && strings.EqualFold(e.name, name) {
+			data, err := c.read(e)
+			return data, err == nil
+		}
+	}
+	return nil, false
+}
+
+func uint32s(b []byte) []uint32 {
+	out := make([]uint32, len(b)/4)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return out
+}
+
+// utf16String decodes little endian UTF-16 up to the first NUL
+func utf16String(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		v := binary.LittleEndian.Uint16(b[i:])
+		if v == 0 {
+			break
+		}
+		u = append(u, v)
+	}
+	return string(utf16.Decode(u))
+}
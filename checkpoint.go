@@ -0,0 +1,82 @@
+package sensitivity_labels
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// CheckpointVersion is bumped whenever the checkpoint format changes incompatibly
+const CheckpointVersion = 1
+
+// Checkpoint is the persisted position of a long scan: the results of every file
+// completed so far keyed by path, nested results (attachments, embedded documents)
+// follow the result of their file
+type Checkpoint struct {
+	Version int                 `json:"version"`
+	RunId   string              `json:"runId"`
+	Command string              `json:"command"`
+	Roots   []string            `json:"roots"`
+	Created string              `json:"created"`
+	Updated string              `json:"updated"`
+	Files   map[string][]Result `json:"files"`
+}
+
+func NewCheckpoint(command string, roots []string, runId string) *Checkpoint {
+	return &Checkpoint{
+		Version: CheckpointVersion,
+		RunId:   runId,
+		Command: command,
+		Roots:   roots,
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Files:   map[string][]Result{},
+	}
+}
+
+// LoadCheckpoint reads a checkpoint written by Write, a missing file returns an
+// error satisfying errors.Is(err, fs.ErrNotExist)
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Files == nil {
+		c.Files = map[string][]Result{}
+	}
+	return &c, nil
+}
+
+// Add records the results of a completed file
+func (c *Checkpoint) Add(filePath string, results []Result) {
+	c.Files[filePath] = results
+}
+
+// Write replaces the checkpoint file through a temporary file, so a crash while
+// writing leaves the previous checkpoint intact
+func (c *Checkpoint) Write(path string) error {
+	c.Updated = time.Now().UTC().Format(time.RFC3339)
+	jsonBytes, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := checkWrite(path); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(LongPath(tmp), jsonBytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(LongPath(tmp), LongPath(path))
+}
+
+// RemoveCheckpoint deletes the checkpoint of a completed scan
+func RemoveCheckpoint(path string) error {
+	if err := checkWrite(path); err != nil {
+		return err
+	}
+	return os.Remove(LongPath(path))
+}
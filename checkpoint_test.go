@@ -0,0 +1,37 @@
+package sensitivity_labels
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	c := NewCheckpoint("get", []string{"dir"}, "run-1")
+	c.Add("dir/report.docx", []Result{{FilePath: "dir/report.docx"}})
+	if err := c.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	SetReadOnlyMode(true)
+	defer SetReadOnlyMode(false)
+	if err := c.Write(path); !errors.Is(err, ErrWriteDenied) {
+		t.Fatalf("Write() in read-only mode = %v, want ErrWriteDenied", err)
+	}
+	if err := RemoveCheckpoint(path); !errors.Is(err, ErrWriteDenied) {
+		t.Fatalf("RemoveCheckpoint() in read-only mode = %v, want ErrWriteDenied", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("checkpoint removed in read-only mode: %v", err)
+	}
+
+	SetReadOnlyMode(false)
+	if err := RemoveCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("checkpoint not removed: %v", err)
+	}
+}
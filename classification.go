@@ -0,0 +1,117 @@
+package sensitivity_labels
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ClassificationMarker is metadata written by a classification tool other
+// than the MIP LabelInfo.xml, e.g. Titus or Boldon James custom properties
+type ClassificationMarker struct {
+	Source string // Titus, Boldon James, Janusseal or MSIP
+	Part   string
+	Name   string
+	Value  string `json:",omitempty"`
+}
+
+// substrings identifying the tool that wrote a property name or customXml namespace
+var classificationSources = []struct {
+	match  string
+	source string
+}{
+	{"msip_label_", "MSIP"},
+	{"titus", "Titus"},
+	{"boldonjames", "Boldon James"},
+	{"bjdocumentlabel", "Boldon James"},
+	{"bjdocumentsecuritylabel", "Boldon James"},
+	{"bjsaver", "Boldon James"},
+	{"bjclsuser", "Boldon James"},
+	{"janusseal", "Janusseal"},
+	{"janus", "Janusseal"},
+}
+
+func classificationSource(s string) string {
+	s = strings.ToLower(s)
+	for _, cs := range classificationSources {
+		if strings.Contains(s, cs.match) {
+			return cs.source
+		}
+	}
+	return ""
+}
+
+type customProperties struct {
+	Properties []struct {
+		Name  string `xml:"name,attr"`
+		Value struct {
+			Text string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"property"`
+}
+
+// FindClassificationMarkers lists custom properties and customXml parts
+// written by known classification tools in an extracted document
+func FindClassificationMarkers(unzipDir string) ([]ClassificationMarker, error) {
+	var markers []ClassificationMarker
+	var custom customProperties
+	if err := readXmlPart(unzipDir+"/docProps/custom.xml", &custom); err != nil {
+		return nil, err
+	}
+	for _, p := range custom.Properties {
+		if source := classificationSource(p.Name); source != "" {
+			markers = append(markers, ClassificationMarker{
+				Source: source,
+				Part:   "docProps/custom.xml",
+				Name:   p.Name,
+				Value:  p.Value.Text,
+			})
+		}
+	}
+
+	items, err := filepath.Glob(filepath.Join(LongPath(unzipDir), "customXml", "item*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(items)
+	for _, item := range items {
+		root, err := rootElement(item)
+		if err != nil {
+			return nil, err
+		}
+		if source := classificationSource(root.Space + " " + root.Local); source != "" {
+			markers = append(markers, ClassificationMarker{
+				Source: source,
+				Part:   "customXml/" + filepath.Base(item),
+				Name:   root.Local,
+				Value:  root.Space,
+			})
+		}
+	}
+	return markers, nil
+}
+
+// rootElement returns the name of the first element of an xml file
+func rootElement(path string) (xml.Name, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return xml.Name{}, err
+	}
+	defer f.Close()
+	d := xml.NewDecoder(f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return xml.Name{}, nil
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
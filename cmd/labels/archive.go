@@ -0,0 +1,67 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// archiveResults reads the labels of documents in a tar or tar.gz archive, reported
+// as backup.tar.gz!/dir/report.docx, entries are streamed one at a time and only
+// those matching --extensions are read, each within --max-extract-bytes
+func archiveResults(fl sl.Result, update updateFunc) []sl.Result {
+	if fl.Handler != "tar" || fl.Error != "" {
+		return nil
+	}
+	extensions := strings.Split(strings.TrimSpace(extensionsCsv), ",")
+	var results []sl.Result
+	err := sl.WalkTar(fl.FilePath, func(hdr *tar.Header, r io.Reader) error {
+		name := sl.TarEntryName(hdr.Name)
+		if !hasExtension(name, extensions) {
+			return nil
+		}
+		if unzipOpts.MaxBytes > 0 && hdr.Size > unzipOpts.MaxBytes {
+			err := fmt.Errorf("%w: entry of %d bytes exceeds --max-extract-bytes", sl.ErrUnsafeArchive, hdr.Size)
+			logger.Error("file error", "file", fl.FilePath+"!/"+name, "category", errExtract, "error", err)
+			if failFast {
+				exitError(err)
+			}
+			results = append(results, sl.Result{
+				FilePath:      fl.FilePath + "!/" + name,
+				Parent:        fl.FilePath,
+				Labels:        []sl.Label{},
+				Error:         err.Error(),
+				ErrorCategory: errExtract,
+			})
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if result, ok := nestedResult(fl, name, data, update); ok {
+			result.Modified = hdr.ModTime.UTC().Format(time.RFC3339)
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Warn("unable to read archive", "file", fl.FilePath, "error", err)
+	}
+	return results
+}
+
+func hasExtension(name string, extensions []string) bool {
+	ext := filepath.Ext(name)
+	for _, e := range extensions {
+		if strings.EqualFold(ext, strings.TrimSpace(e)) {
+			return true
+		}
+	}
+	return false
+}
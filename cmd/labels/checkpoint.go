@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+var checkpointPath string
+var checkpointInterval time.Duration
+
+// scanCheckpoint writes the files completed so far to --checkpoint every --checkpoint-interval
+type scanCheckpoint struct {
+	c     *sl.Checkpoint
+	saved time.Time
+}
+
+// loadCheckpoint resumes the checkpoint of an interrupted scan of the same roots,
+// or starts a new one, nil without --checkpoint
+func loadCheckpoint(cmd string, roots []scanRoot) *scanCheckpoint {
+	if checkpointPath == "" {
+		return nil
+	}
+	paths := make([]string, len(roots))
+	for i, root := range roots {
+		paths[i] = root.path
+	}
+	c, err := sl.LoadCheckpoint(checkpointPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		c = sl.NewCheckpoint(cmd, paths, runId)
+	case err != nil:
+		exitError(fmt.Errorf("unable to read --checkpoint %s: %w", checkpointPath, err))
+	case c.Version != sl.CheckpointVersion || c.Command != cmd || !slices.Equal(c.Roots, paths):
+		exitError(fmt.Errorf("--checkpoint %s belongs to a %s scan of %s, remove it to start over",
+			checkpointPath, c.Command, strings.Join(c.Roots, ", ")))
+	default:
+		logger.Info("resuming scan", "checkpoint", checkpointPath, "runId", c.RunId, "files", len(c.Files), "updated", c.Updated)
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Resuming run %s: %d files already scanned\n", c.RunId, len(c.Files))
+		}
+	}
+	return &scanCheckpoint{c: c, saved: time.Now()}
+}
+
+// done returns the results recorded for a file completed before the scan was interrupted
+func (s *scanCheckpoint) done(filePath string) ([]sl.Result, bool) {
+	if s == nil {
+		return nil, false
+	}
+	results, ok := s.c.Files[filePath]
+	return results, ok
+}
+
+// add records a completed file, the checkpoint is written once --checkpoint-interval elapsed
+func (s *scanCheckpoint) add(filePath string, results []sl.Result) {
+	if s == nil {
+		return
+	}
+	s.c.Add(filePath, results)
+	if time.Since(s.saved) >= checkpointInterval {
+		s.save()
+	}
+}
+
+func (s *scanCheckpoint) save() {
+	if s == nil {
+		return
+	}
+	if err := s.c.Write(checkpointPath); err != nil {
+		warn("unable to write the checkpoint", "path", checkpointPath, "error", err)
+	}
+	s.saved = time.Now()
+}
+
+// finish removes the checkpoint once every file was scanned
+func (s *scanCheckpoint) finish() {
+	if s == nil {
+		return
+	}
+	if err := sl.RemoveCheckpoint(checkpointPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		warn("unable to remove the checkpoint", "path", checkpointPath, "error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+var olderThan time.Duration
+
+func init() {
+	cleanFlags := flag.NewFlagSet("clean-tmp", flag.ContinueOnError)
+	cleanFlags.DurationVar(&olderThan, "older-than", time.Hour, "only remove directories created longer ago than this")
+	cleanFlags.BoolVar(&dryrun, "dry-run", false, "show directories without removing them")
+	addCommand(&command{
+		name:    "clean-tmp",
+		summary: "remove extraction directories left in --tmp-dir by crashed runs",
+		examples: []string{
+			`labels.exe clean-tmp --tmp-dir "path\to\tmp" --older-than 24h`,
+		},
+		run: runCleanTmp,
+	}, cleanFlags)
+}
+
+// runCleanTmp removes directories carrying an extraction marker,
+// anything else in --tmp-dir is left alone
+func runCleanTmp(args []string) {
+	dirs, err := sl.FindTmpDirs(tmpDir)
+	if err != nil {
+		exitError(err)
+	}
+	removed := 0
+	for _, dir := range dirs {
+		marker, err := sl.ReadTmpMarker(dir)
+		if err != nil {
+			warn("unable to read marker", "path", dir, "error", err)
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, marker.Created)
+		if err != nil {
+			warn("invalid marker", "path", dir, "error", err)
+			continue
+		}
+		age := time.Since(created)
+		if age < olderThan {
+			logger.Debug("skipping recent directory", "path", dir, "age", age)
+			continue
+		}
+		if !dryrun {
+			if err := sl.RemoveTmpDir(dir, tmpOptions()); err != nil {
+				warn("unable to remove directory", "path", dir, "error", err)
+				continue
+			}
+		}
+		removed++
+		fmt.Fprintln(out, quotePath(dir)+delimiter+marker.RunId+delimiter+marker.Host+delimiter+marker.Created+delimiter+quotePath(marker.Source))
+	}
+	if dryrun {
+		fmt.Fprintf(out, "%d orphaned directories would be removed\n", removed)
+	} else {
+		fmt.Fprintf(out, "%d orphaned directories removed\n", removed)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "os"
+
+// ansi color codes for human readable output
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+var noColor bool
+
+// colors are only used when the output is a terminal and NO_COLOR is not set
+func useColor(f *os.File) bool {
+	if noColor {
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps stdout text, machine readable output is never colored
+func colorize(color, s string) string {
+	if showJson || reportFormat != "" || outFile != nil || !useColor(os.Stdout) {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,1358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+// flags for commands that scan files and report labels
+var extensionsCsv = ".docx,.xlsx,.pptx"
+var denyLabelsCsv, denyTenantsCsv, expectedTenant string
+var denyLabels, denyTenants []string
+var showJson, showLabeledOnly, showSummary, showRollup, recurse, failFast, showMetadata, showClassification, showMacros, showStats, showHistory, showTimestamps, validate bool
+var retries int
+var retryDelay time.Duration
+var memoryThreshold int64
+var unzipOpts = sl.DefaultUnzipOptions
+var groupBy, reportFormat, ownershipFile string
+var ownershipMap *sl.OwnershipMap
+var xmlMode = xmlLenient
+var groupDepth, topN int
+var scanFlags = flag.NewFlagSet("scan", flag.ContinueOnError)
+
+// flags only accepted by get
+var assertReadonly bool
+var rootPaths []string
+var getFlags = flag.NewFlagSet("get", flag.ContinueOnError)
+
+// flags for commands that modify files
+var auditLog, backupDir, excludeFile string
+var auditRotation = sl.AuditRotation{Compress: true}
+var auditPrune sync.Once
+var exclusions *sl.Exclusions
+var manifestPath, manifestHmacKey, manifestKey, manifestCert string
+var dryrun, forceReadonly, touch, lock, forceBreakLock, breakSignature, adsMirror bool
+var lockWait time.Duration
+var inUse = "skip"
+var writeFlags = flag.NewFlagSet("write", flag.ContinueOnError)
+
+func init() {
+	scanFlags.StringVar(&extensionsCsv, "extensions", extensionsCsv, "file extensions to search for")
+	scanFlags.BoolVar(&showLabeledOnly, "labeled", false, "only show labeled files")
+	scanFlags.BoolVar(&showJson, "json", false, "display results as json")
+	scanFlags.BoolVar(&showSummary, "summary", false, "show totals after the results: labeled and unlabeled files, files per label and tenant, errors and scan duration")
+	scanFlags.StringVar(&reportFormat, "report", "", "render the results as a report once the scan completes: md for Markdown")
+	scanFlags.BoolVar(&showRollup, "rollup", false, "show a coverage line for every directory including its subdirectories: files, labeled, unlabeled and the dominant label")
+	scanFlags.StringVar(&groupBy, "group-by", "", "roll results up by label, tenant, directory, extension, department or owner")
+	scanFlags.StringVar(&ownershipFile, "ownership", "", "CSV file mapping path prefixes to department, owner and steward, joined into results, groups and rollups")
+	scanFlags.IntVar(&topN, "top", 0, "list the N largest and N most recently modified unlabeled files after the results")
+	scanFlags.IntVar(&groupDepth, "group-depth", 1, "with --group-by directory, number of directory levels below the path to group by (0 for full directories)")
+	scanFlags.StringVar(&xmlMode, "xml", xmlMode, "malformed LabelInfo.xml: strict fails the file with the line and offset, lenient keeps the labels read so far and warns")
+	scanFlags.BoolVar(&validate, "validate", false, "check LabelInfo.xml against the mipLabelMetadata schema and report malformed label metadata (exit code 4)")
+	scanFlags.BoolVar(&showMetadata, "metadata", false, "also show document properties: author, last modified by, company, created and modified dates")
+	scanFlags.BoolVar(&showStats, "stats", false, "also show page, word, sheet, slide, embedded object and media counts")
+	scanFlags.BoolVar(&showTimestamps, "timestamps", false, "also show the file modification time and the date the current label was set (from MSIP_Label_* custom properties)")
+	scanFlags.BoolVar(&showHistory, "history", false, "also show the label history recorded in legacy MSIP_Label_* custom properties")
+	scanFlags.BoolVar(&showClassification, "classification", false, "also show classification metadata written by other tools: Titus, Boldon James, Janusseal and MSIP custom properties")
+	scanFlags.BoolVar(&showEmbedded, "embedded", false, "also report the labels of documents embedded in documents, as report.docx!/word/embeddings/sheet.xlsx")
+	scanFlags.BoolVar(&showMacros, "macros", false, "also report VBA macros and macros in files whose extension is not macro-enabled")
+	scanFlags.StringVar(&pathStyle, "paths", "", "print file paths relative to the scanned path, absolute, or as file: URIs (relative, absolute or uri)")
+	scanFlags.StringVar(&fciProperty, "fci-property", "", "set this FSRM classification property of every scanned file to its label names (Windows file servers)")
+	scanFlags.StringSliceVar(&mailTo, "mail-to", nil, "mail the summary and a CSV of the results to these addresses once the scan completes")
+	scanFlags.StringVar(&mailFrom, "mail-from", "", "sender address of --mail-to mails")
+	scanFlags.StringVar(&smtpServer, "smtp-server", "", "SMTP server host:port used for --mail-to, the password of --smtp-user is read from "+envName("smtp-password"))
+	scanFlags.StringVar(&smtpUser, "smtp-user", "", "user to authenticate to --smtp-server with")
+	scanFlags.IntVar(&mailThreshold, "mail-threshold", 0, "only mail when at least this many files carry forbidden labels or labels of other tenants (0 always mails)")
+	scanFlags.StringVar(&progressFormat, "progress", "", "write progress events to stderr: json for one JSON object per line (scanned, total, path, rate, ETA)")
+	scanFlags.BoolVar(&recurse, "recursive", false, "recurse through subdirectory files")
+	scanFlags.StringVar(&checkpointPath, "checkpoint", "", "write the scan position and results to this file, an interrupted scan run again with it resumes where it left off")
+	scanFlags.DurationVar(&checkpointInterval, "checkpoint-interval", time.Minute, "how often --checkpoint is written")
+	scanFlags.StringVar(&shardSpec, "shard", "", "only process slice i of n of the files, e.g. 3/8, assigned by a hash of their path so independent jobs split a tree")
+	scanFlags.IntVar(&retries, "retries", 3, "number of times to retry files locked by another process")
+	scanFlags.DurationVar(&retryDelay, "retry-delay", 500*time.Millisecond, "delay before the first retry, doubled after each attempt")
+	scanFlags.Int64Var(&memoryThreshold, "memory-threshold", sl.DefaultMemoryThreshold, "read labels of documents up to this many bytes in memory, larger documents are extracted to --tmp-dir (0 always extracts)")
+	scanFlags.Int64Var(&unzipOpts.MaxBytes, "max-extract-bytes", unzipOpts.MaxBytes, "fail documents that decompress to more than this many bytes when extracted (0 for no limit)")
+	scanFlags.IntVar(&unzipOpts.MaxDepth, "max-path-depth", unzipOpts.MaxDepth, "fail documents with entries nested deeper than this many directories (0 for no limit)")
+	scanFlags.BoolVar(&failFast, "fail-fast", false, "abort the run on the first file error instead of continuing")
+	scanFlags.StringVar(&denyLabelsCsv, "deny-labels", "", "flag files carrying any of these label IDs or names")
+	scanFlags.StringVar(&denyTenantsCsv, "deny-tenants", "", "flag files carrying labels from any of these tenant IDs or names")
+	scanFlags.StringVar(&expectedTenant, "expected-tenant", "", "warn about labels whose siteId is not this tenant ID or name")
+
+	getFlags.StringSliceVar(&rootPaths, "path", nil, "additional file or directory to scan, may be repeated, results are tagged with their root")
+	getFlags.StringVar(&urlList, "url-list", "", "file listing http(s) URLs of documents to scan, one per line, headers for each host come from the config")
+	getFlags.DurationVar(&urlTimeout, "url-timeout", time.Minute, "time allowed to download each URL")
+	getFlags.BoolVar(&assertReadonly, "assert-readonly", false, "abort the run if anything would be written, including extraction to the temporary directory")
+
+	writeFlags.BoolVar(&dryrun, "dry-run", false, "show a diff of the label changes without applying them")
+	writeFlags.BoolVar(&forceReadonly, "force-readonly", false, "temporarily clear the read-only attribute to relabel read-only files")
+	writeFlags.StringVar(&inUse, "in-use", inUse, "files open in Office: skip, or defer to a retry pass at the end of the run")
+	writeFlags.BoolVar(&breakSignature, "break-signature", false, "relabel digitally signed documents, invalidating their signature (skipped otherwise)")
+	writeFlags.BoolVar(&adsMirror, "ads", false, "also write the labels of rewritten files to a file.docx:mip.json alternate data stream (Windows, NTFS)")
+	writeFlags.BoolVar(&touch, "touch", false, "update the modified time of relabeled files instead of preserving timestamps and attributes")
+	writeFlags.BoolVar(&lock, "lock", false, "hold a lock file in the target root so concurrent runs can't modify the same tree")
+	writeFlags.DurationVar(&lockWait, "wait", 0, "with --lock, how long to wait for another run to release the lock")
+	writeFlags.BoolVar(&forceBreakLock, "force-break-lock", false, "with --lock, remove an existing lock file, e.g. one left by a crashed run")
+	writeFlags.StringVar(&excludeFile, "exclude-file", "", "file listing paths or globs that must never be modified (legal holds), one per line")
+	writeFlags.StringVar(&backupDir, "backup", "", "copy each file into this directory (keyed by run ID) before modifying it")
+	writeFlags.StringVar(&auditLog, "audit-log", "", "append a JSONL audit record for each modification to this file")
+	writeFlags.Int64Var(&auditRotation.MaxSize, "audit-max-size", 0, "rotate --audit-log once it reaches this many bytes (0 for no limit)")
+	writeFlags.DurationVar(&auditRotation.MaxAge, "audit-max-age", 0, "rotate --audit-log once its first record is this old, e.g. 720h")
+	writeFlags.BoolVar(&auditRotation.Compress, "audit-compress", auditRotation.Compress, "gzip rotated audit logs")
+	writeFlags.DurationVar(&auditRotation.Retention, "audit-retention", 0, "remove rotated audit logs whose last record is older than this, e.g. 61320h for 7 years (0 keeps every log)")
+	writeFlags.StringVar(&manifestPath, "manifest", "", "write a manifest of all changes to this file")
+	writeFlags.StringVar(&manifestHmacKey, "manifest-hmac-key", "", "path to a key file used to sign the manifest with HMAC-SHA256")
+	writeFlags.StringVar(&manifestKey, "manifest-key", "", "path to a PEM private key used to sign the manifest")
+	writeFlags.StringVar(&manifestCert, "manifest-cert", "", "path to a PEM certificate embedded in the signed manifest")
+
+	addCommand(&command{
+		name:     "get",
+		args:     []string{"path"},
+		variadic: true,
+		summary:  "list sensitivity labels for the provided files or directories",
+		examples: []string{
+			`labels.exe get .`,
+			`labels.exe get "path\to\dir" --labeled --recursive --json`,
+			`labels.exe get "\\server\share1" "\\server\share2" --recursive --summary`,
+		},
+		run: runGet,
+	}, getFlags, scanFlags)
+	addCommand(&command{
+		name:    "set",
+		args:    []string{"path", "labelId", "tenantId"},
+		summary: "apply the provided sensitivity label ID to the provided file or directory",
+		examples: []string{
+			`labels.exe set "path\to\file.xlsx" "1234-label-id-1234" "4321-tenant-id-4321"`,
+		},
+		run: runSet,
+	}, writeFlags, scanFlags)
+	addCommand(&command{
+		name:    "retag",
+		args:    []string{"path", "tenantId"},
+		summary: "rewrite the siteId of labels from other tenants to the provided tenant ID",
+		examples: []string{
+			`labels.exe retag "path\to\dir" "4321-tenant-id-4321" --dry-run`,
+		},
+		run: runRetag,
+	}, writeFlags, scanFlags)
+	addCommand(&command{
+		name:    "remove",
+		args:    []string{"path"},
+		summary: "remove all sensitivity labels from the provided file or directory",
+		examples: []string{
+			`labels.exe remove "path\to\file.xlsx" --backup "path\to\backups"`,
+		},
+		run: runRemove,
+	}, writeFlags, scanFlags)
+	addCommand(&command{
+		name:    "dedupe",
+		args:    []string{"path"},
+		summary: "collapse label IDs listed more than once into a single entry",
+		examples: []string{
+			`labels.exe dedupe "path\to\dir" --dry-run`,
+		},
+		run: runDedupe,
+	}, writeFlags, scanFlags)
+	addCommand(&command{
+		name:    "normalize",
+		args:    []string{"path"},
+		summary: "rewrite LabelInfo.xml in canonical form so file hashes and diffs are comparable across a fleet",
+		examples: []string{
+			`labels.exe normalize "path\to\dir" --recursive --dry-run`,
+		},
+		run: runNormalize,
+	}, writeFlags, scanFlags)
+	addCommand(&command{
+		name:     "help",
+		optional: []string{"command"},
+		summary:  "show usage for labels.exe or the provided command",
+		run:      runHelp,
+	})
+}
+
+func runHelp(args []string) {
+	if len(args) == 0 {
+		printUsage("")
+		return
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		printUsage("Error: unsupported command " + args[0])
+		os.Exit(sl.ExitUsage)
+	}
+	printCommandUsage(cmd, "")
+}
+
+func runGet(args []string) {
+	if assertReadonly && checkpointPath != "" {
+		printCommandUsage(findCommand("get"), "Error: --checkpoint writes a file and cannot be combined with --assert-readonly")
+		exit(sl.ExitUsage)
+	}
+	if assertReadonly {
+		sl.SetReadOnlyMode(true)
+	}
+	paths := append(args, rootPaths...)
+	if urlList != "" {
+		urls, err := readURLList(urlList)
+		if err != nil {
+			exitError(fmt.Errorf("unable to read --url-list: %w", err))
+		}
+		paths = append(paths, urls...)
+	}
+	scanPaths("get", paths, nil)
+}
+
+func runSet(args []string) {
+	labelId, tenantId := args[1], args[2]
+	logger.Debug("args", "labelId", labelId, "tenantId", tenantId)
+	scan("set", args[0], func(fl sl.Result) (sl.Labels, bool) {
+		return sl.Labels{Labels: []sl.Label{newLabel(labelId, tenantId)}}, true
+	})
+}
+
+// newLabel returns the label entry written by set
+func newLabel(labelId, tenantId string) sl.Label {
+	return sl.Label{
+		Id:          labelId,
+		SiteId:      tenantId,
+		Enabled:     "1",
+		Method:      "Privileged",
+		ContentBits: "0",
+		Removed:     "0",
+	}
+}
+
+func runRetag(args []string) {
+	expectedTenant = args[1]
+	flagSource["expected-tenant"] = "argument"
+	logger.Debug("args", "tenantId", expectedTenant)
+	scan("retag", args[0], func(fl sl.Result) (sl.Labels, bool) {
+		// only rewrite files carrying labels from other tenants
+		if len(sl.FindTenantMismatches(fl.Labels, expectedTenant)) == 0 {
+			return sl.Labels{}, false
+		}
+		return sl.RetagLabels(fl.Labels, expectedTenant), true
+	})
+}
+
+func runRemove(args []string) {
+	scan("remove", args[0], func(fl sl.Result) (sl.Labels, bool) {
+		return sl.Labels{Labels: []sl.Label{}}, fl.LabelInfo
+	})
+}
+
+func runDedupe(args []string) {
+	scan("dedupe", args[0], func(fl sl.Result) (sl.Labels, bool) {
+		if len(sl.FindDuplicateLabels(fl.Labels)) == 0 {
+			return sl.Labels{}, false
+		}
+		return sl.DedupeLabels(fl.Labels), true
+	})
+}
+
+// runNormalize rewrites LabelInfo.xml parts that differ from their canonical form,
+// label semantics are unchanged
+func runNormalize(args []string) {
+	scan("normalize", args[0], func(fl sl.Result) (sl.Labels, bool) {
+		if !fl.LabelInfo {
+			return sl.Labels{}, false
+		}
+		current, ok, err := sl.ReadLabelInfoXml(fl.FilePath)
+		if err != nil || !ok {
+			logger.Debug("LabelInfo.xml not readable", "file", fl.FilePath, "error", err)
+			return sl.Labels{}, false
+		}
+		normalized := sl.NormalizeLabels(fl.Labels)
+		return normalized, string(current) != sl.LabelInfoXml(normalized)
+	})
+}
+
+// resolvePolicy resolves label and tenant names of the policy flags to IDs,
+// again when a reloaded config changes them
+func resolvePolicy() error {
+	if expectedTenant != "" {
+		ids := parseIdList(expectedTenant, labelConfig.Tenants)
+		if len(ids) != 1 {
+			return fmt.Errorf("expected tenant %q must be a single tenant ID or name", expectedTenant)
+		}
+		expectedTenant = ids[0]
+	}
+	denyLabels = parseIdList(denyLabelsCsv, labelConfig.Labels)
+	denyTenants = parseIdList(denyTenantsCsv, labelConfig.Tenants)
+	return nil
+}
+
+// update is called for each file to decide on new labels,
+// returning false leaves the file untouched
+type updateFunc func(fl sl.Result) (sl.Labels, bool)
+
+// prepareScan resolves the scan flags shared by every scanning command
+func prepareScan() []string {
+	extensions := strings.Split(strings.TrimSpace(extensionsCsv), ",")
+	sl.RegisterHandler("ooxml", &sl.OOXMLHandler{MemoryThreshold: memoryThreshold, TmpDir: tmpDir, Unzip: unzipOpts, Tmp: tmpOptions()})
+	if err := resolvePolicy(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		exit(sl.ExitUsage)
+	}
+	if err := checkPathStyle(); err != nil {
+		exitError(err)
+	}
+	if ownershipFile != "" {
+		var err error
+		if ownershipMap, err = sl.ReadOwnershipMap(ownershipFile); err != nil {
+			exitError(fmt.Errorf("unable to read --ownership: %w", err))
+		}
+		logger.Debug("ownership", "file", ownershipFile, "entries", len(ownershipMap.Entries))
+	}
+	if err := startFCI(); err != nil {
+		exitError(fmt.Errorf("--fci-property: %w", err))
+	}
+	if adsMirror && runtime.GOOS != "windows" {
+		exitError(fmt.Errorf("--ads: %w", sl.ErrStreamsUnsupported))
+	}
+	if excludeFile != "" {
+		var err error
+		if exclusions, err = sl.ReadExclusions(excludeFile); err != nil {
+			exitError(fmt.Errorf("unable to read --exclude-file: %w", err))
+		}
+		logger.Debug("exclusions", "file", excludeFile, "patterns", len(exclusions.Patterns))
+	}
+	if err := checkProgressFormat(); err != nil {
+		exitError(err)
+	}
+	if err := checkMail(); err != nil {
+		exitError(err)
+	}
+	if xmlMode != xmlStrict && xmlMode != xmlLenient {
+		exitError(fmt.Errorf("invalid --xml value %q, expected strict or lenient", xmlMode))
+	}
+	switch {
+	case reportFormat != "" && reportFormat != "md":
+		exitError(fmt.Errorf("invalid --report value %q, expected md", reportFormat))
+	case reportFormat != "" && showJson:
+		exitError(fmt.Errorf("--report and --json can't be combined"))
+	case reportFormat == "md":
+		// the report always starts with the totals
+		showSummary = true
+	}
+	if groupBy != "" {
+		if _, err := sl.GroupResults(nil, groupBy, "", groupDepth); err != nil {
+			exitError(err)
+		}
+	}
+	if dryrun {
+		warn("dry-run enabled")
+	}
+	return extensions
+}
+
+// listFiles returns the path of every matching file, path may be a single file
+func listFiles(path string, extensions []string) []string {
+	if sl.IsStorageURL(path) {
+		return listStorage(path, extensions)
+	}
+	var filePaths []string
+
+	// check if path exists
+	pathInfo, err := os.Stat(sl.LongPath(path))
+	if err != nil {
+		exitError(err)
+	}
+
+	// check if path is a directory, if so list files
+	if pathInfo.IsDir() && recurse {
+		scanner := sl.NewScanner(extensions)
+		scanner.Recursive = true
+		found, err := scanner.ListFiles(path)
+		if err != nil {
+			exitError(err)
+		}
+		for _, filePath := range found {
+			relPath, err := filepath.Rel(path, filePath)
+			if err != nil {
+				exitError(err)
+			}
+			filePaths = append(filePaths, sl.JoinPath(path, filepath.ToSlash(relPath)))
+		}
+	} else if pathInfo.IsDir() {
+		for _, file := range sl.ListExtensionFiles(path, false, extensions) {
+			// create full path to file
+			filePaths = append(filePaths, sl.JoinPath(path, file.Name()))
+		}
+	} else {
+		// single file, only the trailing name is replaced as it may also appear in the directory
+		dir := strings.TrimSuffix(path, pathInfo.Name())
+		if dir == "" {
+			dir = "."
+		}
+		filePaths = append(filePaths, sl.JoinPath(dir, pathInfo.Name()))
+	}
+	return filePaths
+}
+
+// per-file error categories used in results and the error summary
+const (
+	errExtract = "extract"
+	errBackup  = "backup"
+	errWrite   = "write"
+	errVerify  = "verify"
+	errFormat  = "format"
+	errXML     = "xml"
+	errFetch   = "fetch"
+)
+
+// --xml modes for malformed LabelInfo.xml
+const (
+	xmlStrict  = "strict"
+	xmlLenient = "lenient"
+)
+
+// skip reasons
+const (
+	skipReadOnly = "read-only"
+	skipInUse    = "in-use"
+	skipSigned   = "signed"
+	skipExcluded = "excluded"
+)
+
+// processFile reads the labels of a single file and applies update when provided,
+// errors are recorded on the result unless --fail-fast is set
+func processFile(cmd, filePath string, update updateFunc, manifest *sl.Manifest) sl.Result {
+	applyConfigReload()
+	start := time.Now()
+	currentFile.Store(filePath)
+	sf, stored := storageFiles[filePath]
+	if sl.IsURL(filePath) || filePath == stdinPath || stored {
+		var fl sl.Result
+		switch {
+		case filePath == stdinPath:
+			fl = processStdin(cmd, update)
+		case stored:
+			fl = processStorage(cmd, filePath, sf, update)
+		default:
+			fl = processURL(cmd, filePath, update)
+		}
+		fl.DurationMs = time.Since(start).Milliseconds()
+		return fl
+	}
+	fl := processFileLabels(cmd, filePath, update, manifest)
+	fl.DurationMs = time.Since(start).Milliseconds()
+	if info, err := os.Stat(sl.LongPath(filePath)); err == nil {
+		fl.Bytes = info.Size()
+		fl.Modified = info.ModTime().UTC().Format(time.RFC3339)
+	}
+	return fl
+}
+
+// inMemory reports whether a document is read without extracting it, only plain reads
+// of small documents qualify as every other feature works on the extracted files
+func inMemory(ooxml *sl.OOXMLHandler, filePath string, update updateFunc) bool {
+	if update != nil || validate || showMetadata || showStats || showHistory || showTimestamps || showClassification || showMacros {
+		return false
+	}
+	info, err := os.Stat(sl.LongPath(filePath))
+	return err == nil && ooxml.InMemory(info.Size())
+}
+
+// checkXMLError returns the error category of a failed label read, malformed XML
+// is only a warning on the result with --xml lenient
+func checkXMLError(flog *slog.Logger, fl *sl.Result, err error) (string, error) {
+	var xmlErr *sl.XMLError
+	if !errors.As(err, &xmlErr) {
+		return errExtract, err
+	}
+	if xmlMode == xmlStrict {
+		return errXML, err
+	}
+	fileWarning(flog, fl, "malformed xml, labels may be incomplete: "+xmlErr.Error())
+	return "", nil
+}
+
+// checkDuplicates warns about label IDs listed more than once, fixed by dedupe
+func checkDuplicates(flog *slog.Logger, fl *sl.Result) {
+	for _, problem := range sl.FindDuplicateLabels(fl.Labels) {
+		fileWarning(flog, fl, "duplicate label entries: "+problem)
+	}
+}
+
+// mirrorLabels writes labels to the --ads stream, written after the document so
+// timestamps are restored afterwards, failing to write it only warns
+func mirrorLabels(flog *slog.Logger, fl *sl.Result, labels []sl.Label) {
+	if !adsMirror {
+		return
+	}
+	if err := sl.WriteLabelStream(fl.FilePath, resolveNames(labels), runId); err != nil {
+		fileWarning(flog, fl, "unable to write the "+sl.LabelStreamName+" stream", "error", err)
+	}
+}
+
+// excluded reports files on the --exclude-file list, they are never modified
+func excluded(flog *slog.Logger, fl *sl.Result) bool {
+	pattern, ok := exclusions.Match(fl.FilePath)
+	if ok {
+		fileWarning(flog, fl, "file is on the exclusion list and was not modified", "pattern", pattern)
+	}
+	return ok
+}
+
+// fileWarning logs a warning and records it on the result
+func fileWarning(flog *slog.Logger, fl *sl.Result, msg string, args ...any) {
+	flog.Warn(msg, args...)
+	fl.Warnings = append(fl.Warnings, msg)
+}
+
+func processFileLabels(cmd, filePath string, update updateFunc, manifest *sl.Manifest) sl.Result {
+	// create temporary directory for file extraction
+	tmpUnzipDir := filepath.Join(tmpDir, sl.TmpDirName(filePath))
+	flog := logger.With("file", filePath)
+	trackTmpDir(tmpUnzipDir)
+	defer untrackTmpDir(tmpUnzipDir)
+	defer cleanup(tmpUnzipDir)
+	fl := sl.Result{
+		FilePath: filePath,
+		Labels:   []sl.Label{},
+	}
+	fail := func(category string, err error) sl.Result {
+		flog.Error("file error", "category", category, "error", err)
+		if failFast || errors.Is(err, sl.ErrWriteDenied) {
+			// clean up on error
+			cleanup(tmpUnzipDir)
+			exitError(err)
+		}
+		fl.Error = err.Error()
+		fl.ErrorCategory = category
+		if category == errFormat || category == errExtract {
+			fl.Reason = sl.FailureReason(filePath, err)
+		}
+		return fl
+	}
+
+	// formats other than OOXML are read and written by their registered handler
+	name, handler, err := sl.FindHandler(filePath)
+	if err != nil {
+		return fail(errFormat, err)
+	}
+	fl.Handler = name
+	var mismatch *sl.FormatMismatchError
+	if err := sl.CheckContainer(filePath); errors.As(err, &mismatch) {
+		fileWarning(flog, &fl, "extension does not match the contents, read as "+name, "extension", mismatch.Ext, "container", mismatch.Container)
+	}
+	if _, ok := handler.(*sl.OOXMLHandler); ok {
+		signatures, err := sl.FindSignatures(filePath)
+		if err != nil {
+			return fail(errExtract, err)
+		}
+		fl.Signatures = signatures
+	}
+	ooxml, ok := handler.(*sl.OOXMLHandler)
+	if !ok || inMemory(ooxml, filePath, update) {
+		flog.Debug("handler", "name", name)
+		return processWithHandler(cmd, flog, fl, name, handler, update, manifest, fail)
+	}
+
+	flog.Debug("extract", "tmpUnzipDir", tmpUnzipDir)
+	if err := sl.CreateTmpDirWith(tmpUnzipDir, filePath, runId, tmpOptions()); err != nil {
+		return fail(errExtract, err)
+	}
+	unzipErr := retry(flog, func() error {
+		return sl.UnzipWith(filePath, tmpUnzipDir, unzipOpts)
+	})
+	if unzipErr != nil {
+		return fail(errExtract, unzipErr)
+	}
+	// check extracted files for docMetadata/LabelInfo.xml
+	labelInfoExists, labelInfoPath := sl.CheckLabelInfoPath(tmpUnzipDir)
+	flog.Debug("check LabelInfo.xml", "exists", labelInfoExists, "path", labelInfoPath)
+	fl.LabelInfo = labelInfoExists
+
+	if fl.LabelInfo && validate {
+		problems, err := sl.ValidateLabelInfo(labelInfoPath)
+		if err != nil {
+			return fail(errExtract, err)
+		}
+		for _, problem := range problems {
+			flog.Debug("invalid LabelInfo.xml", "problem", problem)
+		}
+		fl.Invalid = problems
+	}
+
+	// if LabelInfo.xml exists, parse XML and return labels
+	if fl.LabelInfo {
+		flog.Debug("open")
+		labels, err := sl.ParseLabelInfoFile(labelInfoPath)
+		if err != nil {
+			if category, err := checkXMLError(flog, &fl, err); err != nil {
+				return fail(category, err)
+			}
+		}
+		fl.Labels = labels.Labels
+		if fl.Labels == nil {
+			fl.Labels = []sl.Label{}
+		}
+		checkDuplicates(flog, &fl)
+	} else {
+		flog.Debug("LabelInfo.xml not found")
+	}
+	if showMetadata {
+		props, err := sl.GetDocumentProperties(tmpUnzipDir)
+		if err != nil {
+			return fail(errExtract, fmt.Errorf("unable to read document properties: %w", err))
+		}
+		fl.Metadata = &props
+	}
+	if showStats {
+		stats, err := sl.GetDocumentStatistics(tmpUnzipDir)
+		if err != nil {
+			return fail(errExtract, fmt.Errorf("unable to read document statistics: %w", err))
+		}
+		fl.Statistics = &stats
+	}
+	if showHistory || showTimestamps {
+		history, err := sl.GetLabelHistory(tmpUnzipDir)
+		if err != nil {
+			return fail(errExtract, fmt.Errorf("unable to read label history: %w", err))
+		}
+		if showHistory {
+			fl.History = history
+		}
+		if showTimestamps {
+			fl.SetDate = sl.LabelSetDate(history, fl.Labels)
+		}
+	}
+	if showClassification {
+		markers, err := sl.FindClassificationMarkers(tmpUnzipDir)
+		if err != nil {
+			return fail(errExtract, fmt.Errorf("unable to read classification metadata: %w", err))
+		}
+		fl.Classification = markers
+	}
+	if showMacros {
+		macros, err := sl.FindMacros(tmpUnzipDir, filePath)
+		if err != nil {
+			return fail(errExtract, fmt.Errorf("unable to check for macros: %w", err))
+		}
+		if macros.ExtensionMismatch {
+			fileWarning(flog, &fl, "macros found in a file that is not macro-enabled", "parts", macros.Parts)
+		}
+		fl.Macros = &macros
+	}
+
+	// set labels
+	if update != nil {
+		if newLabels, ok := update(fl); ok {
+			category, err := applyLabels(flog, cmd, &fl, ooxml, tmpUnzipDir, labelInfoPath, newLabels, manifest)
+			if !skipWrite(flog, &fl, category) && err != nil {
+				return fail(category, err)
+			}
+		}
+	}
+
+	fl.ForbiddenLabels = sl.FindForbiddenLabels(fl.Labels, denyLabels, denyTenants)
+	fl.TenantMismatch = sl.FindTenantMismatches(fl.Labels, expectedTenant)
+	return fl
+}
+
+// scan reads the labels of every matching file under path and prints the results,
+// update may be nil for read only commands
+func scan(cmd, path string, update updateFunc) {
+	scanPaths(cmd, []string{path}, update)
+}
+
+// scanPaths scans several roots in one run, e.g. a share per root
+func scanPaths(cmd string, paths []string, update updateFunc) {
+	extensions := prepareScan()
+	roots := make([]scanRoot, 0, len(paths))
+	for _, path := range paths {
+		logger.Debug("scan", "command", cmd, "path", path, "extensions", extensions)
+		if sl.IsURL(path) || path == stdinPath {
+			// documents on web servers are downloaded into memory, stdin is a single document
+			roots = append(roots, scanRoot{path: path, filePaths: []string{path}})
+			continue
+		}
+		roots = append(roots, scanRoot{path: path, filePaths: listFiles(path, extensions)})
+	}
+	pipeOutput(paths, update)
+	scanFiles(cmd, roots, update)
+}
+
+// scanRoot is a path given on the command line and the files found under it
+type scanRoot struct {
+	path      string
+	filePaths []string
+}
+
+// scanFiles processes the files found under each root, prepareScan must have been called,
+// results are tagged with their root when there is more than one
+func scanFiles(cmd string, roots []scanRoot, update updateFunc) {
+	var fileLabels, results []sl.Result
+	var forbidden, mismatched, errored, skipped, invalid, excludedFiles []sl.Result
+	violations := 0
+	manifest := sl.NewManifest()
+	start := time.Now()
+
+	if inUse != "skip" && inUse != "defer" {
+		exitError(fmt.Errorf("invalid --in-use value %q, expected skip or defer", inUse))
+	}
+	shardFiles(roots)
+	total := 0
+	targets := dfsTargets(roots)
+	for _, root := range roots {
+		if update != nil && lock && !dryrun && root.path != stdinPath && !sl.IsStorageURL(root.path) {
+			// DFS namespace paths are locked at the folder target they refer to
+			if target, ok := targets[root.path]; ok {
+				acquireLock(target)
+			} else {
+				acquireLock(root.path)
+			}
+		}
+		total += len(root.filePaths)
+	}
+
+	// print results header if files found, distributed scans list files on the workers
+	if total == 0 && distribute == nil {
+		if !quiet {
+			fmt.Fprintln(os.Stderr, "No files found")
+		}
+		exit(sl.ExitSuccess)
+	} else {
+		PrintFileLabelHeader()
+	}
+	prog := newProgress(total)
+	if distribute == nil {
+		health.queue.Store(int64(total))
+	}
+
+	// collect and print each result as it completes
+	scanned := 0
+	handle := func(root string, fl sl.Result) {
+		fl.Ownership = ownershipMap.Lookup(fl.FilePath)
+		if fl.Parent == "" {
+			publishClassification(&fl)
+			if target, ok := targets[root]; ok {
+				fl.Target = dfsTarget(root, target, fl.FilePath)
+			}
+		} else {
+			fl.Parent = formatPath(root, fl.Parent)
+		}
+		fl.FilePath = formatPath(root, fl.FilePath)
+		if len(roots) > 1 {
+			fl.Root = root
+		}
+		fl = resolveResultNames(fl)
+		if fl.Error != "" {
+			errored = append(errored, fl)
+		}
+		if fl.Skipped != "" {
+			skipped = append(skipped, fl)
+		}
+		if fl.Skipped == skipExcluded {
+			excludedFiles = append(excludedFiles, fl)
+		}
+		if len(fl.Invalid) > 0 {
+			invalid = append(invalid, fl)
+		}
+		if len(fl.TenantMismatch) > 0 {
+			mismatched = append(mismatched, fl)
+		}
+		if len(fl.ForbiddenLabels) > 0 {
+			forbidden = append(forbidden, fl)
+		}
+		if len(fl.ForbiddenLabels) > 0 || len(fl.TenantMismatch) > 0 {
+			violations++
+		}
+		sendToSinks(fl)
+		results = append(results, fl)
+		if fl.Parent == "" {
+			if fl.Error == "" {
+				health.success()
+			}
+			if distribute == nil {
+				health.queue.Add(-1)
+			}
+			scanned++
+			prog.file(scanned, fl.FilePath)
+		}
+		if !(showLabeledOnly && len(fl.Labels) == 0 && fl.Error == "") {
+			PrintFileLabel(fl)
+			PrintValidationProblems(fl)
+			PrintLabelHistory(fl)
+			PrintClassificationMarkers(fl)
+			PrintMacros(fl)
+			PrintLabelDiff(fl)
+			fileLabels = append(fileLabels, fl)
+		}
+	}
+
+	if distribute != nil {
+		distribute(handle)
+	}
+
+	// iterate through files, files open in Office may be deferred to a retry pass,
+	// files completed before an interrupted run are replayed from --checkpoint
+	checkpoint := loadCheckpoint(cmd, roots)
+	var deferred []scanRoot
+	for _, root := range roots {
+		retry := scanRoot{path: root.path}
+		for _, filePath := range root.filePaths {
+			if cancelled.Load() {
+				break
+			}
+			if recorded, ok := checkpoint.done(filePath); ok {
+				for _, r := range recorded {
+					handle(root.path, r)
+				}
+				continue
+			}
+			fl := processFile(cmd, filePath, update, manifest)
+			if inUse == "defer" && fl.Skipped == skipInUse {
+				logger.Info("deferred file open in Office", "file", filePath)
+				retry.filePaths = append(retry.filePaths, filePath)
+				continue
+			}
+			nested := nestedResults(&fl, update)
+			checkpoint.add(filePath, append([]sl.Result{fl}, nested...))
+			handle(root.path, fl)
+			for _, r := range nested {
+				handle(root.path, r)
+			}
+		}
+		deferred = append(deferred, retry)
+	}
+	for _, root := range deferred {
+		for _, filePath := range root.filePaths {
+			if cancelled.Load() {
+				break
+			}
+			fl := processFile(cmd, filePath, update, manifest)
+			nested := nestedResults(&fl, update)
+			checkpoint.add(filePath, append([]sl.Result{fl}, nested...))
+			handle(root.path, fl)
+			for _, r := range nested {
+				handle(root.path, r)
+			}
+		}
+	}
+	prog.done(scanned)
+	if cancelled.Load() {
+		checkpoint.save()
+	} else {
+		checkpoint.finish()
+	}
+
+	// write manifest of applied changes
+	if update != nil && manifestPath != "" && !dryrun {
+		if err := writeManifest(manifest); err != nil {
+			exitError(err)
+		}
+	}
+
+	// print json results and report sections
+	report := jsonReport{Results: fileLabels, GroupBy: groupBy}
+	if showSummary {
+		s := sl.Summarize(results, time.Since(start))
+		report.Summary = &s
+	}
+	if groupBy != "" {
+		report.Groups = groupResults(roots, results)
+	}
+	if showRollup {
+		report.Directories = rollupDirectories(roots, results)
+	}
+	if topN > 0 {
+		top := sl.TopUnlabeled(results, topN)
+		report.Top = &top
+	}
+	PrintFileLabelsJson(report)
+	PrintMarkdownReport(report)
+	PrintSummary(report.Summary)
+	PrintGroups(report.GroupBy, report.Groups)
+	PrintDirectoryRollups(report.Directories)
+	PrintTopFiles(report.Top)
+
+	if len(mismatched) > 0 {
+		PrintTenantMismatches(mismatched)
+	}
+
+	if len(skipped) > 0 {
+		PrintSkipSummary(skipped)
+	}
+	if len(errored) > 0 {
+		PrintErrorSummary(errored)
+	}
+	if len(invalid) > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "\nInvalid: %d files with malformed label metadata\n", len(invalid))
+	}
+
+	if len(forbidden) > 0 {
+		PrintForbiddenLabels(forbidden)
+	}
+	if len(excludedFiles) > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "\nExcluded: %d files on the exclusion list would have been modified\n", len(excludedFiles))
+	}
+	if cancelled.Load() {
+		warn("run cancelled before all files were processed")
+		exit(sl.ExitCancelled)
+	}
+	for _, fn := range afterScan {
+		fn()
+	}
+	mailReport(cmd, roots, results, violations, time.Since(start))
+	if len(forbidden) > 0 || len(excludedFiles) > 0 {
+		exit(sl.ExitPolicyViolation)
+	}
+	if len(errored) > 0 || len(invalid) > 0 {
+		exit(sl.ExitFileErrors)
+	}
+}
+
+// functions run by scanFiles once every file was processed, before the exit code
+// is decided, e.g. writing a plan, not run when the scan was cancelled
+var afterScan []func()
+
+// groupResults applies --group-by, directories of several roots are grouped
+// per root and prefixed with it so equal subdirectories don't merge
+func groupResults(roots []scanRoot, results []sl.Result) []sl.Group {
+	if groupBy != sl.GroupByDirectory {
+		groups, _ := sl.GroupResults(results, groupBy, "", groupDepth)
+		return groups
+	}
+	var groups []sl.Group
+	for _, root := range roots {
+		rootGroups, _ := sl.GroupResults(rootResults(roots, results, root.path), groupBy, formatPath(root.path, groupRoot(root.path)), groupDepth)
+		for _, g := range rootGroups {
+			g.Ownership = dirOwnership(root.path, g.Key)
+			if len(roots) > 1 {
+				g.Key = path.Join(filepath.ToSlash(root.path), g.Key)
+			}
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// rollupDirectories applies --rollup per root, see groupResults
+func rollupDirectories(roots []scanRoot, results []sl.Result) []sl.DirectoryRollup {
+	var rollups []sl.DirectoryRollup
+	for _, root := range roots {
+		for _, r := range sl.RollupDirectories(rootResults(roots, results, root.path), formatPath(root.path, groupRoot(root.path))) {
+			r.Ownership = dirOwnership(root.path, r.Directory)
+			if len(roots) > 1 {
+				r.Directory = path.Join(filepath.ToSlash(root.path), r.Directory)
+			}
+			rollups = append(rollups, r)
+		}
+	}
+	return rollups
+}
+
+// dirOwnership looks up the --ownership of a directory key relative to root
+func dirOwnership(root, dir string) *sl.Ownership {
+	return ownershipMap.Lookup(filepath.Join(groupRoot(root), filepath.FromSlash(dir)))
+}
+
+// rootResults returns the results found under root, all of them for a single root
+func rootResults(roots []scanRoot, results []sl.Result, root string) []sl.Result {
+	if len(roots) == 1 {
+		return results
+	}
+	var filtered []sl.Result
+	for _, fl := range results {
+		if fl.Root == root {
+			filtered = append(filtered, fl)
+		}
+	}
+	return filtered
+}
+
+// groupRoot is the directory --group-by directory is relative to
+func groupRoot(root string) string {
+	if info, err := os.Stat(sl.LongPath(root)); err == nil && !info.IsDir() {
+		return filepath.Dir(root)
+	}
+	return root
+}
+
+// labelWrite is the format specific part of writing labels, writeLabels runs the
+// guards, backup, audit, attribute handling and verification around it
+type labelWrite struct {
+	prepare func() (bool, error)          // after the guards, false leaves the file untouched
+	diff    func() (*sl.LabelDiff, error) // the changes shown by --dry-run
+	before  func() error                  // runs before the write, e.g. hashing the parts
+	write   func() error
+	verify  func() ([]sl.PartHash, error) // checks the written file, part changes are audited
+}
+
+// applyLabels writes newLabels through the OOXML handler, sanitize writes the
+// extracted package it stripped instead
+func applyLabels(flog *slog.Logger, cmd string, fl *sl.Result, ooxml *sl.OOXMLHandler, tmpUnzipDir, labelInfoPath string, newLabels sl.Labels, manifest *sl.Manifest) (string, error) {
+	filePath := fl.FilePath
+	var partsBefore map[string]string
+	return writeLabels(flog, cmd, fl, newLabels, manifest, labelWrite{
+		prepare: func() (bool, error) {
+			if sanitizeOpts == nil {
+				return true, nil
+			}
+			changed, err := sanitizeFile(fl, tmpUnzipDir)
+			if err == nil && !changed {
+				flog.Debug("nothing to sanitize")
+			}
+			return changed, err
+		},
+		diff: func() (*sl.LabelDiff, error) {
+			diff, err := sl.DiffLabels(tmpUnzipDir, labelInfoPath, fl.Labels, newLabels)
+			return &diff, err
+		},
+		before: func() error {
+			var err error
+			partsBefore, err = sl.HashParts(filePath)
+			return err
+		},
+		write: func() error {
+			switch {
+			case preserveLabels:
+				return sl.Repack(tmpUnzipDir, filePath)
+			case sanitizeOpts != nil:
+				return sl.SetLabels(tmpUnzipDir, filePath, labelInfoPath, newLabels)
+			}
+			return ooxml.WriteLabels(filePath, newLabels)
+		},
+		verify: func() ([]sl.PartHash, error) {
+			changes, err := sl.VerifyParts(filePath, partsBefore, allowedParts(fl))
+			if err != nil {
+				return changes, err
+			}
+			want := newLabels.Labels
+			if preserveLabels {
+				want = fl.Labels
+			}
+			return changes, sl.VerifyLabels(filePath, want)
+		},
+	})
+}
+
+// writeLabels writes newLabels to fl.FilePath with w, the category of a failed or
+// skipped write is returned with the error
+func writeLabels(flog *slog.Logger, cmd string, fl *sl.Result, newLabels sl.Labels, manifest *sl.Manifest, w labelWrite) (string, error) {
+	filePath := fl.FilePath
+	if excluded(flog, fl) {
+		return skipExcluded, nil
+	}
+	if err := checkProfileTenant(newLabels); err != nil {
+		return errWrite, err
+	}
+	record := sl.NewAuditRecord(cmd, filePath, fl.Labels, newLabels.Labels)
+	readOnly, err := sl.IsReadOnly(filePath)
+	if err != nil {
+		return errWrite, err
+	}
+	if readOnly && !forceReadonly {
+		return skipReadOnly, nil
+	}
+	if sl.IsInUse(filePath) {
+		return skipInUse, nil
+	}
+	if len(fl.Signatures) > 0 {
+		if !breakSignature {
+			return skipSigned, nil
+		}
+		fileWarning(flog, fl, "digital signature invalidated by relabeling", "signatures", fl.Signatures)
+	}
+	if w.prepare != nil {
+		ok, err := w.prepare()
+		if err != nil {
+			return errWrite, err
+		}
+		if !ok {
+			return "", nil
+		}
+	}
+	flog.Info("write", "dryRun", dryrun)
+	if dryrun {
+		if w.diff != nil && !preserveLabels {
+			diff, err := w.diff()
+			if err != nil {
+				return errExtract, err
+			}
+			fl.Diff = diff
+		}
+		audit(flog, record, nil)
+		fl.Labels = newLabels.Labels
+		return "", nil
+	}
+	if backupDir != "" {
+		var backupPath string
+		err := retry(flog, func() error {
+			var err error
+			backupPath, err = sl.BackupFile(filePath, backupDir, runId)
+			return err
+		})
+		if err != nil {
+			audit(flog, record, err)
+			return errBackup, err
+		}
+		flog.Info("backup", "backupPath", backupPath)
+		record.BackupPath = backupPath
+	}
+	restorePath := record.BackupPath
+	if restorePath == "" {
+		snapshotDir, snapshotPath, err := snapshotFile(flog, filePath)
+		if err != nil {
+			audit(flog, record, err)
+			return errBackup, err
+		}
+		if snapshotDir != "" {
+			defer untrackTmpDir(snapshotDir)
+			defer cleanup(snapshotDir)
+			restorePath = snapshotPath
+		}
+	}
+	hashBefore, err := sl.HashFile(filePath)
+	if err != nil {
+		audit(flog, record, err)
+		return errExtract, err
+	}
+	record.HashBefore = hashBefore
+	if w.before != nil {
+		if err := w.before(); err != nil {
+			audit(flog, record, err)
+			return errExtract, err
+		}
+	}
+	attrs, err := sl.CaptureAttributes(filePath)
+	if err != nil {
+		audit(flog, record, err)
+		return errWrite, err
+	}
+	if readOnly {
+		flog.Info("clear read-only attribute")
+		restore, err := sl.ClearReadOnly(filePath)
+		if err != nil {
+			audit(flog, record, err)
+			return errWrite, err
+		}
+		defer func() {
+			if err := restore(); err != nil {
+				flog.Error("unable to restore read-only attribute", "error", err)
+			}
+		}()
+	}
+	err = retry(flog, func() error {
+		writing.RLock()
+		defer writing.RUnlock()
+		return w.write()
+	})
+	if err != nil {
+		audit(flog, record, err)
+		return errWrite, err
+	}
+	writing.RLock()
+	if w.verify != nil {
+		record.PartChanges, err = w.verify()
+	}
+	if err != nil {
+		err = restoreBackup(flog, restorePath, filePath, err)
+	}
+	writing.RUnlock()
+	hashAfter, hashErr := sl.HashFile(filePath)
+	if hashErr != nil {
+		fileWarning(flog, fl, "unable to hash the written file, it is left out of the manifest", "error", hashErr)
+	}
+	record.HashAfter = hashAfter
+	fl.HashBefore, fl.HashAfter = hashBefore, hashAfter
+	audit(flog, record, err)
+	if err != nil {
+		return errVerify, err
+	}
+	if preserveLabels {
+		mirrorLabels(flog, fl, fl.Labels)
+	} else {
+		mirrorLabels(flog, fl, newLabels.Labels)
+	}
+	if !touch {
+		if err := sl.RestoreAttributes(filePath, attrs); err != nil {
+			fileWarning(flog, fl, "unable to restore timestamps and attributes", "error", err)
+		}
+	}
+	if hashErr == nil {
+		manifest.Add(sl.ManifestEntry{
+			FilePath:     filePath,
+			HashBefore:   hashBefore,
+			HashAfter:    hashAfter,
+			LabelsBefore: fl.Labels,
+			LabelsAfter:  newLabels.Labels,
+		})
+	}
+	fl.LabelInfo = fl.LabelInfo || !preserveLabels
+	fl.Labels = newLabels.Labels
+	return "", nil
+}
+
+// skipWrite records a write skipped by writeLabels on the result, false for
+// the categories of failed writes
+func skipWrite(flog *slog.Logger, fl *sl.Result, category string) bool {
+	switch category {
+	case skipExcluded:
+	case skipReadOnly:
+		flog.Warn("skipped read-only file, use --force-readonly to relabel it")
+	case skipInUse:
+		flog.Warn("skipped file open in Office")
+	case skipSigned:
+		flog.Warn("skipped digitally signed file, use --break-signature to relabel it", "signatures", fl.Signatures)
+	default:
+		return false
+	}
+	fl.Skipped = category
+	return true
+}
+
+// snapshotFile copies filePath into a temporary directory when there is no
+// --backup, so a write failing verification can still be undone. With --no-disk
+// no snapshot is taken and the directory is empty
+func snapshotFile(flog *slog.Logger, filePath string) (string, string, error) {
+	dir := filepath.Join(tmpDir, sl.TmpDirName(filePath)+"-snapshot")
+	// left by a crashed run or --no-cleanup
+	if _, err := sl.ReadTmpMarker(dir); err == nil {
+		sl.RemoveTmpDir(dir, tmpOptions())
+	}
+	err := sl.CreateTmpDirWith(dir, filePath, runId, tmpOptions())
+	if errors.Is(err, sl.ErrNoDisk) {
+		flog.Debug("no snapshot with --no-disk")
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to snapshot the file: %w", err)
+	}
+	trackTmpDir(dir)
+	snapshotPath, err := sl.BackupFile(filePath, dir, "")
+	if err != nil {
+		untrackTmpDir(dir)
+		cleanup(dir)
+		return "", "", fmt.Errorf("unable to snapshot the file: %w", err)
+	}
+	return dir, snapshotPath, nil
+}
+
+// restoreBackup puts the --backup copy, or the snapshot taken without one, back
+// after a failed verification, the outcome is added to err for the result and the audit log
+func restoreBackup(flog *slog.Logger, backupPath, filePath string, err error) error {
+	if backupPath == "" {
+		return fmt.Errorf("%w, no --backup to restore", err)
+	}
+	if restoreErr := sl.RestoreBackup(backupPath, filePath); restoreErr != nil {
+		flog.Error("unable to restore backup", "backupPath", backupPath, "error", restoreErr)
+		return fmt.Errorf("%w, restoring the backup failed: %v", err, restoreErr)
+	}
+	flog.Warn("restored backup after failed verification", "backupPath", backupPath)
+	return fmt.Errorf("%w, restored from backup", err)
+}
+
+// allowedParts are the parts a write may change besides OPC bookkeeping
+func allowedParts(fl *sl.Result) []string {
+	parts := []string{sl.LabelInfoPart}
+	for _, change := range fl.Sanitized {
+		// "rewrite <part>" or "remove <part>"
+		parts = append(parts, change[strings.Index(change, " ")+1:])
+	}
+	return parts
+}
+
+// acquireLock holds the run-level lock for path until exit
+func acquireLock(path string) {
+	if forceBreakLock {
+		warn("breaking existing lock", "path", sl.LockPath(path))
+	}
+	l, err := sl.AcquireLock(path, runId, lockWait, forceBreakLock)
+	if err != nil {
+		exitError(err)
+	}
+	logger.Debug("lock acquired", "path", l.Path)
+	atExit = append(atExit, func() {
+		if err := l.Release(); err != nil {
+			warn("unable to release lock", "path", l.Path, "error", err)
+		}
+	})
+}
+
+// retry fn while the file is locked by another process
+func retry(flog *slog.Logger, fn func() error) error {
+	return sl.Retry(sl.RetryOptions{
+		Retries: retries,
+		Delay:   retryDelay,
+		OnRetry: func(attempt int, err error) {
+			flog.Warn("file locked, retrying", "attempt", attempt, "error", err)
+		},
+	}, fn)
+}
+
+func audit(flog *slog.Logger, record sl.AuditRecord, err error) {
+	if auditLog == "" {
+		return
+	}
+	record.DryRun = dryrun
+	if err != nil {
+		record.Result = "error"
+		record.Error = err.Error()
+	} else if dryrun {
+		record.Result = "dry-run"
+	} else {
+		record.Result = "success"
+	}
+	flog.Debug("audit", "auditLog", auditLog, "result", record.Result)
+	// a log that can't be rotated or pruned keeps receiving records
+	auditPrune.Do(func() {
+		removed, err := sl.PruneAuditLogs(auditLog, auditRotation.Retention)
+		if err != nil {
+			warn("unable to remove expired audit logs", "auditLog", auditLog, "error", err)
+		}
+		for _, path := range removed {
+			logger.Info("removed expired audit log", "path", path)
+		}
+	})
+	if rotated, err := sl.RotateAuditLog(auditLog, auditRotation); err != nil {
+		warn("unable to rotate audit log", "auditLog", auditLog, "error", err)
+	} else if rotated != "" {
+		logger.Info("audit log rotated", "auditLog", auditLog, "rotated", rotated)
+	}
+	if err := sl.AppendAuditRecord(auditLog, record); err != nil {
+		exitError(err)
+	}
+}
+
+func writeManifest(m *sl.Manifest) error {
+	if manifestHmacKey != "" {
+		key, err := os.ReadFile(manifestHmacKey)
+		if err != nil {
+			return err
+		}
+		if err := m.SignHMAC(key); err != nil {
+			return err
+		}
+	} else if manifestKey != "" {
+		keyPEM, err := os.ReadFile(manifestKey)
+		if err != nil {
+			return err
+		}
+		var certPEM []byte
+		if manifestCert != "" {
+			certPEM, err = os.ReadFile(manifestCert)
+			if err != nil {
+				return err
+			}
+		}
+		if err := m.SignX509(keyPEM, certPEM); err != nil {
+			return err
+		}
+	}
+	logger.Info("manifest", "path", manifestPath, "entries", len(m.Entries))
+	return m.Write(manifestPath)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// --pprof and --stats-interval diagnose long running scans, workers and coordinators
+var pprofAddr string
+var statsInterval time.Duration
+
+// currentFile is the file being processed, logged with runtime stats
+var currentFile atomic.Value
+
+// startDiagnostics serves pprof and health endpoints and logs runtime stats in
+// the background, --pprof and --health may share an address
+func startDiagnostics() {
+	muxes := map[string]*http.ServeMux{}
+	mux := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if pprofAddr != "" {
+		m := mux(pprofAddr)
+		m.HandleFunc("/debug/pprof/", pprof.Index)
+		m.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		m.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		m.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		m.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	if healthAddr != "" {
+		for _, path := range healthPaths {
+			mux(healthAddr).HandleFunc(path, serveHealth)
+		}
+	}
+	for addr, m := range muxes {
+		go func(addr string, m *http.ServeMux) {
+			if err := http.ListenAndServe(addr, m); err != nil {
+				warn("diagnostics server stopped", "address", addr, "error", err)
+			}
+		}(addr, m)
+		logger.Info("diagnostics listening", "address", addr)
+	}
+	if statsInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(statsInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				logRuntimeStats()
+			}
+		}()
+	}
+}
+
+func logRuntimeStats() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	file, _ := currentFile.Load().(string)
+	logger.Info("runtime stats",
+		"goroutines", runtime.NumGoroutine(),
+		"heapAlloc", m.HeapAlloc,
+		"heapInuse", m.HeapInuse,
+		"heapObjects", m.HeapObjects,
+		"sys", m.Sys,
+		"numGC", m.NumGC,
+		"gcPauseTotalMs", m.PauseTotalNs/uint64(time.Millisecond),
+		"file", file,
+	)
+}
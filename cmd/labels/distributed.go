@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+// coordinator and worker flags, both sides are given the same --cluster-token (or LABELS_CLUSTER_TOKEN)
+var listenAddr, joinURL, workerName, clusterToken string
+var leaseTimeout time.Duration
+
+// how often idle workers ask for a shard and busy workers report progress
+const workerPoll = 5 * time.Second
+const workerBatch = 200
+
+func init() {
+	coordinatorFlags := flag.NewFlagSet("coordinator", flag.ContinueOnError)
+	coordinatorFlags.StringVar(&listenAddr, "listen", ":8700", "address workers connect to")
+	coordinatorFlags.DurationVar(&leaseTimeout, "lease", 10*time.Minute, "requeue a shard when its worker has not reported for this long")
+	coordinatorFlags.StringVar(&clusterToken, "cluster-token", "", "token workers must present")
+	addCommand(&command{
+		name:     "coordinator",
+		args:     []string{"path"},
+		variadic: true,
+		summary:  "shard the directory subtrees of the provided paths across workers and merge their results",
+		examples: []string{
+			`labels.exe coordinator "\\server\share" --recursive --summary --json --output-file inventory.json`,
+		},
+		run: runCoordinator,
+	}, coordinatorFlags, scanFlags)
+
+	workerFlags := flag.NewFlagSet("worker", flag.ContinueOnError)
+	workerFlags.StringVar(&joinURL, "join", "", "URL of the coordinator, e.g. http://scan01:8700")
+	workerFlags.StringVar(&workerName, "name", "", "name reported to the coordinator (default host name and process ID)")
+	workerFlags.StringVar(&clusterToken, "cluster-token", "", "token presented to the coordinator")
+	addCommand(&command{
+		name:    "worker",
+		summary: "scan shards handed out by a coordinator until every shard is done",
+		examples: []string{
+			`labels.exe worker --join http://scan01:8700`,
+		},
+		run: runWorker,
+	}, workerFlags, scanFlags)
+}
+
+// distribute replaces the file loop of scanFiles, emit is called on the scanning
+// goroutine with every result received from workers
+var distribute func(emit func(root string, fl sl.Result))
+
+// shard is a directory subtree scanned by one worker, Path is the root itself
+// without recursion for the files directly in it
+type shard struct {
+	Id         int      `json:"id"`
+	Root       string   `json:"root"`
+	Path       string   `json:"path"`
+	Recursive  bool     `json:"recursive"`
+	Extensions []string `json:"extensions"`
+}
+
+// shardReport is posted by workers, with Done the shard is complete, Seq numbers
+// the reports of a shard so a report retried after a timeout is only merged once
+type shardReport struct {
+	Worker  string      `json:"worker"`
+	Seq     int         `json:"seq"`
+	Results []sl.Result `json:"results"`
+	Done    bool        `json:"done"`
+}
+
+type shardLease struct {
+	shard    shard
+	worker   string
+	deadline time.Time
+	seq      int // last report merged
+	results  []sl.Result
+}
+
+// coordinator hands shards out, results of a shard are only merged once it is done
+// so the partial results of a failed worker are discarded when the shard is requeued
+type coordinator struct {
+	mu        sync.Mutex
+	pending   []shard
+	leases    map[int]*shardLease
+	remaining int            // shards whose results were not merged yet
+	finished  map[int]string // workers of completed shards, to acknowledge retried final reports
+	done      chan shardLease
+	stopped   chan struct{} // closed when merge returns, reports no longer wait for it
+}
+
+func newCoordinator(shards []shard) *coordinator {
+	return &coordinator{
+		pending:   shards,
+		leases:    map[int]*shardLease{},
+		remaining: len(shards),
+		finished:  map[int]string{},
+		done:      make(chan shardLease),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// buildShards splits each root into its own files and, with --recursive, one
+// shard per subdirectory
+func buildShards(roots []string, extensions []string) []shard {
+	var shards []shard
+	add := func(root, path string, recursive bool) {
+		shards = append(shards, shard{Id: len(shards) + 1, Root: root, Path: path, Recursive: recursive, Extensions: extensions})
+	}
+	for _, root := range roots {
+		info, err := os.Stat(sl.LongPath(root))
+		if err != nil {
+			exitError(err)
+		}
+		add(root, root, false)
+		if !info.IsDir() || !recurse {
+			continue
+		}
+		entries, err := os.ReadDir(sl.LongPath(root))
+		if err != nil {
+			exitError(err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				add(root, sl.JoinPath(root, e.Name()), true)
+			}
+		}
+	}
+	return shards
+}
+
+func runCoordinator(args []string) {
+	extensions := prepareScan()
+	shards := buildShards(args, extensions)
+	c := newCoordinator(shards)
+	health.queue.Store(int64(len(shards)))
+	server := &http.Server{Addr: listenAddr, Handler: c}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			exitError(err)
+		}
+	}()
+	logger.Info("coordinator listening", "address", listenAddr, "shards", len(shards))
+
+	roots := make([]scanRoot, len(args))
+	for i, path := range args {
+		roots[i] = scanRoot{path: path}
+	}
+	distribute = func(emit func(root string, fl sl.Result)) {
+		c.merge(emit)
+		if !cancelled.Load() {
+			// answer the next poll of idle workers before closing
+			time.Sleep(workerPoll)
+		}
+		// don't wait on workers that keep a connection open
+		ctx, cancel := context.WithTimeout(context.Background(), workerPoll)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+	scanFiles("coordinator", roots, nil)
+}
+
+// merge emits the results of completed shards until every shard is done,
+// leases of workers that stopped reporting are requeued
+func (c *coordinator) merge(emit func(root string, fl sl.Result)) {
+	defer close(c.stopped)
+	ticker := time.NewTicker(workerPoll)
+	defer ticker.Stop()
+	for {
+		c.mu.Lock()
+		remaining := c.remaining
+		c.mu.Unlock()
+		if remaining == 0 || cancelled.Load() {
+			return
+		}
+		applyConfigReload()
+		select {
+		case l := <-c.done:
+			c.mu.Lock()
+			c.remaining--
+			health.queue.Store(int64(c.remaining))
+			c.mu.Unlock()
+			health.success()
+			logger.Info("shard done", "shard", l.shard.Id, "path", l.shard.Path, "worker", l.worker, "files", len(l.results))
+			for _, fl := range l.results {
+				emit(l.shard.Root, fl)
+			}
+		case <-ticker.C:
+			c.mu.Lock()
+			c.requeueExpired()
+			c.mu.Unlock()
+		}
+	}
+}
+
+// requeueExpired must be called with mu held
+func (c *coordinator) requeueExpired() {
+	for id, l := range c.leases {
+		if time.Now().After(l.deadline) {
+			warn("worker stopped reporting, shard requeued", "shard", id, "path", l.shard.Path, "worker", l.worker)
+			delete(c.leases, id)
+			c.pending = append(c.pending, l.shard)
+		}
+	}
+}
+
+func (c *coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && isHealthPath(r.URL.Path) {
+		serveHealth(w, r)
+		return
+	}
+	if clusterToken != "" && r.Header.Get("Authorization") != "Bearer "+clusterToken {
+		http.Error(w, "invalid cluster token", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var report shardReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case r.URL.Path == "/next":
+		c.next(w, report.Worker)
+	case strings.HasPrefix(r.URL.Path, "/shards/"):
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/shards/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		c.report(w, id, report)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// next leases a pending shard, 202 asks the worker to poll again while shards are
+// leased to others (they may still fail), 204 tells it every shard is done
+func (c *coordinator) next(w http.ResponseWriter, worker string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requeueExpired()
+	if len(c.pending) == 0 {
+		if c.remaining == 0 {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		return
+	}
+	s := c.pending[0]
+	c.pending = c.pending[1:]
+	c.leases[s.Id] = &shardLease{shard: s, worker: worker, deadline: time.Now().Add(leaseTimeout)}
+	logger.Info("shard leased", "shard", s.Id, "path", s.Path, "worker", worker)
+	json.NewEncoder(w).Encode(s)
+}
+
+// report adds results to a lease and extends it, 409 tells a worker whose lease
+// expired to abandon the shard, reports already merged only extend the lease
+func (c *coordinator) report(w http.ResponseWriter, id int, report shardReport) {
+	c.mu.Lock()
+	l, ok := c.leases[id]
+	if !ok || l.worker != report.Worker {
+		retried := !ok && report.Done && c.finished[id] == report.Worker
+		c.mu.Unlock()
+		if retried {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "shard is not leased to this worker", http.StatusConflict)
+		return
+	}
+	if report.Seq > l.seq {
+		l.results = append(l.results, report.Results...)
+		l.seq = report.Seq
+	} else {
+		logger.Debug("report already merged", "shard", id, "worker", report.Worker, "seq", report.Seq)
+	}
+	l.deadline = time.Now().Add(leaseTimeout)
+	if report.Done {
+		delete(c.leases, id)
+		c.finished[id] = report.Worker
+	}
+	c.mu.Unlock()
+	if report.Done {
+		select {
+		case c.done <- *l:
+		case <-c.stopped:
+			// cancelled, nobody merges results anymore
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errLeaseLost = errors.New("shard lease expired, another worker will scan it")
+
+// runWorker scans shards until the coordinator reports every shard done, worker
+// results are read only and printed by the coordinator
+func runWorker(args []string) {
+	if joinURL == "" {
+		printCommandUsage(findCommand("worker"), "Error: --join is required")
+		exit(sl.ExitUsage)
+	}
+	prepareScan()
+	if workerName == "" {
+		host, _ := os.Hostname()
+		workerName = host + ":" + strconv.Itoa(os.Getpid())
+	}
+	joinURL = strings.TrimSuffix(joinURL, "/")
+	failures := 0
+	for !cancelled.Load() {
+		var s shard
+		status, err := workerPost("/next", shardReport{Worker: workerName}, &s)
+		health.check("coordinator", err)
+		if status == http.StatusUnauthorized {
+			exitError(fmt.Errorf("the coordinator refused --cluster-token: %w", err))
+		}
+		if err != nil {
+			// the coordinator may be restarting or already gone
+			failures++
+			if failures > 5 {
+				exitError(fmt.Errorf("unable to reach the coordinator: %w", err))
+			}
+			warn("unable to reach the coordinator, retrying", "error", err)
+			time.Sleep(workerPoll)
+			continue
+		}
+		failures = 0
+		switch status {
+		case http.StatusNoContent:
+			logger.Info("every shard is done")
+			return
+		case http.StatusAccepted:
+			time.Sleep(workerPoll)
+			continue
+		}
+		if err := scanShard(s); err != nil {
+			warn("shard abandoned", "shard", s.Id, "path", s.Path, "error", err)
+		}
+	}
+}
+
+// scanShard posts results in batches, a heartbeat keeps the lease while a single
+// large document takes longer than --lease, a report that failed is resent with
+// the same Seq and results before the results scanned since
+func scanShard(s shard) error {
+	logger.Info("scanning shard", "shard", s.Id, "path", s.Path)
+	recurse = s.Recursive
+	shardPath := fmt.Sprintf("/shards/%d", s.Id)
+	var mu sync.Mutex
+	var batch, unsent []sl.Result
+	seq := 0
+	retry := false
+	var lost error
+	send := func(done bool) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for {
+			if lost != nil {
+				return lost
+			}
+			if !retry {
+				seq++
+				unsent, batch = batch, nil
+			}
+			last := done && len(batch) == 0
+			status, err := workerPost(shardPath, shardReport{Worker: workerName, Seq: seq, Results: unsent, Done: last}, nil)
+			health.check("coordinator", err)
+			if err == nil && status == http.StatusConflict {
+				err = errLeaseLost
+				lost = err
+			}
+			if err != nil {
+				retry = true
+				return err
+			}
+			retry = false
+			unsent = nil
+			if last || !done {
+				return nil
+			}
+		}
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(workerPoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := send(false); err != nil {
+					logger.Debug("heartbeat failed", "error", err)
+				}
+			}
+		}
+	}()
+
+	manifest := sl.NewManifest()
+	filePaths := listFiles(s.Path, s.Extensions)
+	health.queue.Store(int64(len(filePaths)))
+	for _, filePath := range filePaths {
+		if cancelled.Load() {
+			return errors.New("cancelled")
+		}
+		fl := processFile("get", filePath, nil, manifest)
+		health.queue.Add(-1)
+		if fl.Error == "" {
+			health.success()
+		}
+		nested := nestedResults(&fl, nil)
+		mu.Lock()
+		batch = append(batch, fl)
+		batch = append(batch, nested...)
+		full := len(batch) >= workerBatch
+		lostLease := lost
+		mu.Unlock()
+		if lostLease != nil {
+			return lostLease
+		}
+		if full {
+			if err := send(false); errors.Is(err, errLeaseLost) {
+				return err
+			} else if err != nil {
+				logger.Debug("report failed, resent with the next one", "error", err)
+			}
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		err := send(true)
+		if err == nil || errors.Is(err, errLeaseLost) || attempt == 5 {
+			return err
+		}
+		warn("unable to report the shard, retrying", "shard", s.Id, "error", err)
+		time.Sleep(workerPoll)
+	}
+}
+
+// workerPost sends a report to the coordinator and decodes a shard from 200 responses
+func workerPost(path string, report shardReport, out *shard) (int, error) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, joinURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if clusterToken != "" {
+		req.Header.Set("Authorization", "Bearer "+clusterToken)
+	}
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if out != nil {
+			err = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode, err
+	case http.StatusAccepted, http.StatusNoContent, http.StatusConflict:
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, fmt.Errorf("POST %s: %s", path, resp.Status)
+}
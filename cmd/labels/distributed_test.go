@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// post sends a worker report to the coordinator and returns the response
+func post(t *testing.T, c *coordinator, path string, report shardReport) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	return w
+}
+
+// lease asks for the next shard as worker and checks the status
+func lease(t *testing.T, c *coordinator, worker string, wantStatus int) shard {
+	t.Helper()
+	w := post(t, c, "/next", shardReport{Worker: worker})
+	if w.Code != wantStatus {
+		t.Fatalf("/next for %s = %d, want %d", worker, w.Code, wantStatus)
+	}
+	var s shard
+	if wantStatus == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// report posts results of a shard and checks the status
+func report(t *testing.T, c *coordinator, id int, r shardReport, wantStatus int) {
+	t.Helper()
+	if w := post(t, c, "/shards/"+strconv.Itoa(id), r); w.Code != wantStatus {
+		t.Fatalf("report of shard %d by %s seq %d = %d, want %d", id, r.Worker, r.Seq, w.Code, wantStatus)
+	}
+}
+
+func results(paths ...string) []sl.Result {
+	var rs []sl.Result
+	for _, path := range paths {
+		rs = append(rs, sl.Result{FilePath: path})
+	}
+	return rs
+}
+
+// startMerge runs merge like the coordinator command, wait returns the merged paths
+func startMerge(c *coordinator) (wait func() []string) {
+	var mu sync.Mutex
+	var merged []string
+	finished := make(chan struct{})
+	go func() {
+		c.merge(func(root string, fl sl.Result) {
+			mu.Lock()
+			merged = append(merged, fl.FilePath)
+			mu.Unlock()
+		})
+		close(finished)
+	}()
+	return func() []string {
+		select {
+		case <-finished:
+		case <-time.After(10 * time.Second):
+			panic("merge did not return")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return merged
+	}
+}
+
+func testShards() []shard {
+	return []shard{{Id: 1, Root: "root", Path: "root"}, {Id: 2, Root: "root", Path: "root/sub", Recursive: true}}
+}
+
+func TestCoordinatorLeases(t *testing.T) {
+	c := newCoordinator(testShards())
+	wait := startMerge(c)
+
+	a := lease(t, c, "a", http.StatusOK)
+	b := lease(t, c, "b", http.StatusOK)
+	if a.Id != 1 || b.Id != 2 {
+		t.Fatalf("leased shards %d and %d, want 1 and 2", a.Id, b.Id)
+	}
+	// every shard is leased but none done
+	lease(t, c, "c", http.StatusAccepted)
+	// only the worker holding the lease may report
+	report(t, c, a.Id, shardReport{Worker: "b", Seq: 1, Results: results("root/x.docx")}, http.StatusConflict)
+	report(t, c, 99, shardReport{Worker: "a", Seq: 1}, http.StatusConflict)
+
+	report(t, c, a.Id, shardReport{Worker: "a", Seq: 1, Results: results("root/a1.docx")}, http.StatusNoContent)
+	// retried after a timeout the coordinator did not see
+	report(t, c, a.Id, shardReport{Worker: "a", Seq: 1, Results: results("root/a1.docx")}, http.StatusNoContent)
+	report(t, c, a.Id, shardReport{Worker: "a", Seq: 2, Results: results("root/a2.docx"), Done: true}, http.StatusNoContent)
+	// the final report retried is acknowledged, not a lost lease
+	report(t, c, a.Id, shardReport{Worker: "a", Seq: 2, Results: results("root/a2.docx"), Done: true}, http.StatusNoContent)
+	report(t, c, a.Id, shardReport{Worker: "b", Seq: 3, Done: true}, http.StatusConflict)
+
+	lease(t, c, "a", http.StatusAccepted)
+	report(t, c, b.Id, shardReport{Worker: "b", Seq: 1, Results: results("root/sub/b1.docx"), Done: true}, http.StatusNoContent)
+	merged := wait()
+	lease(t, c, "a", http.StatusNoContent)
+
+	want := []string{"root/a1.docx", "root/a2.docx", "root/sub/b1.docx"}
+	if len(merged) != len(want) {
+		t.Fatalf("merged %q, want %q", merged, want)
+	}
+	for i := range want {
+		if merged[i] != want[i] {
+			t.Fatalf("merged %q, want %q", merged, want)
+		}
+	}
+}
+
+func TestCoordinatorRequeue(t *testing.T) {
+	c := newCoordinator(testShards()[:1])
+	wait := startMerge(c)
+
+	a := lease(t, c, "a", http.StatusOK)
+	report(t, c, a.Id, shardReport{Worker: "a", Seq: 1, Results: results("root/partial.docx")}, http.StatusNoContent)
+	// a stops reporting
+	c.mu.Lock()
+	c.leases[a.Id].deadline = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+
+	b := lease(t, c, "b", http.StatusOK)
+	if b.Id != a.Id {
+		t.Fatalf("requeued shard %d leased as %d", a.Id, b.Id)
+	}
+	// a resumes after its lease expired and must abandon the shard
+	report(t, c, a.Id, shardReport{Worker: "a", Seq: 2, Results: results("root/late.docx"), Done: true}, http.StatusConflict)
+	report(t, c, b.Id, shardReport{Worker: "b", Seq: 1, Results: results("root/partial.docx", "root/late.docx"), Done: true}, http.StatusNoContent)
+
+	merged := wait()
+	if len(merged) != 2 || merged[0] != "root/partial.docx" || merged[1] != "root/late.docx" {
+		t.Fatalf("merged %q, want only the results of the second worker", merged)
+	}
+}
+
+func TestCoordinatorReportAfterCancel(t *testing.T) {
+	c := newCoordinator(testShards()[:1])
+	a := lease(t, c, "a", http.StatusOK)
+	// merge returned on Ctrl-C
+	close(c.stopped)
+	returned := make(chan int)
+	go func() {
+		returned <- post(t, c, "/shards/"+strconv.Itoa(a.Id), shardReport{Worker: "a", Seq: 1, Done: true}).Code
+	}()
+	select {
+	case code := <-returned:
+		if code != http.StatusNoContent {
+			t.Fatalf("final report after cancel = %d, want %d", code, http.StatusNoContent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("final report blocked after merge returned")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// --min-free-space is global, /readyz checks it too
+var minFreeSpace int64
+
+func init() {
+	addCommand(&command{
+		name:    "doctor",
+		summary: "check the environment (temp dir, free space, long paths, config, sample relabel) for support triage",
+		examples: []string{
+			`labels.exe doctor --tmp-dir "path\to\tmp" --config "path\to\config.json"`,
+		},
+		run: runDoctor,
+	})
+}
+
+// check results
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type doctorCheck struct {
+	name string
+	run  func() (string, string) // status and detail
+}
+
+func runDoctor(args []string) {
+	checks := []doctorCheck{
+		{"tmp-dir", checkTmpDir},
+		{"free-space", checkFreeSpace},
+		{"long-paths", checkLongPaths},
+		{"config", checkConfig},
+		{"round-trip", checkRoundTrip},
+		{"graph", func() (string, string) {
+			return checkSkip, "labels.exe reads labels from the documents and does not call Microsoft Graph"
+		}},
+		{"offline", func() (string, string) {
+			if !offline {
+				return checkSkip, "--offline not set"
+			}
+			return checkPass, "no plugins or mail configured, nothing can access the network"
+		}},
+	}
+	failed := 0
+	for _, check := range checks {
+		status, detail := check.run()
+		logger.Debug("doctor", "check", check.name, "status", status)
+		color := colorGreen
+		switch status {
+		case checkFail:
+			color = colorRed
+			failed++
+		case checkSkip:
+			color = colorYellow
+		}
+		fmt.Fprintln(out, colorize(color, status)+delimiter+check.name+delimiter+detail)
+	}
+	if failed > 0 {
+		exit(sl.ExitFatal)
+	}
+}
+
+func checkTmpDir() (string, string) {
+	dir, err := os.MkdirTemp(tmpDir, "_doctor.*")
+	if err != nil {
+		return checkFail, err.Error()
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "write"), []byte("ok"), 0644); err != nil {
+		return checkFail, err.Error()
+	}
+	abs, _ := filepath.Abs(tmpDir)
+	return checkPass, abs + " is writable"
+}
+
+func checkFreeSpace() (string, string) {
+	free, err := sl.FreeSpace(tmpDir)
+	if err != nil {
+		return checkSkip, err.Error()
+	}
+	detail := fmt.Sprintf("%d MiB available", free>>20)
+	if free < uint64(minFreeSpace) {
+		return checkFail, detail + fmt.Sprintf(", below --min-free-space %d MiB", minFreeSpace>>20)
+	}
+	return checkPass, detail
+}
+
+// checkLongPaths writes a file whose path exceeds MAX_PATH (260 characters)
+func checkLongPaths() (string, string) {
+	dir, err := os.MkdirTemp(tmpDir, "_doctor.*")
+	if err != nil {
+		return checkFail, err.Error()
+	}
+	defer os.RemoveAll(sl.LongPath(dir))
+	long := dir
+	for len(long) <= 300 {
+		long = filepath.Join(long, strings.Repeat("d", 50))
+	}
+	if err := os.MkdirAll(sl.LongPath(long), 0755); err != nil {
+		return checkFail, err.Error()
+	}
+	filePath := filepath.Join(long, "long.docx")
+	if err := os.WriteFile(sl.LongPath(filePath), []byte("ok"), 0644); err != nil {
+		return checkFail, err.Error()
+	}
+	return checkPass, fmt.Sprintf("wrote a %d character path on %s", len(filePath), runtime.GOOS)
+}
+
+// checkConfig reports where flag defaults and ID mappings come from
+func checkConfig() (string, string) {
+	var env []string
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, envPrefix) {
+			env = append(env, name)
+		}
+	}
+	detail := "no config file"
+	if config != "" {
+		data, err := os.ReadFile(config)
+		if err != nil {
+			return checkFail, err.Error()
+		}
+		var cfg LabelsConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return checkFail, config + ": " + err.Error()
+		}
+		detail = fmt.Sprintf("%s: %d labels, %d tenants, %d flag defaults", config, len(cfg.Labels), len(cfg.Tenants), len(cfg.Flags))
+	} else if value := os.Getenv(envName("config")); value != "" {
+		return checkFail, "unable to read " + envName("config") + "=" + value
+	}
+	if len(env) > 0 {
+		detail += ", environment: " + strings.Join(env, " ")
+	}
+	return checkPass, detail
+}
+
+// checkRoundTrip labels a generated document in memory and through extraction and reads it back
+func checkRoundTrip() (string, string) {
+	dir, err := os.MkdirTemp(tmpDir, "_doctor.*")
+	if err != nil {
+		return checkFail, err.Error()
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "sample.docx")
+	if err := writeSampleDocument(filePath); err != nil {
+		return checkFail, err.Error()
+	}
+	labels := sl.Labels{Labels: []sl.Label{newLabel("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-000000000002")}}
+	for _, h := range []*sl.OOXMLHandler{
+		{MemoryThreshold: sl.DefaultMemoryThreshold},
+		{MemoryThreshold: 0, TmpDir: dir, Unzip: sl.DefaultUnzipOptions},
+	} {
+		mode := "in memory"
+		if h.MemoryThreshold == 0 {
+			mode = "extracted"
+		}
+		if err := h.WriteLabels(filePath, labels); err != nil {
+			return checkFail, mode + ": " + err.Error()
+		}
+		read, ok, err := h.ReadLabels(filePath)
+		if err != nil {
+			return checkFail, mode + ": " + err.Error()
+		}
+		if !ok || !sl.SameLabels(read.Labels, labels.Labels) {
+			return checkFail, mode + ": labels read back differ from the labels written"
+		}
+	}
+	return checkPass, "relabeled a sample document in memory and extracted"
+}
+
+// writeSampleDocument creates the smallest package Word would open
+func writeSampleDocument(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	parts := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`,
+		"_rels/.rels":         `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships>`,
+		"word/document.xml":   `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p/></w:body></w:document>`,
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml"} {
+		pw, err := w.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := pw.Write([]byte(parts[name])); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
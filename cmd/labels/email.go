@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// attachmentResults reads the labels of documents attached to an email, reported
+// as mail.msg!/attachment.docx, attachments are never modified
+func attachmentResults(fl sl.Result, update updateFunc) []sl.Result {
+	if fl.Handler != "email" || fl.Error != "" {
+		return nil
+	}
+	email, err := sl.ReadEmail(fl.FilePath)
+	if err != nil {
+		logger.Warn("unable to read attachments", "file", fl.FilePath, "error", err)
+		return nil
+	}
+	var results []sl.Result
+	for _, a := range email.Attachments {
+		if r, ok := nestedResult(fl, a.Name, a.Data, update); ok {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// nestedResult reads the labels of a document held by fl, an attachment or an
+// embedded document, documents no handler reads are left out
+func nestedResult(fl sl.Result, name string, data []byte, update updateFunc) (sl.Result, bool) {
+	r := sl.Result{
+		FilePath: fl.FilePath + "!/" + name,
+		Parent:   fl.FilePath,
+		Labels:   []sl.Label{},
+		Bytes:    int64(len(data)),
+		Modified: fl.Modified,
+	}
+	flog := logger.With("file", r.FilePath)
+	category, err := readBytesLabels(flog, &r, name, data)
+	// pictures, PDFs and the like are no documents labels.exe reads
+	if errors.Is(err, sl.ErrUnsupportedFormat) && sl.ExpectedContainer(filepath.Ext(name)) != sl.ContainerZip {
+		flog.Debug("nested file skipped", "error", err)
+		return r, false
+	}
+	if err != nil {
+		flog.Error("file error", "category", category, "error", err)
+		if failFast {
+			exitError(err)
+		}
+		r.Error = err.Error()
+		r.ErrorCategory = category
+		return r, true
+	}
+	if update != nil {
+		fileWarning(flog, &r, "nested documents are not relabeled")
+	}
+	return r, true
+}
+
+// readBytesLabels reads the labels of a document held in memory into fl and applies
+// the policies, the error category is returned with errors
+func readBytesLabels(flog *slog.Logger, fl *sl.Result, name string, data []byte) (string, error) {
+	handler, labels, exists, err := sl.ReadLabelsBytes(name, data)
+	fl.Handler = handler
+	if err != nil {
+		if errors.Is(err, sl.ErrUnsupportedFormat) {
+			fl.Reason = sl.FailureReasonBytes(data, err)
+			return errFormat, err
+		}
+		if category, err := checkXMLError(flog, fl, err); err != nil {
+			if category == errExtract {
+				fl.Reason = sl.FailureReasonBytes(data, err)
+			}
+			return category, err
+		}
+	}
+	fl.LabelInfo = exists
+	if labels.Labels != nil {
+		fl.Labels = labels.Labels
+	}
+	checkDuplicates(flog, fl)
+	fl.ForbiddenLabels = sl.FindForbiddenLabels(fl.Labels, denyLabels, denyTenants)
+	fl.TenantMismatch = sl.FindTenantMismatches(fl.Labels, expectedTenant)
+	return "", nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+var showEmbedded bool
+
+// embeddedResults reads the labels of documents embedded in a document with
+// --embedded, reported as report.docx!/word/embeddings/sheet.xlsx
+func embeddedResults(fl sl.Result, update updateFunc) []sl.Result {
+	if !showEmbedded || fl.Handler != "ooxml" || fl.Error != "" {
+		return nil
+	}
+	objects, err := sl.EmbeddedObjects(fl.FilePath)
+	if err != nil {
+		logger.Warn("unable to read embedded documents", "file", fl.FilePath, "error", err)
+		return nil
+	}
+	var results []sl.Result
+	for _, object := range objects {
+		if r, ok := nestedResult(fl, object.Path, object.Data, update); ok {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// nestedResults returns the attachments, archive entries and embedded documents of fl, warning on fl
+// when they carry labels it does not, e.g. an unlabeled wrapper around a confidential sheet
+func nestedResults(fl *sl.Result, update updateFunc) []sl.Result {
+	nested := append(attachmentResults(*fl, update), archiveResults(*fl, update)...)
+	nested = append(nested, embeddedResults(*fl, update)...)
+	var other []string
+	seen := map[string]bool{}
+	for _, r := range nested {
+		for _, label := range r.Labels {
+			id := sl.NormalizeId(label.Id)
+			if !seen[id] && !hasLabel(fl.Labels, id) {
+				other = append(other, withName(id, labelConfig.Labels))
+			}
+			seen[id] = true
+		}
+	}
+	// archives carry no label of their own
+	if len(other) > 0 && fl.Handler != "tar" {
+		fileWarning(logger.With("file", fl.FilePath), fl, "nested documents carry labels the file does not: "+strings.Join(other, ", "))
+	}
+	return nested
+}
+
+func hasLabel(labels []sl.Label, id string) bool {
+	for _, label := range labels {
+		if sl.NormalizeId(label.Id) == id {
+			return true
+		}
+	}
+	return false
+}
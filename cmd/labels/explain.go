@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+func init() {
+	explainFlags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	explainFlags.BoolVar(&showJson, "json", false, "display the explanation as json")
+	addCommand(&command{
+		name:    "explain",
+		args:    []string{"label"},
+		summary: "describe a label GUID, a label element of LabelInfo.xml or a line of get output",
+		examples: []string{
+			`labels.exe explain 3de9faa6-9fe1-49b3-9a08-227a296b54a6 --config config.json`,
+			`labels.exe explain "true ./123.xlsx 1 [3de9faa6-9fe1-49b3-9a08-227a296b54a6 f49dfc2f-b2b1-4605-accd-09d3ac0089a8]"`,
+		},
+		run: runExplain,
+	}, explainFlags)
+}
+
+// LabelExplanation is everything known about a single label entry,
+// fields not present in the input are left empty
+type LabelExplanation struct {
+	Id          string   `json:",omitempty"`
+	Name        string   `json:",omitempty"`
+	SiteId      string   `json:",omitempty"`
+	TenantName  string   `json:",omitempty"`
+	Enabled     string   `json:",omitempty"`
+	Removed     string   `json:",omitempty"`
+	Method      string   `json:",omitempty"`
+	ContentBits []string `json:",omitempty"`
+	Extra       []string `json:",omitempty"`
+}
+
+var guidPattern = regexp.MustCompile(`[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`)
+
+func runExplain(args []string) {
+	labels, err := parseExplainInput(args[0])
+	if err != nil {
+		exitError(err)
+	}
+	if len(labels) == 0 {
+		exitError(fmt.Errorf("no label found in %q", args[0]))
+	}
+	var explanations []LabelExplanation
+	for _, label := range labels {
+		explanations = append(explanations, explainLabel(label))
+	}
+	if showJson {
+		data, err := json.MarshalIndent(explanations, "", "  ")
+		if err != nil {
+			exitError(err)
+		}
+		fmt.Fprintln(out, string(data))
+		return
+	}
+	for i, e := range explanations {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		printExplanation(e)
+	}
+}
+
+// parseExplainInput accepts a GUID, one or more <label/> elements or a get output line,
+// label names from --config are resolved back to IDs
+func parseExplainInput(input string) ([]sl.Label, error) {
+	input = strings.TrimSpace(input)
+	if strings.Contains(input, "<") {
+		var list sl.Labels
+		if err := xml.Unmarshal([]byte("<labelList>"+input+"</labelList>"), &list); err != nil {
+			return nil, err
+		}
+		return list.Labels, nil
+	}
+	// true ./123.xlsx 1 [labelId siteId, labelId siteId]
+	if start, end := strings.Index(input, "["), strings.LastIndex(input, "]"); start >= 0 && end > start {
+		var labels []sl.Label
+		for _, pair := range strings.Split(input[start+1:end], ", ") {
+			fields := strings.Fields(pair)
+			if len(fields) != 2 {
+				continue
+			}
+			labels = append(labels, sl.Label{
+				Id:     parseIdList(fields[0], labelConfig.Labels)[0],
+				SiteId: parseIdList(fields[1], labelConfig.Tenants)[0],
+			})
+		}
+		return labels, nil
+	}
+	var labels []sl.Label
+	for _, id := range guidPattern.FindAllString(input, -1) {
+		labels = append(labels, sl.Label{Id: id})
+	}
+	if len(labels) == 0 && input != "" {
+		// a label name from --config
+		if id := parseIdList(input, labelConfig.Labels); len(id) > 0 && id[0] != input {
+			labels = append(labels, sl.Label{Id: id[0]})
+		}
+	}
+	return labels, nil
+}
+
+// configName looks up an ID in a --config mapping regardless of braces and case
+func configName(names map[string]string, id string) string {
+	for key, name := range names {
+		if sl.NormalizeId(key) == sl.NormalizeId(id) {
+			return name
+		}
+	}
+	return ""
+}
+
+func explainLabel(label sl.Label) LabelExplanation {
+	e := LabelExplanation{
+		Id:      sl.NormalizeId(label.Id),
+		Name:    configName(labelConfig.Labels, label.Id),
+		SiteId:  sl.NormalizeId(label.SiteId),
+		Enabled: label.Enabled,
+		Removed: label.Removed,
+		Method:  label.Method,
+	}
+	if label.SiteId != "" {
+		e.TenantName = configName(labelConfig.Tenants, label.SiteId)
+	}
+	// a GUID alone may be a tenant
+	if e.Name == "" && label.SiteId == "" {
+		if name := configName(labelConfig.Tenants, label.Id); name != "" {
+			e.SiteId, e.TenantName, e.Id = e.Id, name, ""
+		}
+	}
+	if label.ContentBits != "" {
+		flags, err := label.ContentBits.Decode()
+		if err != nil {
+			flags = []string{string(label.ContentBits)}
+		}
+		e.ContentBits = flags
+	}
+	for _, key := range extraAttrKeys(label) {
+		e.Extra = append(e.Extra, key+"="+label.Extra[key].Value)
+	}
+	return e
+}
+
+func printExplanation(e LabelExplanation) {
+	unknown := "not in --config"
+	if e.Id != "" {
+		fmt.Fprintln(out, "label: "+e.Id)
+		fmt.Fprintln(out, "  name: "+orDefault(e.Name, unknown))
+	}
+	if e.SiteId != "" {
+		fmt.Fprintln(out, "tenant: "+e.SiteId)
+		fmt.Fprintln(out, "  name: "+orDefault(e.TenantName, unknown))
+	}
+	if e.Enabled != "" {
+		fmt.Fprintln(out, "enabled: "+e.Enabled+" ("+explainFlag(e.Enabled, "label is applied", "label is not applied")+")")
+	}
+	if e.Removed != "" {
+		fmt.Fprintln(out, "removed: "+e.Removed+" ("+explainFlag(e.Removed, "label was removed, kept for history", "label is current")+")")
+	}
+	switch e.Method {
+	case "Standard":
+		fmt.Fprintln(out, "method: Standard (applied by default or automatic labeling policy)")
+	case "Privileged":
+		fmt.Fprintln(out, "method: Privileged (chosen by a user or administrator, overrides automatic labeling)")
+	case "":
+	default:
+		fmt.Fprintln(out, "method: "+e.Method+" (unknown method)")
+	}
+	if e.ContentBits != nil {
+		marks := strings.Join(e.ContentBits, ", ")
+		if len(e.ContentBits) == 0 {
+			marks = "no content marking or encryption"
+		}
+		fmt.Fprintln(out, "contentBits: "+marks)
+	}
+	for _, extra := range e.Extra {
+		fmt.Fprintln(out, "attribute: "+extra)
+	}
+}
+
+func explainFlag(value, set, unset string) string {
+	switch strings.ToLower(value) {
+	case "1", "true":
+		return set
+	case "0", "false":
+		return unset
+	}
+	return "unknown value"
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
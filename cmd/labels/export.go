@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+func init() {
+	addCommand(&command{
+		name:    "export",
+		args:    []string{"path", "outDir"},
+		summary: "copy LabelInfo.xml and MSIP custom properties of each document into a mirrored directory with an index.json",
+		examples: []string{
+			`labels.exe export "path\to\dir" "path\to\export" --recursive`,
+		},
+		run: runExport,
+	}, scanFlags)
+}
+
+func runExport(args []string) {
+	root, outDir := args[0], args[1]
+	scanner := sl.NewScanner(prepareScan())
+	scanner.Recursive = recurse
+	filePaths, err := scanner.ListFiles(root)
+	if err != nil {
+		exitError(err)
+	}
+	if len(filePaths) == 0 {
+		if !quiet {
+			fmt.Fprintln(os.Stderr, "No files found")
+		}
+		exit(sl.ExitSuccess)
+	}
+	// a single file is exported under its own name
+	base := root
+	if info, err := os.Stat(sl.LongPath(root)); err == nil && !info.IsDir() {
+		base = filepath.Dir(root)
+	}
+	index := sl.NewExportIndex(root)
+	errored := 0
+	for _, filePath := range filePaths {
+		if cancelled.Load() {
+			break
+		}
+		relPath, err := filepath.Rel(base, filePath)
+		if err != nil {
+			exitError(err)
+		}
+		flog := logger.With("file", filePath)
+		entry, err := sl.ExportDocument(filePath, relPath, outDir)
+		if err != nil {
+			flog.Error("file error", "category", errExtract, "error", err)
+			if failFast {
+				exitError(err)
+			}
+			entry.Error = err.Error()
+			errored++
+		}
+		entry.Labels = resolveNames(entry.Labels)
+		flog.Debug("export", "labels", len(entry.Labels), "properties", len(entry.Properties))
+		index.Entries = append(index.Entries, entry)
+		if showLabeledOnly && len(entry.Labels) == 0 && entry.Error == "" {
+			continue
+		}
+		fmt.Fprintln(out, quotePath(entry.Path)+delimiter+strconv.Itoa(len(entry.Labels))+delimiter+strconv.Itoa(len(entry.Properties))+delimiter+entry.Error)
+	}
+	if err := index.Write(outDir); err != nil {
+		exitError(err)
+	}
+	logger.Info("export", "path", filepath.Join(outDir, sl.ExportIndexName), "entries", len(index.Entries))
+	if cancelled.Load() {
+		warn("run cancelled before all files were exported")
+		exit(sl.ExitCancelled)
+	}
+	if errored > 0 {
+		warn("files could not be exported", "count", errored)
+		exit(sl.ExitFileErrors)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// --fci-property publishes the labels of every scanned file to FSRM
+var fciProperty string
+var fciPublisher *sl.FCIPublisher
+
+func startFCI() error {
+	if fciProperty == "" {
+		return nil
+	}
+	p, err := sl.StartFCIPublisher()
+	if err != nil {
+		return err
+	}
+	fciPublisher = p
+	atExit = append(atExit, func() {
+		if err := fciPublisher.Close(); err != nil {
+			warn("FSRM classification failed", "error", err)
+		}
+	})
+	return nil
+}
+
+// publishClassification sets --fci-property to the label names (or IDs) of the file,
+// "(none)" for unlabeled files, failed files are left alone
+func publishClassification(fl *sl.Result) {
+	if fciPublisher == nil || fl.Error != "" {
+		return
+	}
+	var values []string
+	for _, label := range resolveNames(fl.Labels) {
+		values = append(values, orDefault(label.Name, sl.NormalizeId(label.Id)))
+	}
+	value := strings.Join(values, "; ")
+	if value == "" {
+		value = sl.NoGroupKey
+	}
+	if err := fciPublisher.SetProperties(fl.FilePath, map[string]string{fciProperty: value}); err != nil {
+		fileWarning(logger.With("file", fl.FilePath), fl, "unable to set the FSRM classification property", "property", fciProperty, "error", err)
+	}
+}
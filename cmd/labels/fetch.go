@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// get flags for documents hosted on web servers
+var urlList string
+var urlTimeout time.Duration
+
+// readURLList reads one URL per line, blank lines and # comments are ignored
+func readURLList(listPath string) ([]string, error) {
+	f, err := os.Open(sl.LongPath(listPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// urlHeaders returns the config headers for the host of rawURL, "*" applies to every
+// host and values expand environment variables so tokens stay out of the config
+func urlHeaders(rawURL string) map[string]string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	headers := map[string]string{}
+	// headers for every host first so host specific ones win
+	for _, wildcard := range []bool{true, false} {
+		for host, values := range labelConfig.Headers {
+			if (host == "*") != wildcard || (!wildcard && !strings.EqualFold(host, u.Host) && !strings.EqualFold(host, u.Hostname())) {
+				continue
+			}
+			for name, value := range values {
+				headers[name] = os.ExpandEnv(value)
+			}
+		}
+	}
+	return headers
+}
+
+// processURL downloads a document into memory and reads its labels, URLs are read only
+func processURL(cmd, rawURL string, update updateFunc) sl.Result {
+	fl := sl.Result{FilePath: rawURL, Labels: []sl.Label{}}
+	flog := logger.With("file", rawURL)
+	fail := func(category string, err error) sl.Result {
+		flog.Error("file error", "category", category, "error", err)
+		if failFast {
+			exitError(err)
+		}
+		fl.Error = err.Error()
+		fl.ErrorCategory = category
+		return fl
+	}
+	if update != nil {
+		return fail(errFormat, fmt.Errorf("URLs are read only, %s is not supported", cmd))
+	}
+	data, modified, err := sl.FetchURL(rawURL, sl.FetchOptions{
+		Headers:  urlHeaders(rawURL),
+		MaxBytes: unzipOpts.MaxBytes,
+		Timeout:  urlTimeout,
+	})
+	if err != nil {
+		return fail(errFetch, err)
+	}
+	fl.Bytes = int64(len(data))
+	if !modified.IsZero() {
+		fl.Modified = modified.UTC().Format(time.RFC3339)
+	}
+	name := "document"
+	if u, err := url.Parse(rawURL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	if category, err := readBytesLabels(flog, &fl, name, data); err != nil {
+		return fail(category, err)
+	}
+	flog.Debug("fetched", "bytes", fl.Bytes, "handler", fl.Handler)
+	return fl
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// processWithHandler reads and updates labels through a registered format handler,
+// extraction based features (metadata, sanitize, part verification) are OOXML only
+func processWithHandler(cmd string, flog *slog.Logger, fl sl.Result, name string, handler sl.LabelReader, update updateFunc, manifest *sl.Manifest, fail func(string, error) sl.Result) sl.Result {
+	filePath := fl.FilePath
+	var labels sl.Labels
+	err := retry(flog, func() error {
+		var err error
+		labels, fl.LabelInfo, err = handler.ReadLabels(filePath)
+		return err
+	})
+	if err != nil {
+		if category, err := checkXMLError(flog, &fl, err); err != nil {
+			return fail(category, err)
+		}
+	}
+	fl.Labels = labels.Labels
+	if fl.Labels == nil {
+		fl.Labels = []sl.Label{}
+	}
+	checkDuplicates(flog, &fl)
+
+	if update != nil {
+		if newLabels, ok := update(fl); ok {
+			writer, ok := handler.(sl.LabelWriter)
+			if !ok || sanitizeOpts != nil {
+				return fail(errFormat, fmt.Errorf("%s does not support %s", name, cmd))
+			}
+			category, err := writeWithHandler(flog, cmd, &fl, writer, newLabels, manifest)
+			if !skipWrite(flog, &fl, category) && err != nil {
+				return fail(category, err)
+			}
+		}
+	}
+
+	fl.ForbiddenLabels = sl.FindForbiddenLabels(fl.Labels, denyLabels, denyTenants)
+	fl.TenantMismatch = sl.FindTenantMismatches(fl.Labels, expectedTenant)
+	return fl
+}
+
+// writeWithHandler is applyLabels for formats handled by a LabelWriter, labels are
+// verified by reading them back through the handler
+func writeWithHandler(flog *slog.Logger, cmd string, fl *sl.Result, writer sl.LabelWriter, newLabels sl.Labels, manifest *sl.Manifest) (string, error) {
+	filePath := fl.FilePath
+	return writeLabels(flog, cmd, fl, newLabels, manifest, labelWrite{
+		diff: func() (*sl.LabelDiff, error) {
+			diff := sl.CompareLabels(fl.Labels, newLabels)
+			return &diff, nil
+		},
+		write: func() error {
+			return writer.WriteLabels(filePath, newLabels)
+		},
+		verify: func() ([]sl.PartHash, error) {
+			reader, ok := writer.(sl.LabelReader)
+			if !ok {
+				return nil, nil
+			}
+			labels, _, err := reader.ReadLabels(filePath)
+			if err == nil && !sl.SameLabels(labels.Labels, newLabels.Labels) {
+				err = fmt.Errorf("labels read back differ from the labels written")
+			}
+			return nil, err
+		},
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// --health serves /healthz and /readyz, the coordinator also serves them on --listen
+var healthAddr string
+
+// health is the state reported by /healthz and /readyz
+var health = &healthState{checks: map[string]string{}}
+
+type healthState struct {
+	queue         atomic.Int64 // files, or shards on the coordinator, not done yet
+	mu            sync.Mutex
+	lastSuccess   time.Time
+	configVersion string
+	checks        map[string]string // failing connections (sinks, coordinator) and their error
+}
+
+// healthReport is the body of /healthz and /readyz
+type healthReport struct {
+	Status        string            `json:"status"`
+	RunId         string            `json:"runId"`
+	ConfigVersion string            `json:"configVersion,omitempty"`
+	Queue         int64             `json:"queue"`
+	LastSuccess   *time.Time        `json:"lastSuccess,omitempty"`
+	TmpDir        string            `json:"tmpDir"`
+	FreeSpace     uint64            `json:"freeSpace"`
+	Checks        map[string]string `json:"checks"`
+}
+
+// success records a file scanned, or a shard merged, without error
+func (h *healthState) success() {
+	h.mu.Lock()
+	h.lastSuccess = time.Now()
+	h.mu.Unlock()
+}
+
+// setConfigVersion records the version of the config in use
+func (h *healthState) setConfigVersion(version string) {
+	h.mu.Lock()
+	h.configVersion = version
+	h.mu.Unlock()
+}
+
+// check records the outcome of talking to a sink or the coordinator, a nil error clears a failure
+func (h *healthState) check(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.checks[name] = err.Error()
+	} else {
+		delete(h.checks, name)
+	}
+}
+
+// report checks the temp directory and collects failing connections, ready is false
+// when any check fails or the run is shutting down
+func (h *healthState) report() (healthReport, bool) {
+	r := healthReport{RunId: runId, Queue: h.queue.Load(), TmpDir: tmpDir, Checks: map[string]string{}}
+	h.mu.Lock()
+	r.ConfigVersion = h.configVersion
+	if !h.lastSuccess.IsZero() {
+		last := h.lastSuccess
+		r.LastSuccess = &last
+	}
+	for name, err := range h.checks {
+		r.Checks[name] = err
+	}
+	h.mu.Unlock()
+	for _, sink := range sinks {
+		if _, ok := r.Checks["sink:"+sink.Name]; !ok {
+			r.Checks["sink:"+sink.Name] = "ok"
+		}
+	}
+	if info, err := os.Stat(tmpDir); err != nil {
+		r.Checks["tmp-dir"] = err.Error()
+	} else if !info.IsDir() {
+		r.Checks["tmp-dir"] = tmpDir + " is not a directory"
+	} else if free, err := sl.FreeSpace(tmpDir); err == nil {
+		r.FreeSpace = free
+		r.Checks["tmp-dir"] = "ok"
+		if free < uint64(minFreeSpace) {
+			r.Checks["tmp-dir"] = "free space below --min-free-space"
+		}
+	} else {
+		r.Checks["tmp-dir"] = "ok"
+	}
+	if cancelled.Load() {
+		r.Checks["shutdown"] = "cancelled, finishing the current file"
+	}
+	ready := true
+	for _, status := range r.Checks {
+		if status != "ok" {
+			ready = false
+		}
+	}
+	return r, ready
+}
+
+// serveHealth answers /healthz while the process runs and /readyz with 503 while
+// a check fails, both return the health report
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	report, ready := health.report()
+	status := http.StatusOK
+	report.Status = "ok"
+	if r.URL.Path == "/readyz" {
+		report.Status = "ready"
+		if !ready {
+			report.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
+
+// healthPaths are served without --cluster-token so monitoring needs no secret
+var healthPaths = []string{"/healthz", "/readyz"}
+
+func isHealthPath(path string) bool {
+	return slices.Contains(healthPaths, path)
+}
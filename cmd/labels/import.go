@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+func init() {
+	addCommand(&command{
+		name:    "import",
+		args:    []string{"index", "path"},
+		summary: "apply the labels recorded by export to the matching files under the provided directory",
+		examples: []string{
+			`labels.exe import "path\to\export\index.json" "path\to\dir" --dry-run`,
+		},
+		run: runImport,
+	}, writeFlags, scanFlags)
+}
+
+// runImport relabels the files of an export index found under path,
+// files missing from path and files whose labels already match are reported
+func runImport(args []string) {
+	index, err := sl.ReadExportIndex(args[0])
+	if err != nil {
+		exitError(err)
+	}
+	root := args[1]
+	prepareScan()
+	logger.Debug("import", "index", args[0], "source", index.Source, "entries", len(index.Entries), "path", root)
+
+	entries := map[string]sl.ExportEntry{}
+	var filePaths []string
+	missing := 0
+	for _, entry := range index.Entries {
+		if entry.Error != "" {
+			warn("skipping file that failed to export", "file", entry.Path, "error", entry.Error)
+			continue
+		}
+		filePath := filepath.Join(root, filepath.FromSlash(entry.Path))
+		if _, err := os.Stat(sl.LongPath(filePath)); err != nil {
+			logger.Warn("file not found", "file", filePath, "error", err)
+			missing++
+			continue
+		}
+		entries[filePath] = entry
+		filePaths = append(filePaths, filePath)
+	}
+	if missing > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "Missing: %d files in the index not found under %s\n", missing, root)
+	}
+
+	matched := 0
+	scanFiles("import", []scanRoot{{path: root, filePaths: filePaths}}, func(fl sl.Result) (sl.Labels, bool) {
+		entry := entries[fl.FilePath]
+		if entry.LabelInfo == fl.LabelInfo && sl.SameLabels(entry.Labels, fl.Labels) {
+			matched++
+			return sl.Labels{}, false
+		}
+		return sl.Labels{Labels: entry.Labels}, true
+	})
+	if matched > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "Unchanged: %d files already match the index\n", matched)
+	}
+	if missing > 0 {
+		exit(sl.ExitFileErrors)
+	}
+}
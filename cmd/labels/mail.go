@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// flags to mail the scan summary, the SMTP password is only read from the environment
+var mailTo []string
+var mailFrom, smtpServer, smtpUser string
+var mailThreshold int
+
+// checkMail validates the --mail-to flags before any file is read
+func checkMail() error {
+	if len(mailTo) == 0 {
+		return nil
+	}
+	if mailFrom == "" || smtpServer == "" {
+		return fmt.Errorf("--mail-to requires --mail-from and --smtp-server")
+	}
+	if mailThreshold < 0 {
+		return fmt.Errorf("invalid --mail-threshold %d", mailThreshold)
+	}
+	return nil
+}
+
+// mailReport sends the summary of a completed scan, a failed delivery is
+// a warning only as the scan itself succeeded
+func mailReport(cmd string, roots []scanRoot, results []sl.Result, violations int, duration time.Duration) {
+	if len(mailTo) == 0 {
+		return
+	}
+	if violations < mailThreshold {
+		logger.Info("mail not sent", "violations", violations, "threshold", mailThreshold)
+		return
+	}
+	var paths []string
+	for _, root := range roots {
+		paths = append(paths, root.path)
+	}
+	host, _ := os.Hostname()
+	subject := fmt.Sprintf("labels.exe %s %s: %d files, %d policy violations", cmd, strings.Join(paths, ", "), len(results), violations)
+	attachment, err := resultsCSV(results)
+	if err != nil {
+		warn("unable to mail the report", "error", err)
+		return
+	}
+	opts := sl.MailOptions{
+		Server:   smtpServer,
+		From:     mailFrom,
+		To:       mailTo,
+		User:     smtpUser,
+		Password: os.Getenv(envName("smtp-password")),
+	}
+	body := mailBody(cmd, host, paths, sl.Summarize(results, duration), results)
+	err = sl.SendMail(opts, subject, body, []sl.MailAttachment{
+		{Name: "labels-" + runId + ".csv", ContentType: "text/csv; charset=utf-8", Data: attachment},
+	})
+	if err != nil {
+		warn("unable to mail the report", "server", smtpServer, "error", err)
+		return
+	}
+	logger.Info("mailed report", "to", strings.Join(mailTo, ","), "violations", violations)
+}
+
+func mailBody(cmd, host string, paths []string, s sl.Summary, results []sl.Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "labels.exe %s on %s, run %s\n", cmd, host, runId)
+	for _, path := range paths {
+		fmt.Fprintf(&b, "path: %s\n", path)
+	}
+	fmt.Fprintf(&b, "\nfiles: %d (%d labeled, %d unlabeled, %d errors, %d skipped)\n", s.Files, s.Labeled, s.Unlabeled, s.Errors, s.Skipped)
+	fmt.Fprintf(&b, "coverage: %s\n", percent(s.Labeled, s.Files))
+	fmt.Fprintf(&b, "duration: %s\n", time.Duration(s.DurationMs)*time.Millisecond)
+	for _, id := range sortedByCount(s.Labels) {
+		fmt.Fprintf(&b, "label %s: %d\n", withName(id, labelConfig.Labels), s.Labels[id])
+	}
+	for _, id := range sortedByCount(s.Tenants) {
+		fmt.Fprintf(&b, "tenant %s: %d\n", withName(id, labelConfig.Tenants), s.Tenants[id])
+	}
+	header := false
+	for _, fl := range results {
+		if len(fl.ForbiddenLabels) == 0 && len(fl.TenantMismatch) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(&b, "\nPolicy violations:")
+			header = true
+		}
+		fmt.Fprintf(&b, "%s\n", fl.FilePath)
+	}
+	fmt.Fprintln(&b, "\nEvery result is attached as CSV.")
+	return b.String()
+}
+
+// resultsCSV renders one row per file for spreadsheets
+func resultsCSV(results []sl.Result) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"path", "labels", "tenants", "forbidden", "tenantMismatch", "skipped", "error", "reason", "department", "owner"})
+	for _, fl := range results {
+		var labels, tenants []string
+		for _, label := range resolveNames(fl.Labels) {
+			labels = append(labels, orDefault(label.Name, sl.NormalizeId(label.Id)))
+			tenants = append(tenants, orDefault(label.TenantName, sl.NormalizeId(label.SiteId)))
+		}
+		var department, owner string
+		if fl.Ownership != nil {
+			department, owner = fl.Ownership.Department, fl.Ownership.Owner
+		}
+		w.Write([]string{
+			fl.FilePath,
+			strings.Join(labels, "; "),
+			strings.Join(tenants, "; "),
+			strconv.Itoa(len(fl.ForbiddenLabels)),
+			strconv.Itoa(len(fl.TenantMismatch)),
+			fl.Skipped,
+			fl.Error,
+			fl.Reason,
+			department,
+			owner,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
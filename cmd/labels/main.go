@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/fs"
+	"log/slog"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	sl "github.com/WTFender/sensitivity_labels"
 	flag "github.com/spf13/pflag"
@@ -15,86 +15,249 @@ import (
 
 // config.json can optionally be used
 // to map label and tenant IDs to names
+// and to provide default values for any flag
 type LabelsConfig struct {
-	Labels  map[string]string `json:"labels"`
-	Tenants map[string]string `json:"tenants"`
+	Labels   map[string]string            `json:"labels"`
+	Tenants  map[string]string            `json:"tenants"`
+	Flags    map[string]interface{}       `json:"flags"`
+	Profiles map[string]LabelsProfile     `json:"profiles"`
+	Headers  map[string]map[string]string `json:"headers"` // HTTP headers per host for URLs, "*" for every host
 }
 
+// environment variables override config file flags, e.g. LABELS_TMP_DIR
+const envPrefix = "LABELS_"
+
 var labelConfig = LabelsConfig{}
 
-// flags
-var extensionsCsv = ".docx,.xlsx,.pptx"
+// flags shared by every command
 var tmpDir, config string
-var verbose, showHelp, showJson, showLabeledOnly, dryrun, noCleanup, recurse bool
+var verbose, quiet, showHelp, noCleanup, secureTmp, noDisk, offline bool
+var globalFlags = newGlobalFlags()
+
+var runId = sl.NewRunId()
 var delimiter = " " // TODO cleanup this
 
-// logger
-func log(msgs []string) {
-	if verbose {
-		for _, m := range msgs {
-			fmt.Println(m)
+// logger, diagnostics go to stderr so stdout only carries results
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+var logLevel, logFormat string
+
+func warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
+// setupLogger applies --log-level and --log-format,
+// --verbose and --quiet are shorthands for the debug and error levels
+func setupLogger() error {
+	var level slog.Level
+	switch {
+	case globalFlags.Changed("log-level") || logLevel != "warn":
+		if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+			return fmt.Errorf("invalid log level %q", logLevel)
+		}
+	case quiet:
+		level = slog.LevelError
+	case verbose:
+		level = slog.LevelDebug
+	default:
+		level = slog.LevelWarn
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch logFormat {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return fmt.Errorf("invalid log format %q", logFormat)
+	}
+	logger = logger.With("runId", runId)
+	return nil
+}
+
+// global flags are built before any init() so commands can embed them
+func newGlobalFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("global", flag.ContinueOnError)
+	fs.BoolVar(&verbose, "verbose", false, "show diagnostic output")
+	fs.BoolVar(&quiet, "quiet", false, "only show results and errors")
+	fs.StringVar(&logLevel, "log-level", "warn", "log level: debug, info, warn or error")
+	fs.StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	fs.StringVar(&config, "config", "", "path to JSON file containing ID to name mappings")
+	fs.StringVar(&profile, "profile", "", "named profile of the config file to apply: tenant, label catalog, default policies and sinks")
+	fs.StringVar(&tmpDir, "tmp-dir", "./", "temporary directory for file extraction")
+	fs.BoolVar(&noCleanup, "no-cleanup", false, "do not remove temporary directory contents")
+	fs.BoolVar(&secureTmp, "secure-tmp", false, "create temporary directories only accessible to the current user and overwrite extracted files before removing them")
+	fs.BoolVar(&noDisk, "no-disk", false, "fail documents that would be extracted to the temporary directory instead of being processed in memory")
+	fs.BoolVar(&noColor, "no-color", false, "disable colored output")
+	fs.StringVar(&outputFile, "output-file", "", "write results to this file, replacing it once the run completes")
+	fs.BoolVar(&appendOutput, "append", false, "append results to --output-file instead of replacing it")
+	fs.BoolVar(&offline, "offline", false, "refuse anything that could access the network: --plugin executables, --mail-to and URLs")
+	fs.StringSliceVar(&plugins, "plugin", nil, "external plugin executable providing a format handler or result sink, may be repeated")
+	fs.StringVar(&pprofAddr, "pprof", "", "serve Go pprof profiles on this address, e.g. localhost:6060")
+	fs.DurationVar(&configPoll, "config-poll", 5*time.Second, "reload --config when it changes, checked this often (0 reloads on SIGHUP only)")
+	fs.StringVar(&healthAddr, "health", "", "serve /healthz and /readyz on this address, e.g. :8701")
+	fs.Int64Var(&minFreeSpace, "min-free-space", 1<<30, "fail the doctor free space check and /readyz below this many bytes in --tmp-dir")
+	fs.DurationVar(&statsInterval, "stats-interval", 0, "log memory, GC and goroutine stats at info level every interval (0 disables)")
+	fs.BoolVar(&showHelp, "help", false, "show usage")
+	return fs
+}
+
+// command is a single labels.exe subcommand with its own flags and positional arguments
+type command struct {
+	name     string
+	args     []string // required positional arguments
+	optional []string // optional trailing positional arguments
+	variadic bool     // the last argument may be repeated, or given with a flag of the same name
+	summary  string
+	examples []string
+	flags    *flag.FlagSet
+	run      func(args []string)
+}
+
+var commands []*command
+
+// registers a subcommand, flagSets are shared groups of flags the command accepts
+func addCommand(cmd *command, flagSets ...*flag.FlagSet) {
+	cmd.flags = flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.flags.SortFlags = false
+	cmd.flags.Usage = func() {}
+	for _, fs := range flagSets {
+		cmd.flags.AddFlagSet(fs)
+	}
+	cmd.flags.AddFlagSet(globalFlags)
+	commands = append(commands, cmd)
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
 	}
+	return nil
 }
 
-func init() {
-	flag.StringVar(&extensionsCsv, "extensions", extensionsCsv, "file extensions to search for")
-	flag.BoolVar(&verbose, "verbose", false, "show diagnostic output")
-	flag.BoolVar(&showLabeledOnly, "labeled", false, "only show labeled files")
-	flag.BoolVar(&showJson, "json", false, "display results as json")
-	flag.StringVar(&config, "config", "", "path to JSON file containing ID to name mappings")
-	flag.BoolVar(&dryrun, "dry-run", false, "show results of set before applying")
-	flag.BoolVar(&recurse, "recursive", false, "recurse through subdirectory files")
-	flag.StringVar(&tmpDir, "tmp-dir", "./", "temporary directory for file extraction")
-	flag.BoolVar(&noCleanup, "no-cleanup", false, "do not remove temporary directory contents")
-	flag.BoolVar(&showHelp, "help", false, "show usage")
-	flag.Usage = func() {
-		printUsage("")
+func (cmd *command) usageLine() string {
+	usage := "labels.exe " + cmd.name + " [--flags]"
+	for _, arg := range cmd.args {
+		usage += " <" + arg + ">"
+	}
+	for _, arg := range cmd.optional {
+		usage += " [" + arg + "]"
+	}
+	if cmd.variadic {
+		usage += "..."
 	}
+	return usage
+}
+
+// argFlagSet reports whether the missing argument i of a variadic command was given as a flag instead
+func (cmd *command) argFlagSet(i int) bool {
+	if !cmd.variadic || i != len(cmd.args)-1 {
+		return false
+	}
+	f := cmd.flags.Lookup(cmd.args[i])
+	return f != nil && f.Changed
 }
 
 func printUsage(msg string) {
+	msg = colorize(colorRed, msg)
 	usage := `%s
 usage:
-	labels.exe [--flags] get <path>
-	labels.exe [--flags] set <path> <labelId> <tenantId>
+	labels.exe <command> [--flags] [arguments]
 
-commands	
-	get: list sensitivity labels for the provided file or directory
-	set: apply the provided sensitivity label ID to the provided file or directory
-
-arguments
-	path: path to the file or directory
-	labelId: sensitivity label ID to apply
-	tenantId: microsoft tenant ID to apply
-
-flags
+commands
 %s
+global flags
+%s
+run "labels.exe help <command>" for command arguments and flags
+
 examples
 	labels.exe get .
-	labels.exe get "path\to\dir" --labeled --recursive --json 
+	labels.exe get "path\to\dir" --labeled --recursive --json
 	labels.exe set "path\to\file.xlsx" "1234-label-id-1234" "4321-tenant-id-4321"`
-	fmt.Println(fmt.Sprintf(usage, msg, flag.CommandLine.FlagUsages()))
+	cmds := ""
+	for _, cmd := range commands {
+		cmds += "\t" + cmd.name + ": " + cmd.summary + "\n"
+	}
+	fmt.Println(fmt.Sprintf(usage, msg, cmds, globalFlags.FlagUsages()))
+}
+
+func printCommandUsage(cmd *command, msg string) {
+	msg = colorize(colorRed, msg)
+	usage := `%s
+usage:
+	%s
+
+%s
+
+flags
+%s`
+	if len(cmd.examples) > 0 {
+		usage += "\nexamples\n\t" + strings.Join(cmd.examples, "\n\t")
+	}
+	fmt.Println(fmt.Sprintf(usage, msg, cmd.usageLine(), cmd.summary, cmd.flags.FlagUsages()))
+}
+
+// functions run by exit, e.g. releasing locks
+var atExit []func()
+
+// exit finishes the output file before exiting,
+// results are kept unless the run failed
+func exit(code int) {
+	for _, fn := range atExit {
+		fn()
+	}
+	if err := closeOutput(code != sl.ExitFatal && code != sl.ExitUsage); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		code = sl.ExitFatal
+	}
+	os.Exit(code)
+}
+
+func exitError(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	exit(sl.ExitFatal)
+}
+
+// tmpOptions applies --secure-tmp and --no-disk to extraction directories
+func tmpOptions() sl.TmpOptions {
+	return sl.TmpOptions{Private: secureTmp, Shred: secureTmp, NoDisk: noDisk}
 }
 
 func cleanup(path string) {
-	log([]string{"cleanup: " + path})
+	logger.Debug("cleanup", "path", path)
 	if !noCleanup {
-		err := os.RemoveAll(path)
+		err := sl.RemoveTmpDir(path, tmpOptions())
 		if err != nil {
-			log([]string{
-				"cleanup error: " + path,
-				err.Error(),
-			})
+			warn("cleanup error", "path", path, "error", err)
 		}
 	}
 }
 
+// split a csv flag and resolve any config names back to IDs
+func parseIdList(csv string, names map[string]string) []string {
+	var ids []string
+	for _, item := range strings.Split(csv, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		for id, name := range names {
+			if strings.EqualFold(name, item) {
+				item = id
+				break
+			}
+		}
+		ids = append(ids, item)
+	}
+	return ids
+}
+
 func parseLabelConfigJson(path string) LabelsConfig {
 	var cfg LabelsConfig
 	jsonFile, err := os.Open(path)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 	}
 	defer jsonFile.Close()
 	byteValue, _ := io.ReadAll(jsonFile)
@@ -102,234 +265,170 @@ func parseLabelConfigJson(path string) LabelsConfig {
 	return cfg
 }
 
-func PrintFileLabelHeader() {
-	if !showJson {
-		fmt.Println(strings.Join([]string{
-			"LabelInfo",
-			"FilePath",
-			"NumLabels",
-			"Labels",
-		}, delimiter))
-	}
-
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
 }
 
-func PrintFileLabel(fl sl.FileLabel) {
-	// true ./123.xlsx 1 [3de9faa6-9fe1-49b3-9a08-227a296b54a6 f49dfc2f-b2b1-4605-accd-09d3ac0089a8]
-	labelsArr := []string{}
-	if showJson {
-		return
-	}
-	for _, label := range fl.Labels {
-		labelStr := strings.ReplaceAll((label.Id + " " + label.SiteId), "{", "")
-		labelStr = strings.ReplaceAll(labelStr, "}", "")
-		labelsArr = append(labelsArr, labelStr)
-	}
-	combinedLabelStr := "[" + strings.Join(labelsArr, ", ") + "]"
-	// resolve ids to names if config provided
-	if config != "" {
-		// for each key in labelConfig.Labels, replace id with name
-		for labelId, labelName := range labelConfig.Labels {
-			combinedLabelStr = strings.ReplaceAll(combinedLabelStr, labelId, labelName)
+// applyFlagDefaults fills in flags that were not set on the command line,
+// precedence is flags > LABELS_* environment variables > config file
+func applyFlagDefaults(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Changed || err != nil {
+			return
 		}
-		for tenantId, tenantName := range labelConfig.Tenants {
-			combinedLabelStr = strings.ReplaceAll(combinedLabelStr, tenantId, tenantName)
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			logger.Debug("flag from environment", "flag", f.Name, "env", envName(f.Name))
+			flagSource[f.Name] = "env"
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for %s: %w", envName(f.Name), setErr)
+			}
+			return
 		}
-	}
-	// ./123.xlsx true [label1 label2]
-	fmt.Println(strings.Join([]string{
-		strconv.FormatBool(fl.LabelInfo),
-		fl.FilePath,
-		strconv.Itoa(len(fl.Labels)), // Convert length to string
-		combinedLabelStr,
-	}, delimiter))
+		if value, ok := labelConfig.Flags[f.Name]; ok {
+			logger.Debug("flag from config", "flag", f.Name, "config", config)
+			flagSource[f.Name] = "config"
+			if setErr := setConfigFlag(fs, f, value); setErr != nil {
+				err = fmt.Errorf("invalid value for %s in %s: %w", f.Name, config, setErr)
+			}
+		}
+	})
+	return err
 }
 
-func checkArgs(args []string) (string, string, string, string, []string) {
-	log([]string{
-		"args: " + strings.Join(os.Args, ", "),
-		"parsed args: " + strings.Join(args, ", "),
-	})
-	var cmd, path string
-	labelId := ""
-	tenantId := ""
-	if len(args) < 1 {
-		printUsage("Error: missing command argument")
-		os.Exit(1)
-	} else if len(args) < 2 {
-		printUsage("Error: missing path argument")
-		os.Exit(1)
-	} else if args[0] != "get" && args[0] != "set" {
-		printUsage("Error: unsupported command " + args[0])
-		os.Exit(1)
-	} else if args[0] == "set" && len(args) < 3 {
-		printUsage("Error: missing labelId argument")
-		os.Exit(1)
-	} else if args[0] == "set" && len(args) < 4 {
-		printUsage("Error: missing tenantId argument")
-		os.Exit(1)
-	} else if len(args) > 4 {
-		printUsage("Error: too many arguments")
-		os.Exit(1)
-	}
-	cmd = args[0]
-	path = args[1]
-	if len(args) == 4 {
-		labelId = args[2]
-		tenantId = args[3]
-	}
-	// check if extensions flag is set
-	extensions := strings.Split(strings.TrimSpace(extensionsCsv), ",")
-	if len(extensions) < 1 {
-		fmt.Println("Skipping ID resolution, unable to parse JSON reference: " + config)
-	}
-	// check if config file is valid, ignore if not
-	if config != "" {
-		info, err := os.Stat(config)
-		if err != nil || info.IsDir() {
-			fmt.Println("Skipping ID resolution, unable to parse JSON reference: " + config)
-			config = ""
-		} else {
-			labelConfig = parseLabelConfigJson(config)
-			numIds := (len(labelConfig.Labels) + len(labelConfig.Tenants))
-			log([]string{
-				"loaded labelConfig: " + config,
-				"labelConfig numEntries: " + strconv.Itoa(numIds),
-			})
+// setConfigFlag sets f to a value of the config "flags", lists are set item by
+// item on slice flags and joined with commas otherwise
+func setConfigFlag(fs *flag.FlagSet, f *flag.Flag, value any) error {
+	list, isList := value.([]interface{})
+	if !isList {
+		return fs.Set(f.Name, fmt.Sprint(value))
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+	sv, isSlice := f.Value.(flag.SliceValue)
+	if !isSlice {
+		return fs.Set(f.Name, strings.Join(items, ","))
+	}
+	if len(items) == 0 {
+		return sv.Replace(nil)
+	}
+	// the first Set replaces the default, the others append
+	for _, item := range items {
+		if err := fs.Set(f.Name, item); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
+// check if config file is valid, ignore if not
+func loadConfig() {
+	if config == "" {
+		config = os.Getenv(envName("config"))
 	}
-	if noCleanup {
-		log([]string{"noCleanup: true"})
-		fmt.Println("warn: temporary directory will not be removed")
+	if config == "" {
+		return
 	}
-	if dryrun {
-		log([]string{"dryrun: true"})
-		fmt.Println("warn: dry-run enabled")
+	info, err := os.Stat(config)
+	if err != nil || info.IsDir() {
+		warn("skipping ID resolution, unable to parse JSON reference: " + config)
+		config = ""
+		return
+	}
+	labelConfig = parseLabelConfigJson(config)
+	if data, err := os.ReadFile(config); err == nil {
+		configVersion = configFileVersion(data)
+		health.setConfigVersion(configVersion)
 	}
-	return cmd, path, labelId, tenantId, extensions
+	numIds := (len(labelConfig.Labels) + len(labelConfig.Tenants))
+	logger.Debug("loaded labelConfig", "config", config, "version", configVersion, "numEntries", numIds)
 }
 
-func main() {
-
-	var files []fs.FileInfo
-	var fileLabels []sl.FileLabel
-
-	// get command line arguments
-	flag.Parse()
-	if showHelp {
-		printUsage("")
-		os.Exit(0)
-	}
-	args := flag.Args()
-	cmd, path, labelId, tenantId, extensions := checkArgs(args)
-
-	log([]string{
-		"arg command: " + cmd,
-		"arg path: " + path,
-		"arg labelId: " + labelId,
-		"arg tenantId: " + tenantId,
-		"arg extensions: " + strings.Join(extensions, ", "),
-	})
-
-	// check if path exists
-	pathInfo, err := os.Stat(path)
-	if err != nil {
-		sl.ExitError(err)
-	}
-
-	// check if path is a directory, if so list files
-	if pathInfo.IsDir() {
-		files = sl.ListExtensionFiles(path, false, extensions)
-	} else {
-		// single file
-		files = append(files, pathInfo)
-		path = strings.ReplaceAll(path, pathInfo.Name(), "")
-	}
-
-	// print results header if files found
-	if len(files) == 0 {
-		fmt.Println("No files found")
-		os.Exit(0)
-	} else {
-		PrintFileLabelHeader()
-	}
-
-	// iterate through files
-	for _, file := range files {
-		// create full path to file
-		filePath := path + "/" + file.Name()
-		// create temporary directory for file extraction
-		tmpUnzipDir := tmpDir + "/_" + file.Name()
-		log([]string{
-			"filePath: " + filePath,
-			"tmpUnzipDir: " + tmpUnzipDir,
-		})
-		unzipErr := sl.Unzip(filePath, tmpUnzipDir)
-		if unzipErr != nil {
-			// clean up on error
-			sl.ExitError(unzipErr)
-			cleanup(tmpUnzipDir)
-		}
-		// check extracted files for docMetadata/LabelInfo.xml
-		labelInfoExists, labelInfoPath := sl.CheckLabelInfoPath(tmpUnzipDir)
-		log([]string{
-			"labelInfoExists: " + strconv.FormatBool(labelInfoExists),
-			"checkLabelInfoPath: " + labelInfoPath,
-		})
-		fl := sl.FileLabel{
-			FilePath:  filePath,
-			LabelInfo: labelInfoExists,
-			Labels:    []sl.Label{},
+// splitCommand finds the command name anywhere in the arguments so the
+// original "labels.exe [--flags] get <path>" form keeps working
+func splitCommand(args []string) (*command, []string) {
+	for i, arg := range args {
+		if cmd := findCommand(arg); cmd != nil {
+			rest := append([]string{}, args[:i]...)
+			return cmd, append(rest, args[i+1:]...)
 		}
+	}
+	return nil, args
+}
 
-		// if LabelInfo.xml exists, parse XML and return labels
-		if fl.LabelInfo {
-			log([]string{"open: " + filePath})
-			labels := sl.GetLabelInfoXml(labelInfoPath)
-			fl.Labels = labels.Labels
+func main() {
+	cmd, args := splitCommand(os.Args[1:])
+	if cmd == nil {
+		globalFlags.Usage = func() {}
+		globalFlags.ParseErrorsWhitelist.UnknownFlags = true
+		globalFlags.Parse(args)
+		if showHelp {
+			printUsage("")
+			os.Exit(sl.ExitSuccess)
+		}
+		if len(globalFlags.Args()) > 0 {
+			printUsage("Error: unsupported command " + globalFlags.Args()[0])
 		} else {
-			log([]string{"LabelInfo.xml not found"})
+			printUsage("Error: missing command argument")
 		}
+		os.Exit(sl.ExitUsage)
+	}
 
-		// set labels
-		if cmd == "set" && unzipErr == nil {
-			// set new label
-			log([]string{"write: " + labelInfoPath})
-			newLabels := sl.Labels{
-				Labels: []sl.Label{
-					{
-						Id:          labelId,
-						SiteId:      tenantId,
-						Enabled:     "1",
-						Method:      "Privileged",
-						ContentBits: "0",
-						Removed:     "0",
-					},
-				}}
-			if dryrun {
-				fl.Labels = newLabels.Labels
-			} else {
-				err := sl.SetLabels(tmpUnzipDir, filePath, labelInfoPath, newLabels)
-				if err != nil {
-					sl.ExitError(err)
-				}
-				fl.Labels = newLabels.Labels
-			}
-		}
-		if !(showLabeledOnly && len(fl.Labels) == 0) {
-			PrintFileLabel(fl)
-			fileLabels = append(fileLabels, fl)
-		}
-		cleanup(tmpUnzipDir)
+	err := cmd.flags.Parse(args)
+	if err != nil {
+		printCommandUsage(cmd, "Error: "+err.Error())
+		os.Exit(sl.ExitUsage)
+	}
+	if showHelp {
+		printCommandUsage(cmd, "")
+		os.Exit(sl.ExitSuccess)
+	}
+	// validate positional arguments
+	cmdArgs := cmd.flags.Args()
+	if len(cmdArgs) < len(cmd.args) && !cmd.argFlagSet(len(cmdArgs)) {
+		printCommandUsage(cmd, "Error: missing "+cmd.args[len(cmdArgs)]+" argument")
+		os.Exit(sl.ExitUsage)
+	} else if len(cmdArgs) > len(cmd.args)+len(cmd.optional) && !cmd.variadic {
+		printCommandUsage(cmd, "Error: too many arguments")
+		os.Exit(sl.ExitUsage)
 	}
 
-	// print json results
-	if showJson {
-		jsonBytes, err := json.MarshalIndent(fileLabels, "", "  ")
-		if err != nil {
-			sl.ExitError(err)
-		}
-		fmt.Println(string(jsonBytes))
+	loadConfig()
+	if err := applyProfile(); err != nil {
+		printCommandUsage(cmd, "Error: "+err.Error())
+		os.Exit(sl.ExitUsage)
+	}
+	if err := applyFlagDefaults(cmd.flags); err != nil {
+		printCommandUsage(cmd, "Error: "+err.Error())
+		os.Exit(sl.ExitUsage)
+	}
+	if err := setupLogger(); err != nil {
+		printCommandUsage(cmd, "Error: "+err.Error())
+		os.Exit(sl.ExitUsage)
+	}
+	if err := checkOffline(cmdArgs); err != nil {
+		printCommandUsage(cmd, "Error: "+err.Error())
+		os.Exit(sl.ExitUsage)
+	}
+	logger.Debug("args",
+		"args", os.Args,
+		"parsedArgs", cmdArgs,
+		"command", cmd.name,
+	)
+	if noCleanup {
+		warn("temporary directory will not be removed")
+	}
+	if err := openOutput(); err != nil {
+		exitError(err)
+	}
+	if err := loadPlugins(); err != nil {
+		exitError(err)
 	}
+	watchSignals()
+	watchConfig(cmd.flags)
+	startDiagnostics()
+	cmd.run(cmdArgs)
+	exit(sl.ExitSuccess)
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+// flags for verify-manifest
+var verifyCert, verifyHmacKey string
+
+func init() {
+	verifyFlags := flag.NewFlagSet("verify-manifest", flag.ContinueOnError)
+	verifyFlags.StringVar(&verifyCert, "cert", "", "path to the PEM certificate or public key the manifest must be signed by")
+	verifyFlags.StringVar(&verifyHmacKey, "hmac-key", "", "path to the key file the manifest must be signed with using HMAC-SHA256")
+	addCommand(&command{
+		name:    "verify-manifest",
+		args:    []string{"manifest"},
+		summary: "check that a signed --manifest was not altered since the run that wrote it",
+		examples: []string{
+			`labels.exe verify-manifest manifest.json --cert signer.crt`,
+			`labels.exe verify-manifest manifest.json --hmac-key manifest.key`,
+		},
+		run: runVerifyManifest,
+	}, verifyFlags)
+}
+
+// runVerifyManifest verifies a manifest against the signer's certificate or HMAC key,
+// the certificate embedded in the manifest is never trusted
+func runVerifyManifest(args []string) {
+	if (verifyCert == "") == (verifyHmacKey == "") {
+		printCommandUsage(findCommand("verify-manifest"), "Error: one of --cert or --hmac-key is required")
+		exit(sl.ExitUsage)
+	}
+	manifest, err := sl.ReadManifest(args[0])
+	if err != nil {
+		exitError(err)
+	}
+	if err := verifyManifest(manifest); err != nil {
+		exitError(fmt.Errorf("%s: %w", args[0], err))
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Verified: %d entries created %s, signed with %s\n", len(manifest.Entries), manifest.Created, manifest.SignatureAlg)
+	}
+}
+
+func verifyManifest(manifest *sl.Manifest) error {
+	if manifest.Signature == "" {
+		return sl.ErrUnsignedManifest
+	}
+	if verifyHmacKey != "" {
+		key, err := os.ReadFile(verifyHmacKey)
+		if err != nil {
+			return err
+		}
+		if !manifest.VerifyHMAC(key) {
+			return fmt.Errorf("%w: the manifest was altered or signed with another key", sl.ErrSignature)
+		}
+		return nil
+	}
+	certPEM, err := os.ReadFile(verifyCert)
+	if err != nil {
+		return err
+	}
+	if err := manifest.VerifyX509(certPEM); err != nil {
+		if errors.Is(err, sl.ErrSignature) {
+			return fmt.Errorf("%w: the manifest was altered or signed with another key", err)
+		}
+		return err
+	}
+	return nil
+}
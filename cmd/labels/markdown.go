@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// PrintMarkdownReport renders --report md: the summary, any --group-by and --top
+// sections, policy findings and a table of the results
+func PrintMarkdownReport(report jsonReport) {
+	if reportFormat != "md" {
+		return
+	}
+	s := report.Summary
+	fmt.Fprintln(out, "# Sensitivity label report")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "## Summary")
+	fmt.Fprintln(out)
+	mdTable([]string{"Files", "Labeled", "Unlabeled", "Errors", "Skipped", "Coverage", "Duration"}, [][]string{{
+		strconv.Itoa(s.Files),
+		strconv.Itoa(s.Labeled),
+		strconv.Itoa(s.Unlabeled),
+		strconv.Itoa(s.Errors),
+		strconv.Itoa(s.Skipped),
+		percent(s.Labeled, s.Files),
+		(time.Duration(s.DurationMs) * time.Millisecond).String(),
+	}})
+	if len(s.Labels) > 0 {
+		mdSection("Labels")
+		var rows [][]string
+		for _, id := range sortedByCount(s.Labels) {
+			rows = append(rows, []string{mdCode(id), configName(labelConfig.Labels, id), strconv.Itoa(s.Labels[id])})
+		}
+		mdTable([]string{"Label", "Name", "Files"}, rows)
+	}
+	if len(s.Tenants) > 0 {
+		mdSection("Tenants")
+		var rows [][]string
+		for _, id := range sortedByCount(s.Tenants) {
+			rows = append(rows, []string{mdCode(id), configName(labelConfig.Tenants, id), strconv.Itoa(s.Tenants[id])})
+		}
+		mdTable([]string{"Tenant", "Name", "Files"}, rows)
+	}
+	if report.Groups != nil {
+		mdSection("By " + report.GroupBy)
+		var rows [][]string
+		for _, g := range report.Groups {
+			key := mdCode(g.Key)
+			switch report.GroupBy {
+			case sl.GroupByLabel:
+				key = mdCode(g.Key) + " " + configName(labelConfig.Labels, g.Key)
+			case sl.GroupByTenant:
+				key = mdCode(g.Key) + " " + configName(labelConfig.Tenants, g.Key)
+			}
+			row := []string{key, strconv.Itoa(g.Files), strconv.Itoa(g.Labeled), strconv.Itoa(g.Unlabeled), strconv.Itoa(g.Errors), percent(g.Labeled, g.Files)}
+			if ownershipMap != nil && report.GroupBy == sl.GroupByDirectory {
+				row = append(row, mdOwnership(g.Ownership)...)
+			}
+			rows = append(rows, row)
+		}
+		header := []string{strings.ToUpper(report.GroupBy[:1]) + report.GroupBy[1:], "Files", "Labeled", "Unlabeled", "Errors", "Coverage"}
+		if ownershipMap != nil && report.GroupBy == sl.GroupByDirectory {
+			header = append(header, ownershipHeader...)
+		}
+		mdTable(header, rows)
+	}
+	if report.Directories != nil {
+		mdSection("Directories")
+		var rows [][]string
+		for _, r := range report.Directories {
+			dominant := ""
+			if r.DominantLabel != "" {
+				dominant = mdCode(r.DominantLabel) + " " + configName(labelConfig.Labels, r.DominantLabel)
+			}
+			row := []string{mdCode(r.Directory), strconv.Itoa(r.Files), strconv.Itoa(r.Labeled), strconv.Itoa(r.Unlabeled), strconv.Itoa(r.Errors), percent(r.Labeled, r.Files), dominant}
+			if ownershipMap != nil {
+				row = append(row, mdOwnership(r.Ownership)...)
+			}
+			rows = append(rows, row)
+		}
+		header := []string{"Directory", "Files", "Labeled", "Unlabeled", "Errors", "Coverage", "Dominant label"}
+		if ownershipMap != nil {
+			header = append(header, ownershipHeader...)
+		}
+		mdTable(header, rows)
+	}
+	if report.Top != nil {
+		mdSection("Largest unlabeled files")
+		mdTable([]string{"File", "Bytes", "Modified"}, topRows(report.Top.Largest))
+		mdSection("Most recently modified unlabeled files")
+		mdTable([]string{"File", "Bytes", "Modified"}, topRows(report.Top.Recent))
+	}
+	var findings [][]string
+	for _, fl := range report.Results {
+		for _, label := range fl.ForbiddenLabels {
+			findings = append(findings, []string{mdCode(fl.FilePath), "forbidden label", mdCode(sl.NormalizeId(label.Id)) + " " + configName(labelConfig.Labels, label.Id)})
+		}
+		for _, label := range fl.TenantMismatch {
+			findings = append(findings, []string{mdCode(fl.FilePath), "unexpected tenant", mdCode(sl.NormalizeId(label.SiteId)) + " " + configName(labelConfig.Tenants, label.SiteId)})
+		}
+		if fl.Error != "" {
+			findings = append(findings, []string{mdCode(fl.FilePath), fl.ErrorCategory + " error", mdEscape(withReason(fl))})
+		}
+	}
+	if len(findings) > 0 {
+		mdSection("Findings")
+		mdTable([]string{"File", "Finding", "Detail"}, findings)
+	}
+	mdSection("Files")
+	var rows [][]string
+	for _, fl := range report.Results {
+		var labels []string
+		for _, label := range fl.Labels {
+			name := sl.NormalizeId(label.Id)
+			if n := configName(labelConfig.Labels, label.Id); n != "" {
+				name = n
+			}
+			labels = append(labels, mdEscape(name))
+		}
+		row := []string{mdCode(fl.FilePath), strconv.FormatBool(fl.LabelInfo), strconv.Itoa(len(fl.Labels)), strings.Join(labels, ", ")}
+		if ownershipMap != nil {
+			row = append(row, mdOwnership(fl.Ownership)...)
+		}
+		rows = append(rows, row)
+	}
+	header := []string{"File", "LabelInfo", "NumLabels", "Labels"}
+	if ownershipMap != nil {
+		header = append(header, ownershipHeader...)
+	}
+	mdTable(header, rows)
+}
+
+func mdOwnership(o *sl.Ownership) []string {
+	if o == nil {
+		return []string{"", "", ""}
+	}
+	return []string{mdEscape(o.Department), mdEscape(o.Owner), mdEscape(o.Steward)}
+}
+
+func topRows(results []sl.Result) [][]string {
+	var rows [][]string
+	for _, fl := range results {
+		rows = append(rows, []string{mdCode(fl.FilePath), strconv.FormatInt(fl.Bytes, 10), fl.Modified})
+	}
+	return rows
+}
+
+func mdSection(title string) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "## "+title)
+	fmt.Fprintln(out)
+}
+
+func mdTable(header []string, rows [][]string) {
+	fmt.Fprintln(out, "| "+strings.Join(header, " | ")+" |")
+	fmt.Fprintln(out, strings.Repeat("| --- ", len(header))+"|")
+	for _, row := range rows {
+		fmt.Fprintln(out, "| "+strings.Join(row, " | ")+" |")
+	}
+}
+
+// mdEscape keeps table cells on one line and pipes from splitting them, control
+// characters and invalid UTF-8 are replaced with U+FFFD
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Map(func(r rune) rune {
+		if !strconv.IsPrint(r) {
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
+
+// mdCode formats a path or ID as inline code, pipes are still escaped inside tables,
+// paths with newlines, control characters or invalid UTF-8 are quoted as in text output
+func mdCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	if !printable(s) {
+		s = strconv.Quote(s)
+	}
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// results are written to out, stdout unless --output-file is set
+var out io.Writer = os.Stdout
+var outputFile string
+var appendOutput bool
+var outFile *os.File
+
+// openOutput redirects results to --output-file, without --append results are
+// written to a temporary file that replaces the output file once the run completes
+func openOutput() error {
+	if outputFile == "" {
+		return nil
+	}
+	var err error
+	if appendOutput {
+		outFile, err = os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	} else {
+		outFile, err = os.CreateTemp(filepath.Dir(outputFile), "."+filepath.Base(outputFile)+".*.tmp")
+	}
+	if err != nil {
+		return err
+	}
+	logger.Debug("output file", "path", outputFile, "append", appendOutput)
+	out = outFile
+	return nil
+}
+
+// closeOutput finishes the output file, on failure a replaced
+// output file is left untouched
+func closeOutput(commit bool) error {
+	if outFile == nil {
+		return nil
+	}
+	f := outFile
+	outFile = nil
+	out = os.Stdout
+	err := f.Close()
+	if appendOutput {
+		return err
+	}
+	if err != nil || !commit {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), outputFile)
+}
+
+// textOutput reports whether results are printed as text rows as they complete,
+// --json and --report render everything once the scan is done
+func textOutput() bool {
+	return !showJson && reportFormat == ""
+}
+
+// quotePath quotes paths that would break splitting a row on spaces: whitespace,
+// quotes, invisible characters such as bidi marks and invalid UTF-8, other unicode
+// is kept as is
+func quotePath(path string) string {
+	if !printable(path) || strings.ContainsFunc(path, func(r rune) bool { return unicode.IsSpace(r) || r == '"' }) {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+// printable reports strings without control characters, invisible characters
+// or invalid UTF-8, which decodes to utf8.RuneError
+func printable(s string) bool {
+	for _, r := range s {
+		if r == utf8.RuneError || !strconv.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func PrintFileLabelHeader() {
+	if textOutput() {
+		columns := []string{
+			"LabelInfo",
+			"FilePath",
+			"NumLabels",
+			"Labels",
+		}
+		if showMetadata {
+			columns = append(columns, "Author", "LastModifiedBy", "Company", "Created", "Modified")
+		}
+		if showTimestamps {
+			columns = append(columns, "FileModified", "LabelSetDate")
+		}
+		if showStats {
+			columns = append(columns, "Pages", "Words", "Sheets", "Slides", "EmbeddedObjects", "Media")
+		}
+		if ownershipMap != nil {
+			columns = append(columns, ownershipHeader...)
+		}
+		fmt.Fprintln(out, strings.Join(columns, delimiter))
+	}
+
+}
+
+func PrintFileLabel(fl sl.Result) {
+	// true ./123.xlsx 1 [3de9faa6-9fe1-49b3-9a08-227a296b54a6 f49dfc2f-b2b1-4605-accd-09d3ac0089a8]
+	labelsArr := []string{}
+	if !textOutput() {
+		return
+	}
+	for _, label := range fl.Labels {
+		labelStr := strings.ReplaceAll((label.Id + " " + label.SiteId), "{", "")
+		labelStr = strings.ReplaceAll(labelStr, "}", "")
+		labelsArr = append(labelsArr, labelStr)
+	}
+	combinedLabelStr := "[" + strings.Join(labelsArr, ", ") + "]"
+	// resolve ids to names if config provided
+	if config != "" {
+		// for each key in labelConfig.Labels, replace id with name
+		for labelId, labelName := range labelConfig.Labels {
+			combinedLabelStr = strings.ReplaceAll(combinedLabelStr, labelId, labelName)
+		}
+		for tenantId, tenantName := range labelConfig.Tenants {
+			combinedLabelStr = strings.ReplaceAll(combinedLabelStr, tenantId, tenantName)
+		}
+	}
+	// ./123.xlsx true [label1 label2]
+	columns := []string{
+		strconv.FormatBool(fl.LabelInfo),
+		quotePath(fl.FilePath),
+		strconv.Itoa(len(fl.Labels)), // Convert length to string
+		combinedLabelStr,
+	}
+	if fl.Metadata != nil {
+		// quoted as names and dates may be empty or contain spaces
+		columns = append(columns,
+			strconv.Quote(fl.Metadata.Author),
+			strconv.Quote(fl.Metadata.LastModifiedBy),
+			strconv.Quote(fl.Metadata.Company),
+			strconv.Quote(fl.Metadata.Created),
+			strconv.Quote(fl.Metadata.Modified),
+		)
+	}
+	if showTimestamps {
+		// quoted as the set date is often missing
+		columns = append(columns, strconv.Quote(fl.Modified), strconv.Quote(fl.SetDate))
+	}
+	if fl.Statistics != nil {
+		columns = append(columns,
+			strconv.Itoa(fl.Statistics.Pages),
+			strconv.Itoa(fl.Statistics.Words),
+			strconv.Itoa(fl.Statistics.Sheets),
+			strconv.Itoa(fl.Statistics.Slides),
+			strconv.Itoa(fl.Statistics.EmbeddedObjects),
+			strconv.Itoa(fl.Statistics.Media),
+		)
+	}
+	if ownershipMap != nil {
+		columns = append(columns, ownershipColumns(fl.Ownership)...)
+	}
+	row := strings.Join(columns, delimiter)
+	if len(fl.ForbiddenLabels) > 0 || len(fl.Invalid) > 0 || fl.Error != "" {
+		row = colorize(colorRed, row)
+	} else if len(fl.Labels) > 0 {
+		row = colorize(colorGreen, row)
+	} else {
+		row = colorize(colorYellow, row)
+	}
+	fmt.Fprintln(out, row)
+}
+
+// PrintValidationProblems lists the LabelInfo.xml problems found by --validate
+func PrintValidationProblems(fl sl.Result) {
+	if !textOutput() {
+		return
+	}
+	for _, problem := range fl.Invalid {
+		fmt.Fprintln(out, colorize(colorRed, "\tinvalid: "+problem))
+	}
+}
+
+// PrintLabelHistory lists when, how and by whom each label was applied
+func PrintLabelHistory(fl sl.Result) {
+	if !textOutput() {
+		return
+	}
+	for _, h := range fl.History {
+		fmt.Fprintln(out, "\thistory: "+formatHistoryEntry(h))
+	}
+}
+
+func formatHistoryEntry(h sl.LabelHistoryEntry) string {
+	name := h.Name
+	if configName, ok := labelConfig.Labels[h.LabelId]; ok {
+		name = configName
+	}
+	entry := strings.Join([]string{h.SetDate, h.Method, h.LabelId, strconv.Quote(name)}, delimiter)
+	if h.Owner != "" {
+		entry += delimiter + "owner=" + h.Owner
+	}
+	if h.ActionId != "" {
+		entry += delimiter + "actionId=" + h.ActionId
+	}
+	if h.Enabled == "false" {
+		entry += delimiter + "(disabled)"
+	}
+	return entry
+}
+
+// PrintClassificationMarkers lists metadata written by other classification tools
+func PrintClassificationMarkers(fl sl.Result) {
+	if !textOutput() {
+		return
+	}
+	for _, m := range fl.Classification {
+		fmt.Fprintln(out, "\t"+strings.Join([]string{m.Source, m.Part, m.Name + "=" + strconv.Quote(m.Value)}, delimiter))
+	}
+}
+
+// PrintMacros lists the VBA projects of documents containing macros
+func PrintMacros(fl sl.Result) {
+	if !textOutput() || fl.Macros == nil || !fl.Macros.HasMacros {
+		return
+	}
+	line := "\tmacros: " + strings.Join(fl.Macros.Parts, ", ")
+	if fl.Macros.ExtensionMismatch {
+		line = colorize(colorRed, line+" (extension is not macro-enabled)")
+	}
+	fmt.Fprintln(out, line)
+}
+
+// formatLabel renders every attribute of a label, resolving ids to config names
+func formatLabel(label sl.Label) string {
+	id, siteId := sl.NormalizeId(label.Id), sl.NormalizeId(label.SiteId)
+	if name, ok := labelConfig.Labels[id]; ok {
+		id += " (" + name + ")"
+	}
+	if name, ok := labelConfig.Tenants[siteId]; ok {
+		siteId += " (" + name + ")"
+	}
+	formatted := fmt.Sprintf("id=%s siteId=%s enabled=%s method=%s contentBits=%s removed=%s",
+		id, siteId, label.Enabled, label.Method, label.ContentBits.Format(), label.Removed)
+	for _, key := range extraAttrKeys(label) {
+		formatted += " " + key + "=" + label.Extra[key].Value
+	}
+	return formatted
+}
+
+// extraAttrKeys returns the extra attribute names of a label in a stable order
+func extraAttrKeys(label sl.Label) []string {
+	keys := make([]string, 0, len(label.Extra))
+	for key := range label.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintLabelDiff shows the parts sanitized and the label entries a dry-run would change
+func PrintLabelDiff(fl sl.Result) {
+	if !textOutput() {
+		return
+	}
+	for _, change := range fl.Sanitized {
+		fmt.Fprintln(out, "\tsanitize: "+change)
+	}
+	if fl.Diff == nil {
+		return
+	}
+	if len(fl.Diff.Parts) == 0 {
+		fmt.Fprintln(out, "\tno changes")
+		return
+	}
+	for _, part := range fl.Diff.Parts {
+		fmt.Fprintln(out, "\t"+part.Change+" "+part.Part)
+	}
+	for _, label := range fl.Diff.Removed {
+		fmt.Fprintln(out, colorize(colorRed, "\t- "+formatLabel(label)))
+	}
+	for _, label := range fl.Diff.Unchanged {
+		fmt.Fprintln(out, "\t  "+formatLabel(label))
+	}
+	for _, label := range fl.Diff.Added {
+		fmt.Fprintln(out, colorize(colorGreen, "\t+ "+formatLabel(label)))
+	}
+	fmt.Fprintln(out, "\tnew LabelInfo.xml: "+fl.Diff.After)
+}
+
+// jsonReport is the --json output when report sections are requested,
+// otherwise only the results array is written
+type jsonReport struct {
+	Results     []sl.Result          `json:"results"`
+	Summary     *sl.Summary          `json:"summary,omitempty"`
+	GroupBy     string               `json:"groupBy,omitempty"`
+	Groups      []sl.Group           `json:"groups,omitempty"`
+	Top         *sl.TopFiles         `json:"topUnlabeled,omitempty"`
+	Directories []sl.DirectoryRollup `json:"directories,omitempty"`
+}
+
+func PrintFileLabelsJson(report jsonReport) {
+	if !showJson {
+		return
+	}
+	var v any = report.Results
+	if report.Summary != nil || report.Groups != nil || report.Top != nil || report.Directories != nil {
+		v = report
+	}
+	jsonBytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		exitError(err)
+	}
+	fmt.Fprintln(out, string(jsonBytes))
+}
+
+func PrintForbiddenLabels(fileLabels []sl.Result) {
+	if !textOutput() {
+		return
+	}
+	fmt.Fprintln(out, colorize(colorRed, "\nForbidden labels:"))
+	for _, fl := range fileLabels {
+		for _, label := range fl.ForbiddenLabels {
+			fmt.Fprintln(out, colorize(colorRed, strings.Join([]string{
+				quotePath(fl.FilePath),
+				sl.NormalizeId(label.Id),
+				sl.NormalizeId(label.SiteId),
+			}, delimiter)))
+		}
+	}
+}
+
+// PrintTenantMismatches lists labels of unexpected tenants on stderr, they are
+// warnings and stay out of the results piped from stdout
+func PrintTenantMismatches(fileLabels []sl.Result) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nUnexpected tenants: %d files\n", len(fileLabels))
+	for _, fl := range fileLabels {
+		for _, label := range fl.TenantMismatch {
+			fmt.Fprintln(os.Stderr, "\t"+strings.Join([]string{
+				quotePath(fl.FilePath),
+				sl.NormalizeId(label.Id),
+				sl.NormalizeId(label.SiteId),
+			}, delimiter))
+		}
+	}
+}
+
+// PrintSummary prints the totals of a scan after the results, with label and
+// tenant names from --config, most common first
+func PrintSummary(s *sl.Summary) {
+	if s == nil || !textOutput() {
+		return
+	}
+	fmt.Fprintln(out, "\nSummary:")
+	fmt.Fprintf(out, "\tfiles: %d (%d labeled, %d unlabeled, %d errors, %d skipped)\n", s.Files, s.Labeled, s.Unlabeled, s.Errors, s.Skipped)
+	if s.Files > 0 {
+		fmt.Fprintf(out, "\tcoverage: %.1f%%\n", 100*float64(s.Labeled)/float64(s.Files))
+	}
+	fmt.Fprintf(out, "\tbytes: %d\n", s.Bytes)
+	fmt.Fprintf(out, "\tduration: %s\n", time.Duration(s.DurationMs)*time.Millisecond)
+	for _, id := range sortedByCount(s.Labels) {
+		fmt.Fprintf(out, "\tlabel %s: %d\n", withName(id, labelConfig.Labels), s.Labels[id])
+	}
+	for _, id := range sortedByCount(s.Tenants) {
+		fmt.Fprintf(out, "\ttenant %s: %d\n", withName(id, labelConfig.Tenants), s.Tenants[id])
+	}
+	for _, category := range sortedByCount(s.ErrorsBy) {
+		fmt.Fprintf(out, "\terrors %s: %d\n", category, s.ErrorsBy[category])
+	}
+	for _, reason := range sortedByCount(s.Reasons) {
+		fmt.Fprintf(out, "\treason %s: %d\n", reason, s.Reasons[reason])
+	}
+}
+
+// PrintGroups prints the --group-by rollup, label and tenant keys with --config names
+func PrintGroups(by string, groups []sl.Group) {
+	if groups == nil || !textOutput() {
+		return
+	}
+	fmt.Fprintln(out, "\nGroups by "+by+":")
+	header := []string{"Key", "Files", "Labeled", "Unlabeled", "Errors", "Coverage"}
+	if ownershipMap != nil && by == sl.GroupByDirectory {
+		header = append(header, ownershipHeader...)
+	}
+	fmt.Fprintln(out, strings.Join(header, delimiter))
+	for _, g := range groups {
+		key := g.Key
+		switch by {
+		case sl.GroupByLabel:
+			key = withName(key, labelConfig.Labels)
+		case sl.GroupByTenant:
+			key = withName(key, labelConfig.Tenants)
+		case sl.GroupByDirectory:
+			key = quotePath(key)
+		}
+		columns := []string{
+			key,
+			strconv.Itoa(g.Files),
+			strconv.Itoa(g.Labeled),
+			strconv.Itoa(g.Unlabeled),
+			strconv.Itoa(g.Errors),
+			fmt.Sprintf("%.1f%%", 100*float64(g.Labeled)/float64(g.Files)),
+		}
+		if ownershipMap != nil && by == sl.GroupByDirectory {
+			columns = append(columns, ownershipColumns(g.Ownership)...)
+		}
+		fmt.Fprintln(out, strings.Join(columns, delimiter))
+	}
+}
+
+// PrintDirectoryRollups prints a coverage line per directory, totals include subdirectories
+func PrintDirectoryRollups(rollups []sl.DirectoryRollup) {
+	if rollups == nil || !textOutput() {
+		return
+	}
+	fmt.Fprintln(out, "\nDirectories:")
+	header := []string{"Directory", "Files", "Labeled", "Unlabeled", "Errors", "Coverage", "DominantLabel"}
+	if ownershipMap != nil {
+		header = append(header, ownershipHeader...)
+	}
+	fmt.Fprintln(out, strings.Join(header, delimiter))
+	for _, r := range rollups {
+		dominant := "-"
+		if r.DominantLabel != "" {
+			dominant = withName(r.DominantLabel, labelConfig.Labels)
+		}
+		columns := []string{
+			quotePath(r.Directory),
+			strconv.Itoa(r.Files),
+			strconv.Itoa(r.Labeled),
+			strconv.Itoa(r.Unlabeled),
+			strconv.Itoa(r.Errors),
+			percent(r.Labeled, r.Files),
+			dominant,
+		}
+		if ownershipMap != nil {
+			columns = append(columns, ownershipColumns(r.Ownership)...)
+		}
+		fmt.Fprintln(out, strings.Join(columns, delimiter))
+	}
+}
+
+// columns added by --ownership
+var ownershipHeader = []string{"Department", "Owner", "Steward"}
+
+// ownershipColumns are quoted as names contain spaces and are often missing
+func ownershipColumns(o *sl.Ownership) []string {
+	if o == nil {
+		o = &sl.Ownership{}
+	}
+	return []string{strconv.Quote(o.Department), strconv.Quote(o.Owner), strconv.Quote(o.Steward)}
+}
+
+// PrintTopFiles lists the largest and most recently modified unlabeled files
+func PrintTopFiles(top *sl.TopFiles) {
+	if top == nil || !textOutput() {
+		return
+	}
+	fmt.Fprintln(out, "\nLargest unlabeled files:")
+	for _, fl := range top.Largest {
+		fmt.Fprintln(out, strings.Join([]string{quotePath(fl.FilePath), strconv.FormatInt(fl.Bytes, 10), fl.Modified}, delimiter))
+	}
+	fmt.Fprintln(out, "\nMost recently modified unlabeled files:")
+	for _, fl := range top.Recent {
+		fmt.Fprintln(out, strings.Join([]string{quotePath(fl.FilePath), strconv.FormatInt(fl.Bytes, 10), fl.Modified}, delimiter))
+	}
+}
+
+// sortedByCount returns the keys of counts, highest count first then by key
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// resolveNames sets the --config label and tenant names of labels alongside their IDs
+// so structured output can be keyed on either
+func resolveNames(labels []sl.Label) []sl.Label {
+	if len(labels) == 0 || (len(labelConfig.Labels) == 0 && len(labelConfig.Tenants) == 0) {
+		return labels
+	}
+	resolved := make([]sl.Label, len(labels))
+	for i, label := range labels {
+		label.Name = configName(labelConfig.Labels, label.Id)
+		if label.SiteId != "" {
+			label.TenantName = configName(labelConfig.Tenants, label.SiteId)
+		}
+		resolved[i] = label
+	}
+	return resolved
+}
+
+// resolveResultNames resolves the names of every label list of a result
+func resolveResultNames(fl sl.Result) sl.Result {
+	fl.Labels = resolveNames(fl.Labels)
+	fl.ForbiddenLabels = resolveNames(fl.ForbiddenLabels)
+	fl.TenantMismatch = resolveNames(fl.TenantMismatch)
+	if fl.Diff != nil {
+		diff := *fl.Diff
+		diff.Removed = resolveNames(diff.Removed)
+		diff.Added = resolveNames(diff.Added)
+		diff.Unchanged = resolveNames(diff.Unchanged)
+		fl.Diff = &diff
+	}
+	return fl
+}
+
+// withName appends the --config name of an ID
+func withName(id string, names map[string]string) string {
+	if name := configName(names, id); name != "" {
+		return id + " (" + name + ")"
+	}
+	return id
+}
+
+// withReason prefixes the error of a result with its reason code
+func withReason(fl sl.Result) string {
+	if fl.Reason == "" {
+		return fl.Error
+	}
+	return "[" + fl.Reason + "] " + fl.Error
+}
+
+// PrintErrorSummary lists failed files grouped by error category on stderr
+func PrintErrorSummary(fileLabels []sl.Result) {
+	categories := []string{}
+	byCategory := map[string][]sl.Result{}
+	for _, fl := range fileLabels {
+		if _, ok := byCategory[fl.ErrorCategory]; !ok {
+			categories = append(categories, fl.ErrorCategory)
+		}
+		byCategory[fl.ErrorCategory] = append(byCategory[fl.ErrorCategory], fl)
+	}
+	fmt.Fprintf(os.Stderr, "\nErrors: %d files failed\n", len(fileLabels))
+	for _, category := range categories {
+		fmt.Fprintf(os.Stderr, "%s: %d\n", category, len(byCategory[category]))
+		for _, fl := range byCategory[category] {
+			fmt.Fprintln(os.Stderr, "\t"+quotePath(fl.FilePath)+": "+withReason(fl))
+		}
+	}
+}
+
+// PrintSkipSummary lists files that were intentionally left untouched on stderr
+func PrintSkipSummary(fileLabels []sl.Result) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nSkipped: %d files\n", len(fileLabels))
+	for _, fl := range fileLabels {
+		fmt.Fprintln(os.Stderr, "\t"+quotePath(fl.FilePath)+": "+fl.Skipped)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// unusualPaths are file names that must not break a row of any output format
+var unusualPaths = []struct {
+	name string
+	path string
+}{
+	{"space", "dir/a b.docx"},
+	{"quote", `dir/a"b.docx`},
+	{"tab", "dir/a\tb.docx"},
+	{"newline", "dir/a\nb.docx"},
+	{"carriage return", "dir/a\rb.docx"},
+	{"nul", "dir/a\x00b.docx"},
+	{"escape", "dir/a\x1b[31mb.docx"},
+	{"bidi override", "dir/a\u202Excod.docx"},
+	{"pipe", "dir/a|b.docx"},
+	{"backtick", "dir/a`b.docx"},
+	{"invalid utf-8", "dir/a\xffb.docx"},
+	{"truncated utf-8", "dir/a\xe2\x82.docx"},
+}
+
+// captureOutput runs fn with results written to a buffer
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := out
+	out = &buf
+	defer func() { out = previous }()
+	fn()
+	return buf.String()
+}
+
+func TestQuotePath(t *testing.T) {
+	tests := []struct {
+		path   string
+		quoted bool
+	}{
+		{"dir/report.docx", false},
+		{`C:\Users\me\report.docx`, false},
+		{"dir/résumé.docx", false},
+		{"dir/報告.docx", false},
+		{"dir/a b.docx", true},
+		{"dir/a\u00a0b.docx", true},
+		{`dir/a"b.docx`, true},
+		{"dir/a\tb.docx", true},
+		{"dir/a\nb.docx", true},
+		{"dir/a\x00b.docx", true},
+		{"dir/a\x1bb.docx", true},
+		{"dir/a\u202Eb.docx", true},
+		{"dir/a\u200Bb.docx", true},
+		{"dir/a\xffb.docx", true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.path), func(t *testing.T) {
+			got := quotePath(tt.path)
+			if !tt.quoted {
+				if got != tt.path {
+					t.Fatalf("quotePath(%q) = %s, want it unchanged", tt.path, got)
+				}
+				return
+			}
+			if !utf8.ValidString(got) || strings.ContainsAny(got, "\t\n\r\x00\x1b") {
+				t.Fatalf("quotePath(%q) = %q is not printable", tt.path, got)
+			}
+			unquoted, err := strconv.Unquote(got)
+			if err != nil || unquoted != tt.path {
+				t.Fatalf("quotePath(%q) = %s does not unquote to the path: %q, %v", tt.path, got, unquoted, err)
+			}
+		})
+	}
+}
+
+func TestPrintFileLabelQuoting(t *testing.T) {
+	for _, tt := range unusualPaths {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureOutput(t, func() {
+				PrintFileLabel(sl.Result{FilePath: tt.path, Labels: []sl.Label{}})
+			})
+			if strings.Count(got, "\n") != 1 || !strings.HasSuffix(got, "\n") {
+				t.Fatalf("row is not a single line: %q", got)
+			}
+			if !utf8.ValidString(got) || strings.ContainsAny(got, "\t\r\x00\x1b") {
+				t.Fatalf("row is not printable: %q", got)
+			}
+			if !strings.Contains(got, delimiter+quotePath(tt.path)+delimiter) {
+				t.Fatalf("row %q does not contain the quoted path %s", got, quotePath(tt.path))
+			}
+		})
+	}
+}
+
+func TestPrintFileLabelsJsonQuoting(t *testing.T) {
+	showJson = true
+	defer func() { showJson = false }()
+	for _, tt := range unusualPaths {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureOutput(t, func() {
+				PrintFileLabelsJson(jsonReport{Results: []sl.Result{{FilePath: tt.path, Labels: []sl.Label{}}}})
+			})
+			if !utf8.ValidString(got) || strings.ContainsAny(got, "\t\r\x00\x1b") {
+				t.Fatalf("json is not printable: %q", got)
+			}
+			var results []sl.Result
+			if err := json.Unmarshal([]byte(got), &results); err != nil {
+				t.Fatalf("invalid json %q: %v", got, err)
+			}
+			// encoding/json replaces each invalid byte with U+FFFD, as ranging over a string does
+			want := strings.Map(func(r rune) rune { return r }, tt.path)
+			if len(results) != 1 || results[0].FilePath != want {
+				t.Fatalf("json results %+v, want path %q", results, want)
+			}
+		})
+	}
+}
+
+func TestMarkdownQuoting(t *testing.T) {
+	for _, tt := range unusualPaths {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, cell := range []string{mdCode(tt.path), mdEscape(tt.path)} {
+				if !utf8.ValidString(cell) || strings.ContainsAny(cell, "\t\n\r\x00\x1b") {
+					t.Fatalf("cell is not printable: %q", cell)
+				}
+				if strings.Contains(strings.ReplaceAll(cell, `\|`, ""), "|") {
+					t.Fatalf("cell has an unescaped pipe: %q", cell)
+				}
+			}
+		})
+	}
+	if got := mdCode("dir/a`b.docx"); got != "`` dir/a`b.docx ``" {
+		t.Fatalf("mdCode with a backtick = %q", got)
+	}
+	if got := mdCode("dir/a\nb.docx"); got != "`\"dir/a\\nb.docx\"`" {
+		t.Fatalf("mdCode with a newline = %q", got)
+	}
+}
+
+func TestResultsCSVQuoting(t *testing.T) {
+	var results []sl.Result
+	for _, tt := range unusualPaths {
+		results = append(results, sl.Result{FilePath: tt.path, Error: "unable to read " + tt.path})
+	}
+	data, err := resultsCSV(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+	if len(rows) != len(unusualPaths)+1 {
+		t.Fatalf("%d rows, want %d", len(rows), len(unusualPaths)+1)
+	}
+	for i, tt := range unusualPaths {
+		if rows[i+1][0] != tt.path || rows[i+1][6] != "unable to read "+tt.path {
+			t.Errorf("%s: row %q, want path %q", tt.name, rows[i+1], tt.path)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// --paths styles, the default prints paths as they were found
+const (
+	pathsRelative = "relative"
+	pathsAbsolute = "absolute"
+	pathsURI      = "uri"
+)
+
+var pathStyle string
+
+// --shard slice of the files, e.g. 3/8
+var shardSpec string
+
+func checkPathStyle() error {
+	switch pathStyle {
+	case "", pathsRelative, pathsAbsolute, pathsURI:
+		return nil
+	}
+	return fmt.Errorf("invalid --paths value %q, expected relative, absolute or uri", pathStyle)
+}
+
+// formatPath rewrites a scanned file path in the --paths style,
+// relative paths are relative to the scanned directory (or the directory of a scanned file)
+func formatPath(root, filePath string) string {
+	if sl.IsURL(filePath) || sl.IsStorageURL(filePath) {
+		return filePath
+	}
+	switch pathStyle {
+	case pathsRelative:
+		rel, err := filepath.Rel(groupRoot(root), filePath)
+		if err != nil {
+			return filePath
+		}
+		return filepath.ToSlash(rel)
+	case pathsAbsolute:
+		if abs, err := filepath.Abs(filePath); err == nil {
+			return abs
+		}
+	case pathsURI:
+		if abs, err := filepath.Abs(filePath); err == nil {
+			return fileURI(abs)
+		}
+	}
+	return filePath
+}
+
+// fileURI returns the file: URI of an absolute path, UNC paths
+// (\\server\share\dir) become file://server/share/dir
+func fileURI(abs string) string {
+	u := url.URL{Scheme: "file"}
+	slashed := filepath.ToSlash(abs)
+	if strings.HasPrefix(slashed, "//") {
+		host, path, _ := strings.Cut(slashed[2:], "/")
+		u.Host, u.Path = host, "/"+path
+	} else if !strings.HasPrefix(slashed, "/") {
+		// drive letter paths, C:/dir
+		u.Path = "/" + slashed
+	} else {
+		u.Path = slashed
+	}
+	return u.String()
+}
+
+// dfsTargets resolves the roots that are DFS namespace paths to the folder target
+// serving them, roots that are not are left out
+func dfsTargets(roots []scanRoot) map[string]string {
+	targets := map[string]string{}
+	for _, root := range roots {
+		if !sl.IsUNC(root.path) {
+			continue
+		}
+		target, err := sl.ResolveDFS(root.path)
+		if err != nil {
+			warn("unable to resolve DFS path", "path", root.path, "error", err)
+			continue
+		}
+		if target != filepath.Clean(root.path) {
+			logger.Info("DFS path resolved", "path", root.path, "target", target)
+			targets[root.path] = target
+		}
+	}
+	return targets
+}
+
+// dfsTarget maps a file found under a DFS root to the same file under its target
+func dfsTarget(root, target, filePath string) string {
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return ""
+	}
+	return sl.JoinPath(target, filepath.ToSlash(rel))
+}
+
+// shardFiles keeps the files of --shard, paths are hashed relative to their root
+func shardFiles(roots []scanRoot) {
+	if shardSpec == "" {
+		return
+	}
+	index, count, err := sl.ParseShard(shardSpec)
+	if err != nil {
+		exitError(err)
+	}
+	for i, root := range roots {
+		var kept []string
+		for _, filePath := range root.filePaths {
+			rel, err := filepath.Rel(root.path, filePath)
+			if err != nil || sl.IsURL(filePath) || sl.IsStorageURL(filePath) {
+				rel = strings.TrimPrefix(filePath, root.path)
+			}
+			if sl.InShard(rel, index, count) {
+				kept = append(kept, filePath)
+			}
+		}
+		logger.Info("shard", "shard", shardSpec, "path", root.path, "files", len(kept), "of", len(root.filePaths))
+		roots[i].filePaths = kept
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// the path "-" reads a single document from stdin, write commands write the
+// relabeled document to stdout and results to stderr (or --output-file)
+const stdinPath = "-"
+
+// pipeOutput routes results away from stdout when it carries the document
+func pipeOutput(paths []string, update updateFunc) {
+	if update == nil || outputFile != "" {
+		return
+	}
+	for _, path := range paths {
+		if path == stdinPath {
+			out = os.Stderr
+			return
+		}
+	}
+}
+
+var stdinRead bool
+
+// processStdin reads the labels of the document on stdin and, for write commands,
+// writes it to stdout, unchanged when update leaves it untouched or on --dry-run
+func processStdin(cmd string, update updateFunc) sl.Result {
+	fl := sl.Result{FilePath: stdinPath, Labels: []sl.Label{}}
+	flog := logger.With("file", stdinPath)
+	fail := func(category string, err error) sl.Result {
+		flog.Error("file error", "category", category, "error", err)
+		if failFast {
+			exitError(err)
+		}
+		fl.Error = err.Error()
+		fl.ErrorCategory = category
+		return fl
+	}
+	if stdinRead {
+		return fail(errExtract, errors.New("stdin can only be read once"))
+	}
+	stdinRead = true
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fail(errExtract, err)
+	}
+	fl.Bytes = int64(len(data))
+	if category, err := readBytesLabels(flog, &fl, stdinPath, data); err != nil {
+		return fail(category, err)
+	}
+	if update == nil {
+		return fl
+	}
+	written, _, err := relabelBytes(cmd, flog, &fl, stdinPath, data, update)
+	if err != nil {
+		return fail(errWrite, err)
+	}
+	if _, err := os.Stdout.Write(written); err != nil {
+		exitError(fmt.Errorf("unable to write the document to stdout: %w", err))
+	}
+	return fl
+}
+
+// relabelBytes applies update to a document read into memory and returns the
+// relabeled document, or data unchanged when update leaves it untouched or on --dry-run
+func relabelBytes(cmd string, flog *slog.Logger, fl *sl.Result, name string, data []byte, update updateFunc) ([]byte, bool, error) {
+	newLabels, ok := update(*fl)
+	if !ok {
+		return data, false, nil
+	}
+	if err := checkProfileTenant(newLabels); err != nil {
+		return nil, false, err
+	}
+	record := sl.NewAuditRecord(cmd, fl.FilePath, fl.Labels, newLabels.Labels)
+	flog.Info("write", "dryRun", dryrun)
+	if !dryrun {
+		_, written, err := sl.WriteLabelsBytes(name, data, newLabels)
+		audit(flog, record, err)
+		if err != nil {
+			return nil, false, err
+		}
+		data = written
+	} else {
+		audit(flog, record, nil)
+	}
+	fl.LabelInfo = true
+	fl.Labels = newLabels.Labels
+	fl.ForbiddenLabels = sl.FindForbiddenLabels(fl.Labels, denyLabels, denyTenants)
+	fl.TenantMismatch = sl.FindTenantMismatches(fl.Labels, expectedTenant)
+	return data, true, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+// flags for plan, approve and apply
+var planLabel, planTenant string
+var approveKey, approveCert, approveHmacKey string
+var approverCert, approverHmacKey string
+var allowUnsigned bool
+
+func init() {
+	planFlags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	planFlags.StringVar(&planLabel, "label", "", "label ID or name proposed for unlabeled files and files left without labels")
+	planFlags.StringVar(&planTenant, "tenant", "", "tenant ID or name of --label, defaults to --expected-tenant")
+	addCommand(&command{
+		name:    "plan",
+		args:    []string{"path", "plan"},
+		summary: "write a reviewable plan of the label changes policies call for, without modifying any file",
+		examples: []string{
+			`labels.exe plan "path\to\dir" plan.json --recursive --label "Internal" --expected-tenant "Contoso"`,
+			`labels.exe plan "path\to\dir" plan.json --recursive --deny-labels "Legacy Secret"`,
+		},
+		run: runPlan,
+	}, planFlags, scanFlags)
+	approveFlags := flag.NewFlagSet("approve", flag.ContinueOnError)
+	approveFlags.StringVar(&approveKey, "key", "", "path to the approver's PEM private key used to sign the plan")
+	approveFlags.StringVar(&approveCert, "cert", "", "path to a PEM certificate embedded in the signed plan")
+	approveFlags.StringVar(&approveHmacKey, "hmac-key", "", "path to a key file used to sign the plan with HMAC-SHA256")
+	addCommand(&command{
+		name:    "approve",
+		args:    []string{"plan"},
+		summary: "list the changes of a plan and sign it, apply refuses plans modified after approval",
+		examples: []string{
+			`labels.exe approve plan.json --key approver.key --cert approver.crt`,
+		},
+		run: runApprove,
+	}, approveFlags)
+	applyFlags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	applyFlags.StringVar(&approverCert, "approver-cert", "", "path to the PEM certificate or public key of the approver the plan must be signed by")
+	applyFlags.StringVar(&approverHmacKey, "approver-hmac-key", "", "path to the key file the plan must be signed with using HMAC-SHA256")
+	applyFlags.BoolVar(&allowUnsigned, "allow-unsigned", false, "apply plans nobody approved")
+	addCommand(&command{
+		name:    "apply",
+		args:    []string{"plan"},
+		summary: "apply exactly the changes of an approved plan, files changed since the plan was made are not modified",
+		examples: []string{
+			`labels.exe apply plan.json --approver-cert approver.crt --backup "path\to\backup" --audit-log audit.jsonl`,
+		},
+		run: runApply,
+	}, applyFlags, writeFlags, scanFlags)
+}
+
+// plan reasons
+const (
+	reasonUnlabeled      = "unlabeled"
+	reasonForbidden      = "forbidden"
+	reasonTenantMismatch = "tenant-mismatch"
+)
+
+// runPlan scans path like get and records the labels each file would get from
+// --deny-labels, --deny-tenants, --expected-tenant and --label in a plan file
+func runPlan(args []string) {
+	root, planPath := args[0], args[1]
+	extensions := prepareScan()
+	var defaultLabel *sl.Label
+	if planLabel != "" {
+		tenant := expectedTenant
+		if planTenant != "" {
+			tenant = parseIdList(planTenant, labelConfig.Tenants)[0]
+		}
+		if tenant == "" {
+			exitError(fmt.Errorf("--label requires --tenant or --expected-tenant"))
+		}
+		label := newLabel(parseIdList(planLabel, labelConfig.Labels)[0], tenant)
+		defaultLabel = &label
+	}
+
+	plan := sl.NewPlan(runId, root)
+	afterScan = append(afterScan, func() {
+		if err := plan.Write(planPath); err != nil {
+			exitError(err)
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "\nPlan: %d of %d files would change, written to %s\n", len(plan.Changes()), len(plan.Entries), planPath)
+		}
+	})
+	logger.Debug("plan", "path", root, "plan", planPath, "extensions", extensions)
+	scanFiles("plan", []scanRoot{{path: root, filePaths: listFiles(root, extensions)}}, func(fl sl.Result) (sl.Labels, bool) {
+		hash, err := sl.HashFile(fl.FilePath)
+		if err != nil {
+			warn("unable to hash file, left out of the plan", "file", fl.FilePath, "error", err)
+			return sl.Labels{}, false
+		}
+		entry := proposeLabels(fl, defaultLabel)
+		entry.Hash = hash
+		plan.Add(entry)
+		// plan never writes
+		return sl.Labels{}, false
+	})
+}
+
+// proposeLabels drops forbidden labels, retags labels of other tenants and
+// proposes the default label for files left without one
+func proposeLabels(fl sl.Result, defaultLabel *sl.Label) sl.PlanEntry {
+	var reasons []string
+	proposed := []sl.Label{}
+	forbidden := sl.FindForbiddenLabels(fl.Labels, denyLabels, denyTenants)
+	for _, label := range fl.Labels {
+		if len(sl.FindForbiddenLabels([]sl.Label{label}, denyLabels, denyTenants)) == 0 {
+			proposed = append(proposed, label)
+		}
+	}
+	if len(forbidden) > 0 {
+		reasons = append(reasons, reasonForbidden)
+	}
+	if len(sl.FindTenantMismatches(proposed, expectedTenant)) > 0 {
+		proposed = sl.RetagLabels(proposed, expectedTenant).Labels
+		reasons = append(reasons, reasonTenantMismatch)
+	}
+	if len(proposed) == 0 && defaultLabel != nil {
+		proposed = []sl.Label{*defaultLabel}
+		if len(fl.Labels) == 0 {
+			reasons = append(reasons, reasonUnlabeled)
+		}
+	}
+	entry := sl.PlanEntry{
+		Path:     fl.FilePath,
+		Action:   sl.PlanRelabel,
+		Reason:   strings.Join(reasons, ","),
+		Current:  fl.Labels,
+		Proposed: proposed,
+	}
+	switch {
+	case sl.SameLabels(fl.Labels, proposed):
+		entry.Action = sl.PlanKeep
+		entry.Reason = ""
+		entry.Proposed = fl.Labels
+	case len(proposed) == 0:
+		entry.Action = sl.PlanRemove
+	}
+	return entry
+}
+
+// runApply relabels the files of a plan, files whose contents or labels differ
+// from the plan were changed by someone else since and are left untouched
+func runApply(args []string) {
+	plan, err := sl.ReadPlan(args[0])
+	if err != nil {
+		exitError(err)
+	}
+	if err := verifyPlan(plan); err != nil {
+		exitError(fmt.Errorf("%s: %w", args[0], err))
+	}
+	prepareScan()
+	changes := plan.Changes()
+	logger.Debug("apply", "plan", args[0], "runId", plan.RunId, "entries", len(plan.Entries), "changes", len(changes))
+
+	entries := map[string]sl.PlanEntry{}
+	var filePaths []string
+	stale := 0
+	for _, entry := range changes {
+		hash, err := sl.HashFile(entry.Path)
+		if err != nil {
+			warn("file not found, not applied", "file", entry.Path, "error", err)
+			stale++
+			continue
+		}
+		if hash != entry.Hash {
+			warn("file changed since the plan was made, not applied", "file", entry.Path)
+			stale++
+			continue
+		}
+		entries[entry.Path] = entry
+		filePaths = append(filePaths, entry.Path)
+	}
+	if stale > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "Stale: %d files changed since the plan was made\n", stale)
+	}
+	if stale > 0 && len(filePaths) == 0 {
+		exit(sl.ExitFileErrors)
+	}
+
+	scanFiles("apply", []scanRoot{{path: plan.Root, filePaths: filePaths}}, func(fl sl.Result) (sl.Labels, bool) {
+		entry := entries[fl.FilePath]
+		if !sl.SameLabels(entry.Current, fl.Labels) {
+			warn("labels changed since the plan was made, not applied", "file", fl.FilePath)
+			return sl.Labels{}, false
+		}
+		return sl.Labels{Labels: entry.Proposed}, true
+	})
+	if stale > 0 {
+		exit(sl.ExitFileErrors)
+	}
+}
+
+// errApproverRequired rejects a signed plan that apply was given no key to verify,
+// --allow-unsigned only admits plans without a signature
+var errApproverRequired = errors.New("signed plan, --approver-cert or --approver-hmac-key is required to verify it")
+
+// verifyPlan refuses plans that were not approved by the configured approver
+// or were modified after approval, unsigned plans need --allow-unsigned
+func verifyPlan(plan *sl.Plan) error {
+	if plan.Signature == "" && allowUnsigned {
+		warn("applying a plan nobody approved")
+		return nil
+	}
+	if plan.Signature != "" && approverCert == "" && approverHmacKey == "" {
+		return errApproverRequired
+	}
+	var hmacKey, pubPEM []byte
+	var err error
+	if approverHmacKey != "" {
+		if hmacKey, err = os.ReadFile(approverHmacKey); err != nil {
+			return err
+		}
+	}
+	if approverCert != "" {
+		if pubPEM, err = os.ReadFile(approverCert); err != nil {
+			return err
+		}
+	}
+	if err := plan.Verify(hmacKey, pubPEM); err != nil {
+		if errors.Is(err, sl.ErrUnsignedPlan) {
+			return fmt.Errorf("%w, run labels.exe approve or use --allow-unsigned", err)
+		}
+		return fmt.Errorf("%w: the plan was modified after approval or signed by another approver", err)
+	}
+	logger.Info("plan approved", "approved", plan.Approved, "signatureAlg", plan.SignatureAlg)
+	return nil
+}
+
+// runApprove prints the changes of a plan and signs it in place
+func runApprove(args []string) {
+	plan, err := sl.ReadPlan(args[0])
+	if err != nil {
+		exitError(err)
+	}
+	for _, entry := range plan.Changes() {
+		fmt.Fprintln(out, entry.Action+delimiter+quotePath(entry.Path)+delimiter+planLabels(entry.Proposed)+delimiter+entry.Reason)
+	}
+	switch {
+	case approveHmacKey != "":
+		key, err := os.ReadFile(approveHmacKey)
+		if err != nil {
+			exitError(err)
+		}
+		err = plan.SignHMAC(key)
+		if err != nil {
+			exitError(err)
+		}
+	case approveKey != "":
+		keyPEM, err := os.ReadFile(approveKey)
+		if err != nil {
+			exitError(err)
+		}
+		var certPEM []byte
+		if approveCert != "" {
+			if certPEM, err = os.ReadFile(approveCert); err != nil {
+				exitError(err)
+			}
+		}
+		if err := plan.SignX509(keyPEM, certPEM); err != nil {
+			exitError(err)
+		}
+	default:
+		printCommandUsage(findCommand("approve"), "Error: --key or --hmac-key is required")
+		exit(sl.ExitUsage)
+	}
+	if err := plan.Write(args[0]); err != nil {
+		exitError(err)
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "\nApproved: %d of %d files change, signed with %s\n", len(plan.Changes()), len(plan.Entries), plan.SignatureAlg)
+	}
+}
+
+// planLabels renders labels like get output, "[label tenant, ...]" with config names
+func planLabels(labels []sl.Label) string {
+	var labelsArr []string
+	for _, label := range resolveNames(labels) {
+		labelsArr = append(labelsArr, orDefault(label.Name, sl.NormalizeId(label.Id))+" "+orDefault(label.TenantName, sl.NormalizeId(label.SiteId)))
+	}
+	return "[" + strings.Join(labelsArr, ", ") + "]"
+}
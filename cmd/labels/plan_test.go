@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// setApprover sets the approval flags of apply for a test
+func setApprover(t *testing.T, hmacKey, cert string, unsigned bool) {
+	t.Helper()
+	prevKey, prevCert, prevUnsigned := approverHmacKey, approverCert, allowUnsigned
+	approverHmacKey, approverCert, allowUnsigned = hmacKey, cert, unsigned
+	t.Cleanup(func() { approverHmacKey, approverCert, allowUnsigned = prevKey, prevCert, prevUnsigned })
+}
+
+func TestVerifyPlan(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "approver.key")
+	if err := os.WriteFile(keyPath, []byte("approver key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	otherKeyPath := filepath.Join(t.TempDir(), "other.key")
+	if err := os.WriteFile(otherKeyPath, []byte("other key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signed := sl.NewPlan("run-1", "dir")
+	signed.Add(sl.PlanEntry{Path: "dir/report.docx", Action: sl.PlanRemove})
+	if err := signed.SignHMAC([]byte("approver key")); err != nil {
+		t.Fatal(err)
+	}
+	unsigned := sl.NewPlan("run-1", "dir")
+
+	tests := []struct {
+		name     string
+		plan     *sl.Plan
+		hmacKey  string
+		unsigned bool
+		wantErr  error // nil when the plan is accepted
+	}{
+		{"signed with the approver key", signed, keyPath, false, nil},
+		{"signed with another key", signed, otherKeyPath, false, sl.ErrSignature},
+		{"signed without --approver-*", signed, "", false, errApproverRequired},
+		{"signed without --approver-* and --allow-unsigned", signed, "", true, errApproverRequired},
+		{"unsigned", unsigned, keyPath, false, sl.ErrUnsignedPlan},
+		{"unsigned with --allow-unsigned", unsigned, "", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setApprover(t, tt.hmacKey, "", tt.unsigned)
+			err := verifyPlan(tt.plan)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("verifyPlan() = %v, want the plan accepted", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyPlan() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// --plugin executables, handlers are registered and sinks receive every result
+var plugins []string
+var sinks []*sl.PluginSink
+var sinksAtExit bool
+
+// checkOffline fails --offline runs configuring anything that may access the network,
+// labels.exe itself only reads and writes local and mounted files, plugins are
+// arbitrary executables so they are refused altogether, as are mailing reports
+// and downloading documents
+func checkOffline(args []string) error {
+	if offline && len(plugins) > 0 {
+		return fmt.Errorf("--offline: plugins may access the network, remove --plugin %s", strings.Join(plugins, ", "))
+	}
+	if offline && (len(mailTo) > 0 || smtpServer != "") {
+		return fmt.Errorf("--offline: mailing reports accesses the network, remove --mail-to and --smtp-server")
+	}
+	for _, arg := range append(args, rootPaths...) {
+		if offline && (sl.IsURL(arg) || sl.IsStorageURL(arg)) {
+			return fmt.Errorf("--offline: %s is on the network", arg)
+		}
+	}
+	if offline && urlList != "" {
+		return fmt.Errorf("--offline: remove --url-list")
+	}
+	return nil
+}
+
+func loadPlugins() error {
+	for _, path := range plugins {
+		desc, err := sl.DescribePlugin(path)
+		if err != nil {
+			return err
+		}
+		logger.Debug("plugin", "path", path, "name", desc.Name, "type", desc.Type)
+		if desc.Type == sl.PluginTypeHandler {
+			sl.RegisterHandler(desc.Name, sl.NewPluginHandler(path, desc))
+			continue
+		}
+		sink, err := sl.StartPluginSink(path, desc.Name)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) > 0 && !sinksAtExit {
+		atExit = append(atExit, closeSinks)
+		sinksAtExit = true
+	}
+	return nil
+}
+
+// sendToSinks passes a result to every sink plugin
+func sendToSinks(fl sl.Result) {
+	for _, sink := range sinks {
+		err := sink.Send(fl)
+		health.check("sink:"+sink.Name, err)
+		if err != nil {
+			warn("unable to send result to sink", "plugin", sink.Name, "error", err)
+		}
+	}
+}
+
+func closeSinks() {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			warn("sink plugin failed", "plugin", sink.Name, "error", err)
+		}
+	}
+	sinks = nil
+}
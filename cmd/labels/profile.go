@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// LabelsProfile bundles the settings of one customer or tenant in the config file,
+// selected with --profile so a single config can serve many tenants
+type LabelsProfile struct {
+	Tenant  string                 `json:"tenant"`  // the only tenant labels may be written for
+	Labels  map[string]string      `json:"labels"`  // label catalog, merged over the top level labels
+	Tenants map[string]string      `json:"tenants"` // merged over the top level tenants
+	Flags   map[string]interface{} `json:"flags"`   // default policies and sinks, e.g. deny-labels or plugin
+}
+
+var profile string
+
+// applyProfile merges the selected profile over the config, a missing config
+// or profile is an error so a run never falls back to another tenant's settings
+func applyProfile() error {
+	if profile == "" {
+		profile = os.Getenv(envName("profile"))
+	}
+	if profile == "" {
+		return nil
+	}
+	if config == "" {
+		return fmt.Errorf("--profile %s requires a readable --config", profile)
+	}
+	p, ok := labelConfig.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", profile, config)
+	}
+	labelConfig.Labels = mergeNames(labelConfig.Labels, p.Labels)
+	labelConfig.Tenants = mergeNames(labelConfig.Tenants, p.Tenants)
+	flags := map[string]interface{}{}
+	for name, value := range labelConfig.Flags {
+		flags[name] = value
+	}
+	for name, value := range p.Flags {
+		flags[name] = value
+	}
+	if p.Tenant != "" {
+		p.Tenant = parseIdList(p.Tenant, labelConfig.Tenants)[0]
+		if _, ok := flags["expected-tenant"]; !ok {
+			flags["expected-tenant"] = p.Tenant
+		}
+	}
+	labelConfig.Flags = flags
+	profileTenant = p.Tenant
+	logger.Debug("profile", "profile", profile, "tenant", p.Tenant, "labels", len(p.Labels), "flags", len(p.Flags))
+	return nil
+}
+
+func mergeNames(base, overlay map[string]string) map[string]string {
+	merged := map[string]string{}
+	for id, name := range base {
+		merged[id] = name
+	}
+	for id, name := range overlay {
+		merged[id] = name
+	}
+	return merged
+}
+
+// tenant of the selected profile, labels of other tenants are never written
+var profileTenant string
+
+func checkProfileTenant(newLabels sl.Labels) error {
+	if profileTenant == "" {
+		return nil
+	}
+	for _, label := range newLabels.Labels {
+		if sl.NormalizeId(label.SiteId) != sl.NormalizeId(profileTenant) {
+			return fmt.Errorf("label %s belongs to tenant %s, profile %s only allows %s",
+				strings.Trim(label.Id, "{}"), strings.Trim(label.SiteId, "{}"), profile, profileTenant)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// --progress formats, progress is not reported by default
+const progressJson = "json"
+
+var progressFormat string
+
+func checkProgressFormat() error {
+	switch progressFormat {
+	case "", progressJson:
+		return nil
+	}
+	return fmt.Errorf("invalid --progress value %q, expected json", progressFormat)
+}
+
+// progressEvent is one JSONL line written to stderr with --progress json
+type progressEvent struct {
+	Event   string  `json:"event"`
+	RunId   string  `json:"runId"`
+	Scanned int     `json:"scanned"`
+	Total   int     `json:"total"`
+	Path    string  `json:"path,omitempty"`
+	Rate    float64 `json:"rate"` // files per second
+	EtaMs   int64   `json:"etaMs"`
+}
+
+// progress reports scanned files against the total once started
+type progress struct {
+	total int
+	start time.Time
+	enc   *json.Encoder
+}
+
+func newProgress(total int) *progress {
+	if progressFormat != progressJson {
+		return nil
+	}
+	p := &progress{total: total, start: time.Now(), enc: json.NewEncoder(os.Stderr)}
+	p.emit("start", 0, "")
+	return p
+}
+
+// file reports a completed file, scanned counts every file completed so far
+func (p *progress) file(scanned int, filePath string) {
+	if p != nil {
+		p.emit("progress", scanned, filePath)
+	}
+}
+
+func (p *progress) done(scanned int) {
+	if p != nil {
+		p.emit("done", scanned, "")
+	}
+}
+
+func (p *progress) emit(event string, scanned int, filePath string) {
+	ev := progressEvent{Event: event, RunId: runId, Scanned: scanned, Total: p.total, Path: filePath}
+	// the total of distributed scans is unknown
+	if elapsed := time.Since(p.start).Seconds(); scanned > 0 && elapsed > 0 && p.total >= scanned {
+		rate := float64(scanned) / elapsed
+		ev.Rate = math.Round(rate*100) / 100
+		ev.EtaMs = int64(float64(p.total-scanned) / rate * 1000)
+	}
+	if err := p.enc.Encode(ev); err != nil {
+		logger.Debug("progress event not written", "error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+var quarantineCopy bool
+
+func init() {
+	quarantineFlags := flag.NewFlagSet("quarantine", flag.ContinueOnError)
+	quarantineFlags.BoolVar(&quarantineCopy, "copy", false, "copy files into the quarantine directory instead of moving them")
+	addCommand(&command{
+		name:    "quarantine",
+		args:    []string{"path", "quarantineDir"},
+		summary: "move files carrying forbidden labels or labels of other tenants into a quarantine directory, keeping their relative paths",
+		examples: []string{
+			`labels.exe quarantine "path\to\dir" "path\to\quarantine" --recursive --deny-labels "Legacy Secret" --audit-log audit.jsonl`,
+			`labels.exe quarantine "path\to\dir" "path\to\quarantine" --recursive --expected-tenant "Contoso" --copy`,
+		},
+		run: runQuarantine,
+	}, quarantineFlags, writeFlags, scanFlags)
+}
+
+// quarantine candidate, the labels are recorded in the audit log
+type quarantined struct {
+	filePath string
+	labels   []sl.Label
+}
+
+// runQuarantine scans path and, once every file was read, moves the files failing
+// --deny-labels, --deny-tenants or --expected-tenant below quarantineDir
+func runQuarantine(args []string) {
+	root, quarantineDir := args[0], args[1]
+	extensions := prepareScan()
+	if len(denyLabels) == 0 && len(denyTenants) == 0 && expectedTenant == "" {
+		printCommandUsage(findCommand("quarantine"), "Error: --deny-labels, --deny-tenants or --expected-tenant is required")
+		exit(sl.ExitUsage)
+	}
+
+	var candidates []quarantined
+	afterScan = append(afterScan, func() {
+		quarantineFiles(root, quarantineDir, candidates)
+	})
+	logger.Debug("quarantine", "path", root, "quarantineDir", quarantineDir, "copy", quarantineCopy)
+	scanFiles("quarantine", []scanRoot{{path: root, filePaths: listFiles(root, extensions)}}, func(fl sl.Result) (sl.Labels, bool) {
+		if len(sl.FindForbiddenLabels(fl.Labels, denyLabels, denyTenants)) > 0 || len(sl.FindTenantMismatches(fl.Labels, expectedTenant)) > 0 {
+			candidates = append(candidates, quarantined{filePath: fl.FilePath, labels: fl.Labels})
+		}
+		// labels are left untouched, the file is moved after the scan
+		return sl.Labels{}, false
+	})
+}
+
+func quarantineFiles(root, quarantineDir string, candidates []quarantined) {
+	moved, failed := 0, 0
+	for _, c := range candidates {
+		flog := logger.With("file", c.filePath)
+		if pattern, ok := exclusions.Match(c.filePath); ok {
+			flog.Warn("file is on the exclusion list and was not quarantined", "pattern", pattern)
+			continue
+		}
+		record := sl.NewAuditRecord("quarantine", c.filePath, c.labels, c.labels)
+		if dryrun {
+			audit(flog, record, nil)
+			fmt.Fprintln(out, "quarantine"+delimiter+quotePath(c.filePath))
+			continue
+		}
+		quarantinePath, err := sl.QuarantineFile(c.filePath, groupRoot(root), quarantineDir, !quarantineCopy)
+		record.QuarantinePath = quarantinePath
+		audit(flog, record, err)
+		if err != nil {
+			flog.Error("unable to quarantine file", "error", err)
+			failed++
+			continue
+		}
+		flog.Info("quarantined", "quarantinePath", quarantinePath)
+		fmt.Fprintln(out, "quarantine"+delimiter+quotePath(c.filePath)+delimiter+quotePath(quarantinePath))
+		moved++
+	}
+	if !quiet {
+		action := "moved"
+		if quarantineCopy {
+			action = "copied"
+		}
+		fmt.Fprintf(os.Stderr, "\nQuarantined: %d of %d files %s to %s\n", moved, len(candidates), action, quarantineDir)
+	}
+	if failed > 0 {
+		exit(sl.ExitFileErrors)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// --config is reread on SIGHUP and when the file changes, checked every --config-poll,
+// a new config applies from the next file on
+var configPoll time.Duration
+
+// configVersion identifies the config in use, the start of the SHA-256 of the file
+var configVersion string
+
+// reloadPending is set by SIGHUP and the config poller, the scanning goroutine
+// applies it before the next file so a file is never processed with two configs
+var reloadPending atomic.Bool
+
+// reloadFlags are the policy and sink flags taken from a reloaded config file,
+// other flags keep the values the run started with
+var reloadFlags = []string{"deny-labels", "deny-tenants", "expected-tenant", "plugin", "mail-to", "mail-from", "smtp-server"}
+
+// flagSource records flags not set on the command line: "env", "config" or "argument",
+// only flags from the config file or left unset are reloaded
+var flagSource = map[string]string{}
+
+// activeFlags are the flags of the running command
+var activeFlags *flag.FlagSet
+
+// readConfig parses a config file, unlike loadConfig invalid JSON is an error
+func readConfig(path string) (LabelsConfig, string, error) {
+	var cfg LabelsConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, "", err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, "", err
+	}
+	return cfg, configFileVersion(data), nil
+}
+
+func configFileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// watchConfig requests a reload on SIGHUP and when --config changes
+func watchConfig(fs *flag.FlagSet) {
+	if config == "" {
+		return
+	}
+	activeFlags = fs
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("received SIGHUP, reloading config before the next file", "config", config)
+			reloadPending.Store(true)
+		}
+	}()
+	if configPoll <= 0 {
+		return
+	}
+	go func() {
+		last, _ := os.Stat(config)
+		ticker := time.NewTicker(configPoll)
+		defer ticker.Stop()
+		for range ticker.C {
+			info, err := os.Stat(config)
+			if err != nil {
+				continue
+			}
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				logger.Debug("config changed", "config", config)
+				last = info
+				reloadPending.Store(true)
+			}
+		}
+	}()
+}
+
+// applyConfigReload rereads --config when a reload is pending, an unreadable or
+// invalid file, or one --offline refuses, keeps the current config
+func applyConfigReload() {
+	if !reloadPending.CompareAndSwap(true, false) {
+		return
+	}
+	cfg, version, err := readConfig(config)
+	if err != nil {
+		warn("unable to reload config, keeping the current config", "config", config, "version", configVersion, "error", err)
+		return
+	}
+	if version == configVersion {
+		return
+	}
+	previous, previousTenant, previousPlugins := labelConfig, profileTenant, slices.Clone(plugins)
+	labelConfig = cfg
+	err = applyProfile()
+	var changed []string
+	if err == nil {
+		changed, err = applyConfigFlags()
+	}
+	if err == nil {
+		err = checkOffline(nil)
+	}
+	if err == nil {
+		err = resolvePolicy()
+	}
+	if err != nil {
+		labelConfig, profileTenant = previous, previousTenant
+		applyConfigFlags()
+		resolvePolicy()
+		warn("unable to reload config, keeping the current config", "config", config, "version", configVersion, "error", err)
+		return
+	}
+	if !slices.Equal(plugins, previousPlugins) {
+		closeSinks()
+		if err := loadPlugins(); err != nil {
+			warn("unable to start plugins of the reloaded config", "error", err)
+		}
+	}
+	logger.Info("config reloaded", "config", config, "version", version, "previousVersion", configVersion, "changedFlags", changed)
+	configVersion = version
+	health.setConfigVersion(version)
+}
+
+// applyConfigFlags sets the reloadable flags from the config, including the
+// --profile flags, flags the config no longer sets return to their defaults
+func applyConfigFlags() ([]string, error) {
+	var changed []string
+	for _, name := range reloadFlags {
+		f := activeFlags.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if source := flagSource[name]; source != "config" && (source != "" || f.Changed) {
+			continue
+		}
+		before := f.Value.String()
+		value, ok := labelConfig.Flags[name]
+		if sv, isSlice := f.Value.(flag.SliceValue); isSlice {
+			var items []string
+			if list, isList := value.([]interface{}); isList {
+				for _, item := range list {
+					items = append(items, fmt.Sprint(item))
+				}
+			} else if ok {
+				items = strings.Split(fmt.Sprint(value), ",")
+			}
+			if err := sv.Replace(items); err != nil {
+				return changed, fmt.Errorf("invalid value for %s in %s: %w", name, config, err)
+			}
+		} else {
+			if !ok {
+				value = f.DefValue
+			}
+			if err := f.Value.Set(fmt.Sprint(value)); err != nil {
+				return changed, fmt.Errorf("invalid value for %s in %s: %w", name, config, err)
+			}
+		}
+		flagSource[name] = "config"
+		if f.Value.String() != before {
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+var stripCsv = "authors,comments,track-changes,custom-properties"
+
+// set by sanitize, applyLabels strips metadata before writing
+var sanitizeOpts *sl.SanitizeOptions
+
+// sanitize without a label keeps LabelInfo.xml exactly as it is
+var preserveLabels bool
+
+func init() {
+	sanitizeFlags := flag.NewFlagSet("sanitize", flag.ContinueOnError)
+	sanitizeFlags.StringVar(&stripCsv, "strip", stripCsv, "metadata to strip: authors, comments, track-changes, custom-properties")
+	addCommand(&command{
+		name:     "sanitize",
+		args:     []string{"path"},
+		optional: []string{"labelId", "tenantId"},
+		summary:  "strip metadata from documents before external release, preserving labels or applying the provided label",
+		examples: []string{
+			`labels.exe sanitize "path\to\dir" --strip authors,comments --dry-run`,
+			`labels.exe sanitize "path\to\file.docx" "1234-label-id-1234" "4321-tenant-id-4321"`,
+		},
+		run: runSanitize,
+	}, sanitizeFlags, writeFlags, scanFlags)
+}
+
+func runSanitize(args []string) {
+	opts, err := sl.ParseSanitizeOptions(stripCsv)
+	if err != nil {
+		exitError(err)
+	}
+	sanitizeOpts = &opts
+	switch len(args) {
+	case 1:
+		preserveLabels = true
+		scan("sanitize", args[0], func(fl sl.Result) (sl.Labels, bool) {
+			return sl.Labels{Labels: fl.Labels}, true
+		})
+	case 3:
+		labelId, tenantId := args[1], args[2]
+		scan("sanitize", args[0], func(fl sl.Result) (sl.Labels, bool) {
+			return sl.Labels{Labels: []sl.Label{newLabel(labelId, tenantId)}}, true
+		})
+	default:
+		printCommandUsage(findCommand("sanitize"), "Error: labelId and tenantId must be provided together")
+		exit(sl.ExitUsage)
+	}
+}
+
+// sanitizeFile strips metadata from the extracted document,
+// returns false when there is nothing to write
+func sanitizeFile(fl *sl.Result, tmpUnzipDir string) (bool, error) {
+	changes, err := sl.Sanitize(tmpUnzipDir, *sanitizeOpts)
+	if err != nil {
+		return false, fmt.Errorf("sanitize: %w", err)
+	}
+	fl.Sanitized = changes
+	return len(changes) > 0 || !preserveLabels, nil
+}
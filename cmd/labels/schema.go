@@ -0,0 +1,21 @@
+package main
+
+import (
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+func init() {
+	addCommand(&command{
+		name:    "schema",
+		summary: "print the JSON Schema of the --json output",
+		examples: []string{
+			`labels.exe schema > labels.schema.json`,
+		},
+		run: runSchema,
+	})
+}
+
+func runSchema(args []string) {
+	logger.Debug("schema", "version", sl.OutputSchemaVersion)
+	out.Write(sl.OutputSchema)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// cancelled is set on the first SIGINT/SIGTERM, the file being processed
+// is finished and no new files are started
+var cancelled atomic.Bool
+
+// writing is held while a file is written and verified, the second signal
+// waits for it so documents aren't left truncated
+var writing sync.RWMutex
+
+// temporary extraction directories currently in use
+var tmpDirs = map[string]bool{}
+var tmpDirsMu sync.Mutex
+
+func trackTmpDir(path string) {
+	tmpDirsMu.Lock()
+	tmpDirs[path] = true
+	tmpDirsMu.Unlock()
+}
+
+func untrackTmpDir(path string) {
+	tmpDirsMu.Lock()
+	delete(tmpDirs, path)
+	tmpDirsMu.Unlock()
+}
+
+// watchSignals stops the run gracefully on the first signal,
+// a second signal waits for the write in progress, removes temporary
+// directories and exits
+func watchSignals() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		cancelled.Store(true)
+		warn("received signal, finishing the current file", "signal", sig.String())
+		sig = <-c
+		warn("received second signal, exiting", "signal", sig.String())
+		writing.Lock()
+		tmpDirsMu.Lock()
+		for path := range tmpDirs {
+			cleanup(path)
+		}
+		tmpDirsMu.Unlock()
+		for _, fn := range atExit {
+			fn()
+		}
+		closeOutput(false)
+		os.Exit(sl.ExitCancelled)
+	}()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// storageFile is a document listed from a storage backend, keyed by the URL it is reported as
+type storageFile struct {
+	storage sl.Storage
+	object  sl.StorageObject
+}
+
+var storageFiles = map[string]storageFile{}
+
+// listStorage lists the documents under a storage URL (s3://bucket/prefix), objects
+// below the prefix are only included with --recursive
+func listStorage(rawURL string, extensions []string) []string {
+	storage, prefix, err := sl.OpenStorage(rawURL)
+	if err != nil {
+		exitError(err)
+	}
+	objects, err := storage.List(prefix)
+	if err != nil {
+		exitError(err)
+	}
+	// objects are reported as the URL of the storage root followed by their name
+	base := strings.TrimSuffix(strings.TrimSuffix(rawURL, prefix), "/")
+	var filePaths []string
+	for _, object := range objects {
+		rel := strings.TrimPrefix(strings.TrimPrefix(object.Name, prefix), "/")
+		if rel != "" && !recurse && strings.Contains(rel, "/") {
+			continue
+		}
+		if !hasExtension(object.Name, extensions) {
+			continue
+		}
+		filePath := base + "/" + object.Name
+		storageFiles[filePath] = storageFile{storage: storage, object: object}
+		filePaths = append(filePaths, filePath)
+	}
+	return filePaths
+}
+
+// processStorage reads a document of a storage backend into memory, write commands
+// write it back in place
+func processStorage(cmd, filePath string, sf storageFile, update updateFunc) sl.Result {
+	fl := sl.Result{FilePath: filePath, Labels: []sl.Label{}, Bytes: sf.object.Size}
+	if !sf.object.Modified.IsZero() {
+		fl.Modified = sf.object.Modified.UTC().Format(time.RFC3339)
+	}
+	flog := logger.With("file", filePath)
+	fail := func(category string, err error) sl.Result {
+		flog.Error("file error", "category", category, "error", err)
+		if failFast {
+			exitError(err)
+		}
+		fl.Error = err.Error()
+		fl.ErrorCategory = category
+		return fl
+	}
+	data, err := sl.ReadObject(sf.storage, sf.object.Name, unzipOpts.MaxBytes)
+	if err != nil {
+		return fail(errFetch, err)
+	}
+	if category, err := readBytesLabels(flog, &fl, sf.object.Name, data); err != nil {
+		return fail(category, err)
+	}
+	if update == nil {
+		return fl
+	}
+	written, changed, err := relabelBytes(cmd, flog, &fl, sf.object.Name, data, update)
+	if err != nil {
+		return fail(errWrite, err)
+	}
+	if changed && !dryrun {
+		if err := sf.storage.Write(sf.object.Name, written); err != nil {
+			return fail(errWrite, err)
+		}
+		fl.Bytes = int64(len(written))
+	}
+	return fl
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+var templateLabelIds []string
+var templateTenantId, templateContentBits, templateMethod string
+
+func init() {
+	templateFlags := flag.NewFlagSet("template", flag.ContinueOnError)
+	templateFlags.StringSliceVar(&templateLabelIds, "label-id", nil, "sensitivity label ID or name, may be repeated")
+	templateFlags.StringVar(&templateTenantId, "tenant-id", "", "tenant ID or name the labels belong to")
+	templateFlags.StringVar(&templateContentBits, "content-bits", "0", "content marking flags: header|footer|watermark|encryption, or a number")
+	templateFlags.StringVar(&templateMethod, "method", "Privileged", "assignment method: Standard or Privileged")
+	addCommand(&command{
+		name:    "template",
+		summary: "print a LabelInfo.xml document for the provided labels",
+		examples: []string{
+			`labels.exe template --label-id "1234-label-id-1234" --tenant-id "4321-tenant-id-4321" --content-bits header|watermark`,
+		},
+		run: runTemplate,
+	}, templateFlags)
+}
+
+func runTemplate(args []string) {
+	if len(templateLabelIds) == 0 || templateTenantId == "" {
+		printCommandUsage(findCommand("template"), "Error: --label-id and --tenant-id are required")
+		os.Exit(sl.ExitUsage)
+	}
+	contentBits, err := sl.EncodeContentBits(templateContentBits)
+	if err != nil {
+		exitError(err)
+	}
+	tenantId := parseIdList(templateTenantId, labelConfig.Tenants)[0]
+	labels := sl.Labels{Labels: []sl.Label{}}
+	for _, labelId := range templateLabelIds {
+		label := newLabel(parseIdList(labelId, labelConfig.Labels)[0], tenantId)
+		label.Method = templateMethod
+		label.ContentBits = contentBits
+		labels.Labels = append(labels.Labels, label)
+	}
+	logger.Debug("template", "labels", len(labels.Labels))
+	xml := sl.LabelInfoXml(labels)
+	if problems := sl.ValidateLabelInfoXml([]byte(xml)); len(problems) > 0 {
+		for _, problem := range problems {
+			warn("invalid label", "problem", problem)
+		}
+		exit(sl.ExitUsage)
+	}
+	fmt.Fprintln(out, xml)
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func makeRaw(in, out *os.File) (func() error, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func terminalSize(out *os.File) (int, int, error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procSetConsoleMode             = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleScreenBufferInfo")
+)
+
+// console modes, see SetConsoleMode
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableProcessedOutput           = 0x0001
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+type coord struct {
+	x, y int16
+}
+
+type smallRect struct {
+	left, top, right, bottom int16
+}
+
+type consoleScreenBufferInfo struct {
+	size              coord
+	cursorPosition    coord
+	attributes        uint16
+	window            smallRect
+	maximumWindowSize coord
+}
+
+func setConsoleMode(f *os.File, mode uint32) error {
+	if r, _, err := procSetConsoleMode.Call(f.Fd(), uintptr(mode)); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// makeRaw switches the console of in to raw input with arrow keys sent as
+// escape sequences and out to ANSI processing, the returned function restores both
+func makeRaw(in, out *os.File) (func() error, error) {
+	var inMode, outMode uint32
+	if err := syscall.GetConsoleMode(syscall.Handle(in.Fd()), &inMode); err != nil {
+		return nil, err
+	}
+	if err := syscall.GetConsoleMode(syscall.Handle(out.Fd()), &outMode); err != nil {
+		return nil, err
+	}
+	raw := inMode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if err := setConsoleMode(in, raw); err != nil {
+		return nil, err
+	}
+	if err := setConsoleMode(out, outMode|enableProcessedOutput|enableVirtualTerminalProcessing); err != nil {
+		setConsoleMode(in, inMode)
+		return nil, err
+	}
+	return func() error {
+		err := setConsoleMode(in, inMode)
+		if outErr := setConsoleMode(out, outMode); err == nil {
+			err = outErr
+		}
+		return err
+	}, nil
+}
+
+// terminalSize returns the columns and rows of the console window of out
+func terminalSize(out *os.File) (int, int, error) {
+	var info consoleScreenBufferInfo
+	if r, _, err := procGetConsoleScreenBufferInfo.Call(out.Fd(), uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0, 0, err
+	}
+	return int(info.window.right-info.window.left) + 1, int(info.window.bottom-info.window.top) + 1, nil
+}
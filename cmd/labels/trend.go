@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+var showTrendPaths bool
+
+func init() {
+	trendFlags := flag.NewFlagSet("trend", flag.ContinueOnError)
+	trendFlags.BoolVar(&showJson, "json", false, "display the trend as json")
+	trendFlags.BoolVar(&showTrendPaths, "paths", false, "list the paths of newly labeled, newly unlabeled, relabeled, added and removed files")
+	addCommand(&command{
+		name:    "trend",
+		args:    []string{"before", "after"},
+		summary: "compare two saved --json or NDJSON results: coverage change, newly labeled and unlabeled files, label distribution",
+		examples: []string{
+			`labels.exe trend september.json october.json`,
+		},
+		run: runTrend,
+	}, trendFlags)
+}
+
+func runTrend(args []string) {
+	before, err := sl.ReadResults(args[0])
+	if err != nil {
+		exitError(err)
+	}
+	after, err := sl.ReadResults(args[1])
+	if err != nil {
+		exitError(err)
+	}
+	logger.Debug("trend", "before", len(before), "after", len(after))
+	t := sl.CompareResults(before, after)
+	if showJson {
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			exitError(err)
+		}
+		fmt.Fprintln(out, string(data))
+		return
+	}
+	fmt.Fprintf(out, "files: %d -> %d (%d added, %d removed)\n", t.FilesBefore, t.FilesAfter, len(t.Added), len(t.Removed))
+	fmt.Fprintf(out, "coverage: %.1f%% -> %.1f%% (%+.1f points)\n", t.CoverageBefore, t.CoverageAfter, t.CoverageAfter-t.CoverageBefore)
+	printTrendPaths("newly labeled", t.NewlyLabeled)
+	printTrendPaths("newly unlabeled", t.NewlyUnlabeled)
+	printTrendPaths("relabeled", t.Relabeled)
+	if showTrendPaths {
+		printTrendPaths("added", t.Added)
+		printTrendPaths("removed", t.Removed)
+	}
+	printShifts("\nLabels:", t.LabelShifts, labelConfig.Labels)
+	printShifts("\nTenants:", t.TenantShifts, labelConfig.Tenants)
+}
+
+func printTrendPaths(title string, paths []string) {
+	fmt.Fprintf(out, "%s: %d\n", title, len(paths))
+	if !showTrendPaths {
+		return
+	}
+	for _, path := range paths {
+		fmt.Fprintln(out, "\t"+quotePath(path))
+	}
+}
+
+func printShifts(title string, shifts []sl.LabelShift, names map[string]string) {
+	if len(shifts) == 0 {
+		return
+	}
+	fmt.Fprintln(out, title)
+	for _, s := range shifts {
+		fmt.Fprintf(out, "\t%s: %d -> %d (%+d)\n", withName(s.Id, names), s.Before, s.After, s.Change)
+	}
+}
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+func init() {
+	addCommand(&command{
+		name:    "tui",
+		args:    []string{"path"},
+		summary: "interactively browse, filter and relabel the files under the provided path",
+		examples: []string{
+			`labels.exe tui "path\to\dir" --recursive`,
+			`labels.exe tui "path\to\dir" --recursive --config labels.json --expected-tenant "Contoso" --backup "path\to\backup"`,
+		},
+		run: runTui,
+	}, writeFlags, scanFlags)
+}
+
+const tuiKeys = "up/down move  space select  a all  enter details  / filter  s state  l label  r remove  R rescan  q quit"
+
+// terminal control sequences
+const (
+	termAltScreen  = "\033[?1049h\033[?25l"
+	termMainScreen = "\033[?25h\033[?1049l"
+	termHome       = "\033[H"
+	termClearLine  = "\033[K"
+	termClearBelow = "\033[J"
+	termReverse    = "\033[7m"
+	termBold       = "\033[1m"
+)
+
+// labelsColumn is the width of the labels column of the table
+const labelsColumn = 40
+
+// tui is an interactive session: the files of path in a table, the rows matching
+// filter and state, a cursor and the files selected for the next action
+type tui struct {
+	path       string
+	extensions []string
+	fileLabels []sl.Result
+	filter     string
+	state      string
+	manifest   *sl.Manifest
+	rows       []int        // indices into fileLabels of the rows shown
+	cursor     int          // row of the cursor
+	offset     int          // first row on screen
+	selected   map[int]bool // indices into fileLabels
+	details    bool         // detail pane expanded to half the screen
+	message    string       // outcome of the last action
+	input      string       // prompt shown instead of the message
+	keys       *keyReader
+	term       io.Writer
+}
+
+func runTui(args []string) {
+	if outputFile != "" {
+		exitError(fmt.Errorf("--output-file is not supported by tui"))
+	}
+	t := &tui{
+		path:       args[0],
+		extensions: prepareScan(),
+		state:      "all",
+		manifest:   sl.NewManifest(),
+		selected:   map[int]bool{},
+		keys:       &keyReader{in: os.Stdin},
+		term:       os.Stdout,
+	}
+	restore, err := makeRaw(os.Stdin, os.Stdout)
+	if err != nil {
+		exitError(fmt.Errorf("tui needs an interactive terminal: %w", err))
+	}
+	// log records would be drawn over the table, failures are shown in the
+	// status line and the detail pane instead
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	fmt.Fprint(t.term, termAltScreen)
+	leave := func() {
+		fmt.Fprint(t.term, termMainScreen)
+		restore()
+	}
+	atExit = append(atExit, leave)
+
+	t.message = "scanning " + t.path
+	t.draw()
+	t.rescan()
+	for {
+		t.draw()
+		k, err := t.keys.read()
+		if err != nil || !t.handle(k) {
+			break
+		}
+	}
+	leave()
+	atExit = atExit[:len(atExit)-1]
+
+	// write manifest of changes applied during the session
+	if manifestPath != "" && !dryrun && len(t.manifest.Entries) > 0 {
+		if err := writeManifest(t.manifest); err != nil {
+			exitError(err)
+		}
+	}
+}
+
+// handle runs the action bound to k, false ends the session
+func (t *tui) handle(k key) bool {
+	t.message = ""
+	switch {
+	case k.name == "ctrl-c" || k.r == 'q':
+		return false
+	case k.name == "up" || k.r == 'k':
+		t.move(-1)
+	case k.name == "down" || k.r == 'j':
+		t.move(1)
+	case k.name == "pgup":
+		t.move(-t.tableHeight())
+	case k.name == "pgdn":
+		t.move(t.tableHeight())
+	case k.name == "home" || k.r == 'g':
+		t.move(-len(t.rows))
+	case k.name == "end" || k.r == 'G':
+		t.move(len(t.rows))
+	case k.r == ' ':
+		if i, ok := t.current(); ok {
+			t.selected[i] = !t.selected[i]
+			if !t.selected[i] {
+				delete(t.selected, i)
+			}
+			t.move(1)
+		}
+	case k.r == 'a':
+		t.selectAll()
+	case k.name == "esc":
+		t.selected = map[int]bool{}
+	case k.name == "enter":
+		t.details = !t.details
+	case k.r == '/':
+		previous := t.filter
+		if _, ok := t.prompt("filter: ", t.filter, func(s string) { t.filter = s; t.refresh() }); !ok {
+			t.filter = previous
+			t.refresh()
+		}
+	case k.r == 's':
+		t.state = map[string]string{"all": "labeled", "labeled": "unlabeled", "unlabeled": "all"}[t.state]
+		t.refresh()
+	case k.r == 'l':
+		t.applyLabel()
+	case k.r == 'r' || k.name == "delete":
+		t.removeLabels()
+	case k.r == 'R':
+		t.message = "scanning " + t.path
+		t.draw()
+		t.rescan()
+		t.message = fmt.Sprintf("%d files", len(t.fileLabels))
+	case k.r == '?' || k.r == 'h':
+		t.message = tuiKeys
+	}
+	return true
+}
+
+func (t *tui) rescan() {
+	t.fileLabels = []sl.Result{}
+	t.selected = map[int]bool{}
+	for _, filePath := range listFiles(t.path, t.extensions) {
+		t.fileLabels = append(t.fileLabels, processFile("get", filePath, nil, nil))
+	}
+	t.cursor, t.offset = 0, 0
+	t.refresh()
+}
+
+// refresh applies the filter and state, the cursor stays on its file while it is shown
+func (t *tui) refresh() {
+	current, hasCurrent := t.current()
+	t.rows = t.rows[:0]
+	for i, fl := range t.fileLabels {
+		if t.matches(fl) {
+			t.rows = append(t.rows, i)
+		}
+	}
+	if hasCurrent {
+		if n := sort.SearchInts(t.rows, current); n < len(t.rows) && t.rows[n] == current {
+			t.cursor = n
+		}
+	}
+	t.move(0)
+}
+
+func (t *tui) matches(fl sl.Result) bool {
+	if t.state == "labeled" && len(fl.Labels) == 0 {
+		return false
+	}
+	if t.state == "unlabeled" && len(fl.Labels) > 0 {
+		return false
+	}
+	if t.filter == "" {
+		return true
+	}
+	text := fl.FilePath
+	for _, label := range fl.Labels {
+		text += " " + label.Id + " " + label.SiteId + " " + labelConfig.Labels[sl.NormalizeId(label.Id)]
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(t.filter))
+}
+
+// current returns the file under the cursor
+func (t *tui) current() (int, bool) {
+	if t.cursor < 0 || t.cursor >= len(t.rows) {
+		return 0, false
+	}
+	return t.rows[t.cursor], true
+}
+
+// move moves the cursor by n rows and scrolls it into view
+func (t *tui) move(n int) {
+	t.cursor = max(0, min(t.cursor+n, len(t.rows)-1))
+	height := t.tableHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	} else if t.cursor >= t.offset+height {
+		t.offset = t.cursor - height + 1
+	}
+	t.offset = max(0, min(t.offset, len(t.rows)-height))
+}
+
+// selectAll selects every row shown, or clears the selection if they all are
+func (t *tui) selectAll() {
+	all := len(t.rows) > 0
+	for _, i := range t.rows {
+		all = all && t.selected[i]
+	}
+	t.selected = map[int]bool{}
+	if !all {
+		for _, i := range t.rows {
+			t.selected[i] = true
+		}
+	}
+}
+
+// targets are the selected files, or the file under the cursor without a selection
+func (t *tui) targets() []int {
+	var targets []int
+	for i := range t.selected {
+		targets = append(targets, i)
+	}
+	if len(targets) == 0 {
+		if i, ok := t.current(); ok {
+			targets = append(targets, i)
+		}
+	}
+	sort.Ints(targets)
+	return targets
+}
+
+func (t *tui) applyLabel() {
+	targets := t.targets()
+	if len(targets) == 0 {
+		return
+	}
+	label, ok := t.prompt("label ID or name: ", "", nil)
+	if !ok || strings.TrimSpace(label) == "" {
+		return
+	}
+	tenant, ok := t.prompt("tenant ID or name: ", orDefault(expectedTenant, profileTenant), nil)
+	if !ok {
+		return
+	}
+	labelIds := parseIdList(label, labelConfig.Labels)
+	tenantIds := parseIdList(tenant, labelConfig.Tenants)
+	if len(labelIds) != 1 || len(tenantIds) != 1 {
+		t.message = "Error: a single label and tenant are required"
+		return
+	}
+	labelId, tenantId := labelIds[0], tenantIds[0]
+	if !t.confirm(fmt.Sprintf("apply %s to %d files? (y/n) ", label, len(targets))) {
+		return
+	}
+	t.update(targets, "set", func(fl sl.Result) (sl.Labels, bool) {
+		return sl.Labels{Labels: []sl.Label{newLabel(labelId, tenantId)}}, true
+	})
+}
+
+func (t *tui) removeLabels() {
+	targets := t.targets()
+	if len(targets) == 0 || !t.confirm(fmt.Sprintf("remove every label from %d files? (y/n) ", len(targets))) {
+		return
+	}
+	t.update(targets, "remove", func(fl sl.Result) (sl.Labels, bool) {
+		return sl.Labels{Labels: []sl.Label{}}, fl.LabelInfo
+	})
+}
+
+// update relabels the targets and reports the outcome in the status line
+func (t *tui) update(targets []int, cmd string, update updateFunc) {
+	t.message = fmt.Sprintf("%s: %d files...", cmd, len(targets))
+	t.draw()
+	done, skipped, failed := 0, 0, 0
+	firstError := ""
+	for _, i := range targets {
+		fl := processFile(cmd, t.fileLabels[i].FilePath, update, t.manifest)
+		t.fileLabels[i] = fl
+		switch {
+		case fl.Error != "":
+			failed++
+			if firstError == "" {
+				firstError = ", " + quotePath(fl.FilePath) + ": " + withReason(fl)
+			}
+		case fl.Skipped != "":
+			skipped++
+		default:
+			done++
+		}
+	}
+	t.selected = map[int]bool{}
+	t.refresh()
+	t.message = fmt.Sprintf("%s: %d files done, %d skipped, %d failed%s", cmd, done, skipped, failed, firstError)
+	if dryrun {
+		t.message += " (dry run)"
+	}
+}
+
+// prompt reads a line in the status line, onChange sees every edit,
+// false when cancelled with escape
+func (t *tui) prompt(label, value string, onChange func(string)) (string, bool) {
+	defer func() { t.input = "" }()
+	for {
+		t.input = label + value + "_"
+		t.draw()
+		k, err := t.keys.read()
+		switch {
+		case err != nil || k.name == "esc" || k.name == "ctrl-c":
+			return value, false
+		case k.name == "enter":
+			return value, true
+		case k.name == "backspace":
+			_, size := utf8.DecodeLastRuneInString(value)
+			value = value[:len(value)-size]
+		case k.r >= ' ':
+			value += string(k.r)
+		default:
+			continue
+		}
+		if onChange != nil {
+			onChange(value)
+		}
+	}
+}
+
+func (t *tui) confirm(question string) bool {
+	defer func() { t.input = "" }()
+	t.input = question
+	t.draw()
+	k, err := t.keys.read()
+	return err == nil && (k.r == 'y' || k.r == 'Y')
+}
+
+// screen layout: title, column header, table, detail pane, status line and keys
+func (t *tui) size() (int, int) {
+	width, height, err := terminalSize(os.Stdout)
+	if err != nil || width < 20 || height < 10 {
+		return 80, 24
+	}
+	return width, height
+}
+
+func (t *tui) detailHeight() int {
+	_, height := t.size()
+	if t.details {
+		return height / 2
+	}
+	return min(8, height/4)
+}
+
+func (t *tui) tableHeight() int {
+	_, height := t.size()
+	return max(1, height-4-t.detailHeight())
+}
+
+func (t *tui) draw() {
+	width, _ := t.size()
+	var b strings.Builder
+	b.WriteString(termHome)
+	line := func(style, s string) {
+		s = pad(s, width)
+		if style != "" {
+			s = style + s + colorReset
+		}
+		b.WriteString(s + termClearLine + "\r\n")
+	}
+	title := fmt.Sprintf(" %s  %d of %d files  state: %s", t.path, len(t.rows), len(t.fileLabels), t.state)
+	if t.filter != "" {
+		title += fmt.Sprintf("  filter: %q", t.filter)
+	}
+	if len(t.selected) > 0 {
+		title += fmt.Sprintf("  selected: %d", len(t.selected))
+	}
+	line(termReverse, title)
+	line(termBold, "   "+pad("Labels", labelsColumn)+" FilePath")
+	height := t.tableHeight()
+	for n := t.offset; n < t.offset+height; n++ {
+		if n >= len(t.rows) {
+			line("", "")
+			continue
+		}
+		i := t.rows[n]
+		style := t.rowColor(t.fileLabels[i])
+		if n == t.cursor {
+			style = termReverse
+		}
+		line(style, t.row(i))
+	}
+	details := []string{}
+	if i, ok := t.current(); ok {
+		details = detailLines(t.fileLabels[i])
+	}
+	line(termReverse, " details")
+	for n := 0; n < t.detailHeight()-1; n++ {
+		if n < len(details) {
+			line("", " "+details[n])
+		} else {
+			line("", "")
+		}
+	}
+	if t.input != "" {
+		line(termBold, t.input)
+	} else {
+		line("", t.message)
+	}
+	// the last line has no line break so the screen doesn't scroll
+	b.WriteString(pad(tuiKeys, width) + termClearBelow)
+	fmt.Fprint(t.term, b.String())
+}
+
+// row renders a file of the table: selection mark, label count and names, path
+func (t *tui) row(i int) string {
+	fl := t.fileLabels[i]
+	mark := " "
+	if t.selected[i] {
+		mark = "*"
+	}
+	var labels string
+	switch {
+	case fl.Error != "":
+		labels = fl.ErrorCategory + " error"
+	case len(fl.Labels) == 0:
+		labels = "-"
+	default:
+		var names []string
+		for _, label := range resolveNames(fl.Labels) {
+			names = append(names, orDefault(label.Name, sl.NormalizeId(label.Id)))
+		}
+		labels = strconv.Itoa(len(fl.Labels)) + " " + strings.Join(names, ", ")
+	}
+	return mark + "  " + pad(labels, labelsColumn) + " " + quotePath(fl.FilePath)
+}
+
+// rowColor matches the colors of get output
+func (t *tui) rowColor(fl sl.Result) string {
+	if !useColor(os.Stdout) {
+		return ""
+	}
+	switch {
+	case len(fl.ForbiddenLabels) > 0 || len(fl.Invalid) > 0 || fl.Error != "":
+		return colorRed
+	case len(fl.Labels) > 0:
+		return colorGreen
+	}
+	return colorYellow
+}
+
+// detailLines lists every attribute of the labels of a file for the detail pane
+func detailLines(fl sl.Result) []string {
+	lines := []string{
+		"FilePath: " + quotePath(fl.FilePath),
+		"LabelInfo: " + strconv.FormatBool(fl.LabelInfo),
+	}
+	if fl.Error != "" {
+		lines = append(lines, "error: "+withReason(fl))
+	}
+	if fl.Skipped != "" {
+		lines = append(lines, "skipped: "+fl.Skipped)
+	}
+	for _, w := range fl.Warnings {
+		lines = append(lines, "warning: "+w)
+	}
+	for i, label := range fl.Labels {
+		lines = append(lines,
+			fmt.Sprintf("label %d", i+1),
+			"    id: "+label.Id+" "+labelConfig.Labels[sl.NormalizeId(label.Id)],
+			"    siteId: "+label.SiteId+" "+labelConfig.Tenants[sl.NormalizeId(label.SiteId)],
+			"    enabled: "+label.Enabled,
+			"    method: "+label.Method,
+			"    contentBits: "+label.ContentBits.Format(),
+			"    removed: "+label.Removed,
+		)
+		for _, key := range extraAttrKeys(label) {
+			lines = append(lines, "    "+key+": "+label.Extra[key].Value)
+		}
+	}
+	for _, h := range fl.History {
+		lines = append(lines, "history: "+formatHistoryEntry(h))
+	}
+	return lines
+}
+
+// pad cuts or fills s to width columns, counting one column per rune
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		return string(runes[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// key is a key press, r for characters and name for the other keys:
+// up, down, left, right, home, end, pgup, pgdn, delete, enter, tab, backspace, esc, ctrl-c
+type key struct {
+	r    rune
+	name string
+}
+
+// keyReader decodes key presses from a terminal in raw mode
+type keyReader struct {
+	in      io.Reader
+	pending []byte
+}
+
+func (kr *keyReader) read() (key, error) {
+	for len(kr.pending) == 0 {
+		buf := make([]byte, 64)
+		n, err := kr.in.Read(buf)
+		if err != nil {
+			return key{}, err
+		}
+		kr.pending = buf[:n]
+	}
+	k, n := parseKey(kr.pending)
+	kr.pending = kr.pending[n:]
+	return k, nil
+}
+
+// csiKeys are the escape sequences of special keys after ESC [ or ESC O
+var csiKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end", "1~": "home", "4~": "end", "7~": "home", "8~": "end",
+	"5~": "pgup", "6~": "pgdn", "3~": "delete",
+}
+
+// parseKey decodes the first key press of b and returns the bytes it used,
+// unknown escape sequences are consumed as a key without name
+func parseKey(b []byte) (key, int) {
+	switch b[0] {
+	case '\r', '\n':
+		return key{name: "enter"}, 1
+	case '\t':
+		return key{name: "tab"}, 1
+	case 0x7f, 0x08:
+		return key{name: "backspace"}, 1
+	case 0x03:
+		return key{name: "ctrl-c"}, 1
+	case 0x1b:
+		if len(b) >= 3 && (b[1] == '[' || b[1] == 'O') {
+			i := 2
+			for i < len(b) && (b[i] >= '0' && b[i] <= '9' || b[i] == ';') {
+				i++
+			}
+			if i < len(b) {
+				return key{name: csiKeys[string(b[2:i+1])]}, i + 1
+			}
+		}
+		return key{name: "esc"}, 1
+	}
+	r, n := utf8.DecodeRune(b)
+	return key{r: r}, n
+}
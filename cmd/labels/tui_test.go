@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+func TestKeyReader(t *testing.T) {
+	input := "j\x1b[A\x1b[B\x1b[5~\x1b[6~\x1bOH\x1b[4~\x1b[1;5C \r\x7f\x03\x1bé"
+	want := []key{
+		{r: 'j'}, {name: "up"}, {name: "down"}, {name: "pgup"}, {name: "pgdn"}, {name: "home"}, {name: "end"},
+		{name: ""}, {r: ' '}, {name: "enter"}, {name: "backspace"}, {name: "ctrl-c"}, {name: "esc"}, {r: 'é'},
+	}
+	kr := &keyReader{in: strings.NewReader(input)}
+	for i, w := range want {
+		got, err := kr.read()
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if got != w {
+			t.Fatalf("key %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if _, err := kr.read(); err == nil {
+		t.Fatal("read() after the input, want an error")
+	}
+}
+
+func TestTuiSelection(t *testing.T) {
+	tu := &tui{state: "all", selected: map[int]bool{}, fileLabels: []sl.Result{
+		{FilePath: "dir/a.docx", Labels: []sl.Label{{Id: "{11111111-1111-1111-1111-111111111111}"}}},
+		{FilePath: "dir/b.docx", Labels: []sl.Label{}},
+		{FilePath: "dir/c.xlsx", Labels: []sl.Label{}},
+	}}
+	tu.refresh()
+	if got := tu.targets(); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("targets() without a selection = %v, want the cursor file", got)
+	}
+	tu.handle(key{name: "end"})
+	tu.handle(key{r: 's'})
+	if len(tu.rows) != 1 || tu.rows[0] != 0 {
+		t.Fatalf("labeled rows = %v", tu.rows)
+	}
+	tu.handle(key{r: 's'})
+	tu.handle(key{r: 'a'})
+	if got := tu.targets(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("targets() after selecting every unlabeled file = %v", got)
+	}
+	tu.filter = "XLSX"
+	tu.refresh()
+	if len(tu.rows) != 1 || tu.rows[0] != 2 {
+		t.Fatalf("rows filtered by extension = %v", tu.rows)
+	}
+	tu.handle(key{r: 'a'})
+	tu.handle(key{r: 'a'})
+	if got := tu.targets(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("targets() after selecting the filtered file = %v", got)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	sl "github.com/WTFender/sensitivity_labels"
+	flag "github.com/spf13/pflag"
+)
+
+// set at build time, e.g. -ldflags "-X main.version=1.4.0 -X main.commit=abc1234 -X main.buildDate=2024-05-01T00:00:00Z",
+// otherwise taken from the module and vcs build info
+var version, commit, buildDate string
+
+var versionOutput = "text"
+
+func init() {
+	versionFlags := flag.NewFlagSet("version", flag.ContinueOnError)
+	versionFlags.StringVar(&versionOutput, "output", versionOutput, "output format: text or json")
+	addCommand(&command{
+		name:    "version",
+		summary: "print the version, commit, build date and supported format handlers",
+		examples: []string{
+			`labels.exe version --output json`,
+		},
+		run: runVersion,
+	}, versionFlags)
+}
+
+type VersionInfo struct {
+	Version       string           `json:"version"`
+	Commit        string           `json:"commit,omitempty"`
+	BuildDate     string           `json:"buildDate,omitempty"`
+	GoVersion     string           `json:"goVersion"`
+	Platform      string           `json:"platform"`
+	SchemaVersion int              `json:"outputSchemaVersion"`
+	Handlers      []HandlerVersion `json:"handlers"`
+	Storages      []string         `json:"storages"`
+}
+
+type HandlerVersion struct {
+	Name     string `json:"name"`
+	Writable bool   `json:"writable"`
+}
+
+func buildVersion() VersionInfo {
+	info := VersionInfo{
+		Version:       version,
+		Commit:        commit,
+		BuildDate:     buildDate,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		SchemaVersion: sl.OutputSchemaVersion,
+		Handlers:      []HandlerVersion{},
+		Storages:      sl.Storages(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = strings.TrimPrefix(build.Main.Version, "v")
+		}
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	for _, name := range sl.Handlers() {
+		handler, _ := sl.LookupHandler(name)
+		_, writable := handler.(sl.LabelWriter)
+		info.Handlers = append(info.Handlers, HandlerVersion{Name: name, Writable: writable})
+	}
+	return info
+}
+
+func runVersion(args []string) {
+	info := buildVersion()
+	switch versionOutput {
+	case "json":
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			exitError(err)
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		fmt.Fprintln(out, "labels.exe "+info.Version)
+		fmt.Fprintln(out, "commit: "+orDefault(info.Commit, "unknown"))
+		fmt.Fprintln(out, "built: "+orDefault(info.BuildDate, "unknown"))
+		fmt.Fprintln(out, "go: "+info.GoVersion+" "+info.Platform)
+		fmt.Fprintf(out, "output schema: %d\n", info.SchemaVersion)
+		for _, h := range info.Handlers {
+			mode := "read-only"
+			if h.Writable {
+				mode = "read-write"
+			}
+			fmt.Fprintln(out, "handler: "+h.Name+" ("+mode+")")
+		}
+		for _, scheme := range info.Storages {
+			fmt.Fprintln(out, "storage: "+scheme+"://")
+		}
+	default:
+		printCommandUsage(findCommand("version"), fmt.Sprintf("Error: invalid --output %q, expected text or json", versionOutput))
+		exit(sl.ExitUsage)
+	}
+}
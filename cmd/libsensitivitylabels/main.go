@@ -0,0 +1,79 @@
+//go:build cgo
+
+// libsensitivitylabels exposes the label engine as a C shared library,
+// build with build/build_shared.sh (or build_shared_win.ps1 for a DLL)
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"unsafe"
+
+	sl "github.com/WTFender/sensitivity_labels"
+)
+
+// result returned by LabelsRead as JSON
+type readResult struct {
+	LabelInfo bool       `json:"labelInfo"`
+	Labels    []sl.Label `json:"labels"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// LabelsRead returns the labels of a document as JSON,
+// the result must be released with LabelsFree
+//
+//export LabelsRead
+func LabelsRead(path *C.char) *C.char {
+	var result readResult
+	_, handler, err := sl.FindHandler(C.GoString(path))
+	if err == nil {
+		var labels sl.Labels
+		labels, result.LabelInfo, err = handler.ReadLabels(C.GoString(path))
+		result.Labels = labels.Labels
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.Labels == nil {
+		result.Labels = []sl.Label{}
+	}
+	data, _ := json.Marshal(result)
+	return C.CString(string(data))
+}
+
+// LabelsSet writes the JSON array of labels to a document, returns NULL on
+// success or an error message that must be released with LabelsFree
+//
+//export LabelsSet
+func LabelsSet(path *C.char, labelsJson *C.char) *C.char {
+	var labels []sl.Label
+	if err := json.Unmarshal([]byte(C.GoString(labelsJson)), &labels); err != nil {
+		return C.CString(err.Error())
+	}
+	name, handler, err := sl.FindHandler(C.GoString(path))
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	writer, ok := handler.(sl.LabelWriter)
+	if !ok {
+		return C.CString(name + " is read only")
+	}
+	if err := writer.WriteLabels(C.GoString(path), sl.Labels{Labels: labels}); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// LabelsFree releases a string returned by the library
+//
+//export LabelsFree
+func LabelsFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// required by -buildmode=c-shared
+func main() {}
@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Fprintln(os.Stderr, "libsensitivitylabels requires cgo, build with CGO_ENABLED=1 -buildmode=c-shared")
+	os.Exit(1)
+}
@@ -0,0 +1,21 @@
+/* sensitivity_labels.h - C API of libsensitivitylabels */
+#ifndef SENSITIVITY_LABELS_H
+#define SENSITIVITY_LABELS_H
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* returns {"labelInfo": bool, "labels": [...], "error": "..."} as JSON, release with LabelsFree */
+char *LabelsRead(char *path);
+
+/* writes a JSON array of labels to path, returns NULL on success or an error message to release with LabelsFree */
+char *LabelsSet(char *path, char *labelsJson);
+
+void LabelsFree(char *s);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
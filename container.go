@@ -0,0 +1,118 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// containers detected from the first bytes of a file
+const (
+	ContainerZip     = "zip" // Office Open XML and other zip packages
+	ContainerCFB     = "cfb" // OLE compound file: legacy .doc, .xls, .ppt, .msg and encrypted Office documents
+	ContainerPDF     = "pdf"
+	ContainerXML     = "xml" // flat OPC and other XML documents
+	ContainerUnknown = ""
+)
+
+var (
+	cfbMagic      = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	pdfMagic      = []byte("%PDF-")
+	emptyZipMagic = []byte("PK\x05\x06")
+	utf8BOM       = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// DetectContainer returns the container type of a file from its first bytes
+func DetectContainer(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, zipMagic) || bytes.HasPrefix(header, emptyZipMagic):
+		return ContainerZip
+	case bytes.HasPrefix(header, cfbMagic):
+		return ContainerCFB
+	case bytes.HasPrefix(header, pdfMagic):
+		return ContainerPDF
+	case bytes.HasPrefix(bytes.TrimLeft(bytes.TrimPrefix(header, utf8BOM), " \t\r\n"), []byte("<")):
+		return ContainerXML
+	}
+	return ContainerUnknown
+}
+
+// ExpectedContainer returns the container files with this extension are stored in,
+// empty for extensions labels.exe knows nothing about
+func ExpectedContainer(ext string) string {
+	ext = strings.ToLower(ext)
+	for _, e := range ooxmlExtensions {
+		if ext == e {
+			return ContainerZip
+		}
+	}
+	switch ext {
+	case ".doc", ".dot", ".xls", ".xlt", ".ppt", ".pot", ".msg":
+		return ContainerCFB
+	case ".pdf":
+		return ContainerPDF
+	case ".xml":
+		return ContainerXML
+	}
+	return ContainerUnknown
+}
+
+// FormatMismatchError reports a file whose contents don't match its extension,
+// e.g. a legacy .doc renamed to .docx
+type FormatMismatchError struct {
+	Ext       string
+	Container string
+}
+
+func (e *FormatMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s file is %s", ErrUnsupportedFormat, e.Ext, describeContainer(e.Container))
+}
+
+func (e *FormatMismatchError) Unwrap() error {
+	return ErrUnsupportedFormat
+}
+
+func describeContainer(container string) string {
+	switch container {
+	case ContainerZip:
+		return "a zip package"
+	case ContainerCFB:
+		return "an OLE compound file (a legacy .doc, .xls or .ppt, or an encrypted Office document)"
+	case ContainerPDF:
+		return "a PDF document"
+	case ContainerXML:
+		return "an XML document"
+	}
+	return "of an unknown format"
+}
+
+// checkContainer returns a *FormatMismatchError when the header doesn't match the
+// container expected for the extension of filePath
+func checkContainer(filePath string, header []byte) error {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	expected := ExpectedContainer(ext)
+	container := DetectContainer(header)
+	if expected == ContainerUnknown || container == expected {
+		return nil
+	}
+	return &FormatMismatchError{Ext: ext, Container: container}
+}
+
+// CheckContainer reads the first bytes of a file and returns a *FormatMismatchError
+// when they don't match its extension, e.g. a handler recognized a renamed file
+func CheckContainer(filePath string) error {
+	f, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	return checkContainer(filePath, header[:n])
+}
@@ -0,0 +1,114 @@
+package sensitivity_labels
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContentBits is the contentBits attribute of a label, a bitmask of the
+// content markings and protection applied by the label
+type ContentBits string
+
+const (
+	ContentBitsHeader     = 0x1
+	ContentBitsFooter     = 0x2
+	ContentBitsWatermark  = 0x4
+	ContentBitsEncryption = 0x8
+)
+
+var contentBitNames = []struct {
+	bit  uint64
+	name string
+}{
+	{ContentBitsHeader, "header"},
+	{ContentBitsFooter, "footer"},
+	{ContentBitsWatermark, "watermark"},
+	{ContentBitsEncryption, "encryption"},
+}
+
+// Decode returns the name of every flag set, unknown bits are returned in hex
+func (c ContentBits) Decode() ([]string, error) {
+	bits, err := strconv.ParseUint(string(c), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contentBits %q", string(c))
+	}
+	flags := []string{}
+	for _, f := range contentBitNames {
+		if bits&f.bit != 0 {
+			flags = append(flags, f.name)
+			bits &^= f.bit
+		}
+	}
+	for bit := uint64(1); bits != 0; bit <<= 1 {
+		if bits&bit != 0 {
+			flags = append(flags, fmt.Sprintf("0x%x", bit))
+			bits &^= bit
+		}
+	}
+	return flags, nil
+}
+
+// Format renders the flags as header|footer, none when no flag is set,
+// values that aren't a number are returned unchanged
+func (c ContentBits) Format() string {
+	flags, err := c.Decode()
+	if err != nil {
+		return string(c)
+	}
+	if len(flags) == 0 {
+		return "none"
+	}
+	return strings.Join(flags, "|")
+}
+
+// EncodeContentBits parses flags formatted by Format, or a plain number
+func EncodeContentBits(s string) (ContentBits, error) {
+	if s == "" {
+		return "", nil
+	}
+	if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return ContentBits(s), nil
+	}
+	var bits uint64
+	for _, name := range strings.Split(s, "|") {
+		if name == "none" {
+			continue
+		}
+		found := false
+		for _, f := range contentBitNames {
+			if f.name == name {
+				bits |= f.bit
+				found = true
+			}
+		}
+		if !found {
+			bit, err := strconv.ParseUint(strings.TrimPrefix(name, "0x"), 16, 64)
+			if err != nil || !strings.HasPrefix(name, "0x") {
+				return "", fmt.Errorf("invalid contentBits flag %q", name)
+			}
+			bits |= bit
+		}
+	}
+	return ContentBits(strconv.FormatUint(bits, 10)), nil
+}
+
+// json output uses the symbolic flags, xml keeps the number
+func (c ContentBits) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Format())
+}
+
+func (c *ContentBits) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	// values Format couldn't decode were written unchanged
+	bits, err := EncodeContentBits(s)
+	if err != nil {
+		bits = ContentBits(s)
+	}
+	*c = bits
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package sensitivity_labels
+
+// resolveDFS returns path unchanged, DFS namespaces are resolved by the Windows client
+func resolveDFS(path string) (string, error) {
+	return path, nil
+}
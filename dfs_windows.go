@@ -0,0 +1,71 @@
+package sensitivity_labels
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	netapi32                = syscall.NewLazyDLL("netapi32.dll")
+	procNetDfsGetClientInfo = netapi32.NewProc("NetDfsGetClientInfo")
+	procNetApiBufferFree    = netapi32.NewProc("NetApiBufferFree")
+)
+
+const dfsStorageStateActive = 0x4
+
+// DFS_INFO_3 and DFS_STORAGE_INFO of lmdfs.h
+type dfsInfo3 struct {
+	EntryPath        *uint16
+	Comment          *uint16
+	State            uint32
+	NumberOfStorages uint32
+	Storage          *dfsStorageInfo
+}
+
+type dfsStorageInfo struct {
+	State      uint32
+	ServerName *uint16
+	ShareName  *uint16
+}
+
+// resolveDFS asks the DFS client cache which target serves path, paths outside of
+// DFS namespaces fail the lookup and are returned unchanged
+func resolveDFS(path string) (string, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return path, err
+	}
+	var buf *dfsInfo3
+	r, _, _ := procNetDfsGetClientInfo.Call(uintptr(unsafe.Pointer(pathPtr)), 0, 0, 3, uintptr(unsafe.Pointer(&buf)))
+	if r != 0 || buf == nil {
+		return path, nil
+	}
+	defer procNetApiBufferFree.Call(uintptr(unsafe.Pointer(buf)))
+
+	entry := utf16PtrString(buf.EntryPath)
+	if len(entry) > len(path) || !strings.EqualFold(path[:len(entry)], entry) {
+		return path, nil
+	}
+	storages := unsafe.Slice(buf.Storage, buf.NumberOfStorages)
+	for _, s := range storages {
+		if s.State&dfsStorageStateActive == 0 {
+			continue
+		}
+		target := `\\` + utf16PtrString(s.ServerName) + `\` + utf16PtrString(s.ShareName)
+		return target + path[len(entry):], nil
+	}
+	return path, nil
+}
+
+// utf16PtrString converts a NUL terminated UTF-16 string returned by the API
+func utf16PtrString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for *(*uint16)(unsafe.Add(unsafe.Pointer(p), 2*n)) != 0 {
+		n++
+	}
+	return syscall.UTF16ToString(unsafe.Slice(p, n))
+}
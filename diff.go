@@ -0,0 +1,108 @@
+package sensitivity_labels
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LabelDiff describes the changes a relabel would make to a document
+type LabelDiff struct {
+	Removed   []Label      `json:",omitempty"`
+	Added     []Label      `json:",omitempty"`
+	Unchanged []Label      `json:",omitempty"`
+	Parts     []PartChange `json:",omitempty"`
+	Before    string       `json:",omitempty"` // LabelInfo.xml content before
+	After     string       // LabelInfo.xml content after
+}
+
+// PartChange is a package part that would be rewritten, Change is create or modify
+type PartChange struct {
+	Part   string
+	Change string
+}
+
+// ids are compared without braces and case, other attributes exactly
+func sameLabel(a, b Label) bool {
+	return NormalizeId(a.Id) == NormalizeId(b.Id) &&
+		NormalizeId(a.SiteId) == NormalizeId(b.SiteId) &&
+		a.Enabled == b.Enabled &&
+		a.Method == b.Method &&
+		a.ContentBits == b.ContentBits &&
+		a.Removed == b.Removed &&
+		sameExtraAttrs(a.Extra, b.Extra)
+}
+
+// SameLabels reports whether two label lists carry the same labels in the same order
+func SameLabels(a, b []Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameLabel(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameExtraAttrs(a, b ExtraAttrs) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, attr := range a {
+		if b[key] != attr {
+			return false
+		}
+	}
+	return true
+}
+
+func containsLabel(labels []Label, label Label) bool {
+	for _, l := range labels {
+		if sameLabel(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareLabels lists the labels removed, added and kept by newLabels and the
+// LabelInfo.xml written for them
+func CompareLabels(before []Label, newLabels Labels) LabelDiff {
+	diff := LabelDiff{After: templateLabelInfoXml(newLabels)}
+	for _, label := range before {
+		if containsLabel(newLabels.Labels, label) {
+			diff.Unchanged = append(diff.Unchanged, label)
+		} else {
+			diff.Removed = append(diff.Removed, label)
+		}
+	}
+	for _, label := range newLabels.Labels {
+		if !containsLabel(before, label) {
+			diff.Added = append(diff.Added, label)
+		}
+	}
+	return diff
+}
+
+// DiffLabels compares the labels extracted to unzipDir with newLabels without writing anything
+func DiffLabels(unzipDir, labelInfoPath string, before []Label, newLabels Labels) (LabelDiff, error) {
+	diff := CompareLabels(before, newLabels)
+	part, err := filepath.Rel(unzipDir, labelInfoPath)
+	if err != nil {
+		return diff, err
+	}
+	change := PartChange{Part: filepath.ToSlash(part), Change: "modify"}
+	content, err := os.ReadFile(LongPath(labelInfoPath))
+	if os.IsNotExist(err) {
+		change.Change = "create"
+	} else if err != nil {
+		return diff, err
+	} else {
+		diff.Before = string(content)
+	}
+	if diff.Before != diff.After {
+		diff.Parts = append(diff.Parts, change)
+	}
+	return diff, nil
+}
@@ -0,0 +1,6 @@
+package sensitivity_labels
+
+// FreeSpace returns the bytes available to the current user on the volume holding dir
+func FreeSpace(dir string) (uint64, error) {
+	return freeSpace(dir)
+}
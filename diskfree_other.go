@@ -0,0 +1,9 @@
+//go:build !windows && !linux && !darwin && !freebsd
+
+package sensitivity_labels
+
+import "errors"
+
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
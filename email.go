@@ -0,0 +1,281 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Email is the label and attachments of an exported .eml or .msg email
+type Email struct {
+	Labels      []Label
+	Labeled     bool // the email carries a msip_labels header or property
+	Attachments []EmailAttachment
+}
+
+// EmailAttachment is a file attached to an email
+type EmailAttachment struct {
+	Name string
+	Data []byte
+}
+
+// EmailHandler reads the label of .eml and .msg emails from their msip_labels
+// header, emails are read only
+type EmailHandler struct{}
+
+func (h *EmailHandler) Sniff(filePath string, header []byte) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".msg":
+		return DetectContainer(header) == ContainerCFB
+	case ".eml":
+		container := DetectContainer(header)
+		return container != ContainerZip && container != ContainerCFB && container != ContainerPDF
+	}
+	return false
+}
+
+func (h *EmailHandler) ReadLabels(filePath string) (Labels, bool, error) {
+	email, err := ReadEmail(filePath)
+	if err != nil {
+		return Labels{}, false, err
+	}
+	return Labels{Labels: email.Labels}, email.Labeled, nil
+}
+
+// ReadEmail parses an .msg (Outlook) or .eml (MIME) email
+func ReadEmail(filePath string) (*Email, error) {
+	data, err := os.ReadFile(LongPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	if DetectContainer(data) == ContainerCFB {
+		return parseMsg(data)
+	}
+	return parseEml(data)
+}
+
+// ParseMsipLabels converts the msip_labels header written by Office clients,
+// "MSIP_Label_<id>_Enabled=true; MSIP_Label_<id>_SiteId=<tenant>; ...",
+// to labels, labels that are not enabled are left out
+func ParseMsipLabels(header string) []Label {
+	labels := []Label{}
+	index := map[string]int{}
+	enabled := map[string]bool{}
+	for _, field := range strings.Split(header, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		m := msipProperty.FindStringSubmatch(strings.TrimSpace(key))
+		if m == nil {
+			continue
+		}
+		id := NormalizeId(m[1])
+		i, ok := index[id]
+		if !ok {
+			i = len(labels)
+			index[id] = i
+			labels = append(labels, Label{Id: "{" + m[1] + "}", Enabled: "1", Method: "Standard", ContentBits: "0", Removed: "0"})
+		}
+		value = strings.TrimSpace(value)
+		switch m[2] {
+		case "Enabled":
+			enabled[id] = strings.EqualFold(value, "true")
+		case "SiteId":
+			labels[i].SiteId = "{" + strings.Trim(value, "{}") + "}"
+		case "Method":
+			labels[i].Method = value
+		case "ContentBits":
+			labels[i].ContentBits = ContentBits(value)
+		}
+	}
+	var out []Label
+	for _, label := range labels {
+		if enabled[NormalizeId(label.Id)] {
+			out = append(out, label)
+		}
+	}
+	if out == nil {
+		out = []Label{}
+	}
+	return out
+}
+
+func parseEml(data []byte) (*Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse email: %w", err)
+	}
+	email := &Email{Labels: []Label{}}
+	if header := msg.Header.Get("msip_labels"); header != "" {
+		email.Labeled = true
+		email.Labels = ParseMsipLabels(header)
+	}
+	err = emlAttachments(email, msg.Header, msg.Body)
+	return email, err
+}
+
+// mimeHeader is the part of mail.Header and multipart parts used to find attachments
+type mimeHeader interface {
+	Get(key string) string
+}
+
+var wordDecoder = new(mime.WordDecoder)
+
+// emlAttachments walks multipart bodies, parts with a file name are attachments
+func emlAttachments(email *Email, header mimeHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := r.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("unable to parse email: %w", err)
+			}
+			if err := emlAttachments(email, part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+	name := params["name"]
+	if _, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && dparams["filename"] != "" {
+		name = dparams["filename"]
+	}
+	if name == "" {
+		return nil
+	}
+	if decoded, err := wordDecoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("unable to decode attachment %s: %w", name, err)
+	}
+	email.Attachments = append(email.Attachments, EmailAttachment{Name: attachmentName(name), Data: content})
+	return nil
+}
+
+// MAPI property streams of .msg files
+const (
+	msgAttachPrefix     = "__attach_version1.0_#"
+	msgNameIdStorage    = "__nameid_version1.0"
+	msgTransportHeaders = "007D"
+	msgAttachData       = "__substg1.0_37010102"
+	msgAttachLongName   = "3707"
+	msgAttachName       = "3704"
+)
+
+func parseMsg(data []byte) (*Email, error) {
+	c, err := openCFB(data)
+	if err != nil {
+		return nil, err
+	}
+	root := c.entries[0]
+	email := &Email{Labels: []Label{}}
+
+	// msip_labels is a named property of the message, received emails also carry it
+	// in their transport headers
+	header, ok := msgNamedString(c, root, "msip_labels")
+	if !ok {
+		if headers, ok := msgString(c, root, msgTransportHeaders); ok {
+			if msg, err := mail.ReadMessage(strings.NewReader(headers + "\r\n\r\n")); err == nil {
+				header = msg.Header.Get("msip_labels")
+			}
+		}
+	}
+	if header != "" {
+		email.Labeled = true
+		email.Labels = ParseMsipLabels(header)
+	}
+
+	for _, e := range c.children(root) {
+		if e.typ != cfbStorage || !strings.HasPrefix(strings.ToLower(e.name), msgAttachPrefix) {
+			continue
+		}
+		// embedded messages and OLE objects have no binary data stream
+		content, ok := c.stream(e, msgAttachData)
+		if !ok {
+			continue
+		}
+		name, ok := msgString(c, e, msgAttachLongName)
+		if !ok {
+			name, _ = msgString(c, e, msgAttachName)
+		}
+		email.Attachments = append(email.Attachments, EmailAttachment{Name: attachmentName(name), Data: content})
+	}
+	return email, nil
+}
+
+// msgString reads a string property stored as Unicode (001F) or 8-bit (001E)
+func msgString(c *cfbFile, storage cfbEntry, id string) (string, bool) {
+	if data, ok := c.stream(storage, "__substg1.0_"+id+"001F"); ok {
+		return utf16String(data), true
+	}
+	if data, ok := c.stream(storage, "__substg1.0_"+id+"001E"); ok {
+		return strings.TrimRight(string(data), "\x00"), true
+	}
+	return "", false
+}
+
+// msgNamedString looks a string named property up through the named property
+// mapping: entry stream (0003) of name offsets and indexes into the string stream (0004)
+func msgNamedString(c *cfbFile, root cfbEntry, name string) (string, bool) {
+	var nameId cfbEntry
+	found := false
+	for _, e := range c.children(root) {
+		if e.typ == cfbStorage && strings.EqualFold(e.name, msgNameIdStorage) {
+			nameId, found = e, true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	entries, _ := c.stream(nameId, "__substg1.0_00030102")
+	names, _ := c.stream(nameId, "__substg1.0_00040102")
+	for i := 0; i+8 <= len(entries); i += 8 {
+		offset := binary.LittleEndian.Uint32(entries[i:])
+		kind := binary.LittleEndian.Uint32(entries[i+4:])
+		if kind&1 == 0 || int(offset)+4 > len(names) {
+			continue
+		}
+		size := int(binary.LittleEndian.Uint32(names[offset:]))
+		end := int(offset) + 4 + size
+		if end > len(names) || !strings.EqualFold(utf16String(names[offset+4:end]), name) {
+			continue
+		}
+		return msgString(c, root, fmt.Sprintf("%04X", 0x8000+kind>>16))
+	}
+	return "", false
+}
+
+// attachmentName strips directories some clients leave in attachment names
+func attachmentName(name string) string {
+	return path.Base(strings.ReplaceAll(name, `\`, "/"))
+}
+
+// ReadAttachmentLabels reads the labels of an attachment as ReadLabelsBytes
+func ReadAttachmentLabels(a EmailAttachment) (string, Labels, bool, error) {
+	return ReadLabelsBytes(a.Name, a.Data)
+}
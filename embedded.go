@@ -0,0 +1,113 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/WTFender/sensitivity_labels/opc"
+)
+
+// EmbeddedObject is a document embedded in an Office document, Path is its part
+// name, documents embedded in embedded documents are joined with "!/"
+type EmbeddedObject struct {
+	Path string
+	Data []byte
+}
+
+// nested embeddings deeper than this are not opened
+const maxEmbeddingDepth = 4
+
+// name of the OLE stream holding an Office document embedded as an OLE object
+const olePackageStream = "Package"
+
+// EmbeddedObjects returns the Office documents found in the embeddings/ parts of
+// a document, either stored as a package or wrapped in an OLE object
+func EmbeddedObjects(filePath string) ([]EmbeddedObject, error) {
+	pkg, err := opc.Open(LongPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	return embeddedObjects(pkg, "", 1), nil
+}
+
+func embeddedObjects(pkg *opc.Package, prefix string, depth int) []EmbeddedObject {
+	var objects []EmbeddedObject
+	for _, name := range pkg.Parts() {
+		if !strings.Contains(strings.ToLower(name), "/embeddings/") {
+			continue
+		}
+		data, _ := pkg.Part(name)
+		if DetectContainer(data) == ContainerCFB {
+			c, err := openCFB(data)
+			if err != nil {
+				continue
+			}
+			if data, _ = c.stream(c.entries[0], olePackageStream); data == nil {
+				// charts, equations and other OLE objects are no documents
+				continue
+			}
+		}
+		if DetectContainer(data) != ContainerZip {
+			continue
+		}
+		object := EmbeddedObject{Path: prefix + name, Data: data}
+		objects = append(objects, object)
+		if depth < maxEmbeddingDepth {
+			if inner, err := opc.Read(bytes.NewReader(data), int64(len(data))); err == nil {
+				objects = append(objects, embeddedObjects(inner, object.Path+"!/", depth+1)...)
+			}
+		}
+	}
+	return objects
+}
+
+// ReadLabelsBytes reads the labels of a document held in memory through the format
+// handlers, returning the handler name, ErrUnsupportedFormat when none recognizes it
+func ReadLabelsBytes(name string, data []byte) (string, Labels, bool, error) {
+	fsys := NewMemFS()
+	name = path.Base(name)
+	if name == "." || name == "/" {
+		name = "document"
+	}
+	if err := fsys.WriteFile(name, data, 0644); err != nil {
+		return "", Labels{}, false, err
+	}
+	handlerName, handler, err := FindHandlerFS(fsys, name)
+	if err != nil {
+		return "", Labels{}, false, err
+	}
+	reader, ok := handler.(FSLabelReader)
+	if !ok {
+		return handlerName, Labels{}, false, ErrUnsupportedFormat
+	}
+	labels, exists, err := reader.ReadLabelsFS(fsys, name)
+	return handlerName, labels, exists, err
+}
+
+// WriteLabelsBytes returns a relabeled copy of a document held in memory, written by
+// the format handler recognizing it, ErrReadOnlyFormat when that handler can't write
+func WriteLabelsBytes(name string, data []byte, labels Labels) (string, []byte, error) {
+	fsys := NewMemFS()
+	name = path.Base(name)
+	if name == "." || name == "/" {
+		name = "document"
+	}
+	if err := fsys.WriteFile(name, data, 0644); err != nil {
+		return "", nil, err
+	}
+	handlerName, handler, err := FindHandlerFS(fsys, name)
+	if err != nil {
+		return "", nil, err
+	}
+	writer, ok := handler.(FSLabelWriter)
+	if !ok {
+		return handlerName, nil, ErrReadOnlyFormat
+	}
+	if err := writer.WriteLabelsFS(fsys, name, labels); err != nil {
+		return handlerName, nil, err
+	}
+	written, err := fs.ReadFile(fsys, name)
+	return handlerName, written, err
+}
@@ -0,0 +1,67 @@
+package sensitivity_labels
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Exclusions are paths that must never be modified, e.g. legal holds,
+// matching is case-insensitive so a pattern can't be bypassed on Windows
+type Exclusions struct {
+	Patterns []string
+}
+
+// ReadExclusions reads an exclusion list, one path or glob per line,
+// blank lines and lines starting with # are ignored
+func ReadExclusions(listPath string) (*Exclusions, error) {
+	f, err := os.Open(LongPath(listPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e := &Exclusions{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(exclusionKey(line), ""); err != nil {
+			return nil, err
+		}
+		e.Patterns = append(e.Patterns, line)
+	}
+	return e, scanner.Err()
+}
+
+// Match returns the pattern excluding filePath, a pattern excludes the path itself and
+// everything below it, globs (*, ? and [...]) are matched against the path and its parents
+func (e *Exclusions) Match(filePath string) (string, bool) {
+	if e == nil {
+		return "", false
+	}
+	key := exclusionKey(filePath)
+	for _, pattern := range e.Patterns {
+		patternKey := exclusionKey(pattern)
+		for p := key; ; p = path.Dir(p) {
+			if matched, _ := path.Match(patternKey, p); matched || p == patternKey {
+				return pattern, true
+			}
+			if parent := path.Dir(p); parent == p {
+				break
+			}
+		}
+	}
+	return "", false
+}
+
+// exclusionKey is the absolute, slash separated, lowercase form of a path
+func exclusionKey(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	return strings.ToLower(filepath.ToSlash(p))
+}
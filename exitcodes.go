@@ -0,0 +1,17 @@
+package sensitivity_labels
+
+// exit codes returned by labels.exe, wrapping scripts can branch on these
+const (
+	// every file was processed successfully
+	ExitSuccess = 0
+	// the run was aborted by an unrecoverable error
+	ExitFatal = 1
+	// invalid command, arguments or flags
+	ExitUsage = 2
+	// files carrying forbidden labels or otherwise violating policy were found
+	ExitPolicyViolation = 3
+	// the run completed but some files could not be processed
+	ExitFileErrors = 4
+	// the run was stopped by SIGINT or SIGTERM before all files were processed
+	ExitCancelled = 130
+)
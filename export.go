@@ -0,0 +1,133 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WTFender/sensitivity_labels/opc"
+)
+
+// files written for each document of an export,
+// under a directory named after the document's relative path
+const (
+	ExportIndexName      = "index.json"
+	ExportLabelInfoName  = "LabelInfo.xml"
+	ExportPropertiesName = "msip-properties.json"
+)
+
+// ExportEntry is the label state of a single document, Path is relative to
+// the exported root and uses forward slashes
+type ExportEntry struct {
+	Path       string            `json:"path"`
+	LabelInfo  bool              `json:"labelInfo"`
+	Labels     []Label           `json:"labels"`
+	Properties map[string]string `json:"msipProperties,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// ExportIndex lists every exported document
+type ExportIndex struct {
+	Created string        `json:"created"`
+	Source  string        `json:"source"`
+	Entries []ExportEntry `json:"entries"`
+}
+
+func NewExportIndex(source string) *ExportIndex {
+	return &ExportIndex{
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Source:  source,
+		Entries: []ExportEntry{},
+	}
+}
+
+// ExportDocument copies the LabelInfo.xml part and MSIP_Label_* custom properties
+// of filePath into outDir/relPath, documents without either only get an index entry
+func ExportDocument(filePath, relPath, outDir string) (ExportEntry, error) {
+	entry := ExportEntry{Path: filepath.ToSlash(relPath), Labels: []Label{}}
+	pkg, err := opc.Open(LongPath(filePath))
+	if err != nil {
+		return entry, err
+	}
+	dir := filepath.Join(outDir, filepath.FromSlash(entry.Path))
+	if data, ok := pkg.Part(LabelInfoPart); ok {
+		labels, err := ParseLabelInfo(data)
+		if err != nil {
+			return entry, err
+		}
+		entry.LabelInfo = true
+		entry.Labels = labels.Labels
+		if err := writeExportFile(dir, ExportLabelInfoName, data); err != nil {
+			return entry, err
+		}
+	}
+	if data, ok := pkg.Part("docProps/custom.xml"); ok {
+		var custom customProperties
+		if err := DecodeXML(bytes.NewReader(data), "docProps/custom.xml", &custom, DefaultXMLLimits); err != nil {
+			return entry, err
+		}
+		for _, p := range custom.Properties {
+			if !strings.HasPrefix(p.Name, "MSIP_Label_") {
+				continue
+			}
+			if entry.Properties == nil {
+				entry.Properties = map[string]string{}
+			}
+			entry.Properties[p.Name] = p.Value.Text
+		}
+	}
+	if entry.Properties != nil {
+		data, err := json.MarshalIndent(entry.Properties, "", "  ")
+		if err != nil {
+			return entry, err
+		}
+		if err := writeExportFile(dir, ExportPropertiesName, data); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+func writeExportFile(dir, name string, data []byte) error {
+	if err := checkWrite(filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(LongPath(dir), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(LongPath(filepath.Join(dir, name)), data, 0644)
+}
+
+// Write saves the index as outDir/index.json
+func (x *ExportIndex) Write(outDir string) error {
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := checkWrite(filepath.Join(outDir, ExportIndexName)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(LongPath(outDir), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(LongPath(filepath.Join(outDir, ExportIndexName)), data, 0644)
+}
+
+// ReadExportIndex reads an index, path may be the index file or the export directory
+func ReadExportIndex(path string) (*ExportIndex, error) {
+	if info, err := os.Stat(LongPath(path)); err == nil && info.IsDir() {
+		path = filepath.Join(path, ExportIndexName)
+	}
+	data, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var x ExportIndex
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	return &x, nil
+}
@@ -0,0 +1,123 @@
+package sensitivity_labels
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrFCIUnsupported is returned outside of Windows, where there is no File Server Resource Manager
+var ErrFCIUnsupported = errors.New("FSRM file classification is only supported on Windows file servers")
+
+// fciScript sets file classification properties through the FSRM COM API,
+// reading one JSON request per line and answering each with one JSON line
+const fciScript = `$ErrorActionPreference = 'Stop'
+$mgr = New-Object -ComObject Fsrm.FsrmClassificationManager
+[Console]::Out.WriteLine('{}')
+while (($line = [Console]::In.ReadLine()) -ne $null) {
+	$r = $line | ConvertFrom-Json
+	try {
+		foreach ($p in $r.properties.PSObject.Properties) { $mgr.SetFileProperty($r.path, $p.Name, [string]$p.Value) }
+		[Console]::Out.WriteLine('{}')
+	} catch {
+		[Console]::Out.WriteLine((@{error = $_.Exception.Message} | ConvertTo-Json -Compress))
+	}
+}`
+
+type fciRequest struct {
+	Path       string            `json:"path"`
+	Properties map[string]string `json:"properties"`
+}
+
+type fciResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// FCIPublisher sets FSRM classification properties of files so file management
+// tasks can act on them, properties must be defined on the server beforehand
+type FCIPublisher struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// StartFCIPublisher starts a PowerShell process holding the FSRM classification manager
+func StartFCIPublisher() (*FCIPublisher, error) {
+	if runtime.GOOS != "windows" {
+		return nil, ErrFCIUnsupported
+	}
+	// the script is passed encoded so stdin only carries requests
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(fciScript))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &FCIPublisher{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}
+	// the first line confirms the classification manager was created
+	if err := p.response(); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("unable to start the FSRM classification manager: %w", err)
+	}
+	return p, nil
+}
+
+// SetProperties sets the classification properties of filePath, name to value
+func (p *FCIPublisher) SetProperties(filePath string, properties map[string]string) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	line, err := json.Marshal(fciRequest{Path: filePath, Properties: properties})
+	if err != nil {
+		return err
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.response()
+}
+
+func (p *FCIPublisher) response() error {
+	line, err := p.stdout.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	var resp fciResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return fmt.Errorf("invalid response %q: %w", strings.TrimSpace(string(line)), err)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// encodePowerShell returns the base64 UTF-16LE form -EncodedCommand expects
+func encodePowerShell(script string) string {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(script)) {
+		b = append(b, byte(r), byte(r>>8))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Close ends the PowerShell process
+func (p *FCIPublisher) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
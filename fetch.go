@@ -0,0 +1,57 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsURL reports whether a source is an http(s) URL rather than a path
+func IsURL(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// FetchOptions configures FetchURL, Headers are sent with every request (e.g.
+// Authorization), documents larger than MaxBytes fail (0 for no limit)
+type FetchOptions struct {
+	Headers  map[string]string
+	MaxBytes int64
+	Timeout  time.Duration
+}
+
+// FetchURL downloads a document into memory, modified is the Last-Modified time
+// reported by the server, zero when unknown
+func FetchURL(url string, opts FetchOptions) ([]byte, time.Time, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for name, value := range opts.Headers {
+		req.Header.Set(name, value)
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body := io.Reader(resp.Body)
+	if opts.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, opts.MaxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if opts.MaxBytes > 0 && int64(len(data)) > opts.MaxBytes {
+		return nil, time.Time{}, fmt.Errorf("GET %s: document larger than %d bytes", url, opts.MaxBytes)
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return data, modified, nil
+}
@@ -0,0 +1,259 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FlatOPCNamespace is the namespace of flat OPC documents, the single file
+// "XML Document" and "XML Presentation" formats of Word and PowerPoint
+const FlatOPCNamespace = "http://schemas.microsoft.com/office/2006/xmlPackage"
+
+// FlatOPCHandler reads and writes the /docMetadata/LabelInfo.xml part of flat OPC
+// documents, parts other than LabelInfo.xml and the package relationships are
+// written back byte for byte
+type FlatOPCHandler struct{}
+
+// flatPart is the location of a pkg:part in the document, data is the
+// content of its pkg:xmlData or pkg:binaryData element
+type flatPart struct {
+	name       string
+	start, end int64
+	dataStart  int64
+	dataEnd    int64
+	binary     bool
+}
+
+// flatPackage is a parsed flat OPC document, prefix is the namespace prefix
+// of the pkg:package element and end the offset of its end tag
+type flatPackage struct {
+	data   []byte
+	prefix string
+	parts  []flatPart
+	end    int64
+}
+
+// Sniff looks for the package namespace whatever the extension, Word opens
+// renamed flat OPC files as well
+func (h *FlatOPCHandler) Sniff(filePath string, header []byte) bool {
+	return DetectContainer(header) == ContainerXML && bytes.Contains(header, []byte(FlatOPCNamespace))
+}
+
+func (h *FlatOPCHandler) ReadLabels(filePath string) (Labels, bool, error) {
+	data, err := os.ReadFile(LongPath(filePath))
+	if err != nil {
+		return Labels{}, false, err
+	}
+	return readFlatLabelInfo(data)
+}
+
+// ReadLabelsFS reads labels of a document in fsys
+func (h *FlatOPCHandler) ReadLabelsFS(fsys fs.FS, name string) (Labels, bool, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Labels{}, false, err
+	}
+	return readFlatLabelInfo(data)
+}
+
+func (h *FlatOPCHandler) WriteLabels(filePath string, labels Labels) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(LongPath(filePath))
+	if err != nil {
+		return err
+	}
+	if data, err = writeFlatLabelInfo(data, labels); err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, data)
+}
+
+// WriteLabelsFS writes labels to a document in fsys
+func (h *FlatOPCHandler) WriteLabelsFS(fsys WriteFS, name string, labels Labels) error {
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	if data, err = writeFlatLabelInfo(data, labels); err != nil {
+		return err
+	}
+	return fsys.WriteFile(name, data, 0644)
+}
+
+func readFlatLabelInfo(data []byte) (Labels, bool, error) {
+	pkg, err := parseFlatPackage(data)
+	if err != nil {
+		return Labels{}, false, err
+	}
+	part, ok := pkg.part("/" + LabelInfoPart)
+	if !ok {
+		return Labels{}, false, nil
+	}
+	if data, err = pkg.partData(part); err != nil {
+		return Labels{}, false, err
+	}
+	labels, err := ParseLabelInfo(data)
+	return labels, true, err
+}
+
+func writeFlatLabelInfo(data []byte, labels Labels) ([]byte, error) {
+	pkg, err := parseFlatPackage(data)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.setLabelInfo(labels)
+}
+
+func parseFlatPackage(data []byte) (*flatPackage, error) {
+	pkg := &flatPackage{data: data, end: -1}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var part flatPart
+	depth := 0
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newXMLError("flat OPC package", d, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if t.Name.Space != FlatOPCNamespace {
+				continue
+			}
+			switch {
+			case depth == 1 && t.Name.Local == "package":
+				pkg.prefix = rawPrefix(data[offset:])
+			case depth == 2 && t.Name.Local == "part":
+				part = flatPart{start: offset, dataStart: -1}
+				for _, attr := range t.Attr {
+					if attr.Name.Space == FlatOPCNamespace && attr.Name.Local == "name" {
+						part.name = attr.Value
+					}
+				}
+			case depth == 3 && (t.Name.Local == "xmlData" || t.Name.Local == "binaryData"):
+				part.dataStart = d.InputOffset()
+				part.binary = t.Name.Local == "binaryData"
+			}
+		case xml.EndElement:
+			depth--
+			if t.Name.Space != FlatOPCNamespace {
+				continue
+			}
+			switch {
+			case depth == 0 && t.Name.Local == "package":
+				pkg.end = offset
+			case depth == 1 && t.Name.Local == "part":
+				part.end = d.InputOffset()
+				pkg.parts = append(pkg.parts, part)
+			case depth == 2 && (t.Name.Local == "xmlData" || t.Name.Local == "binaryData"):
+				part.dataEnd = offset
+			}
+		}
+	}
+	if pkg.end < 0 {
+		return nil, fmt.Errorf("%w: not a flat OPC package", ErrUnsupportedFormat)
+	}
+	return pkg, nil
+}
+
+// rawPrefix returns the namespace prefix of the start tag at the beginning of data
+func rawPrefix(data []byte) string {
+	end := bytes.IndexAny(data, " \t\r\n/>")
+	if end < 0 {
+		return ""
+	}
+	name := string(data[1:end])
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// part names are compared ignoring case as in OPC
+func (pkg *flatPackage) part(name string) (flatPart, bool) {
+	for _, part := range pkg.parts {
+		if strings.EqualFold(part.name, name) {
+			return part, true
+		}
+	}
+	return flatPart{}, false
+}
+
+func (pkg *flatPackage) partData(part flatPart) ([]byte, error) {
+	if part.dataStart < 0 {
+		return nil, nil
+	}
+	data := pkg.data[part.dataStart:part.dataEnd]
+	if !part.binary {
+		return data, nil
+	}
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+}
+
+// flatEdit replaces data[start:end]
+type flatEdit struct {
+	start, end int64
+	data       string
+}
+
+// setLabelInfo returns the document with labels in its LabelInfo.xml part,
+// adding the part and its package relationship when missing
+func (pkg *flatPackage) setLabelInfo(labels Labels) ([]byte, error) {
+	p := pkg.prefix
+	if p != "" {
+		p += ":"
+	}
+	labelInfo := templateLabelInfoXml(labels)
+	if i := strings.Index(labelInfo, "?>"); i >= 0 {
+		labelInfo = labelInfo[i+2:]
+	}
+	newPart := fmt.Sprintf(`<%[1]spart %[1]sname="/%[2]s" %[1]scontentType="%[3]s"><%[1]sxmlData>%[4]s</%[1]sxmlData></%[1]spart>`,
+		p, LabelInfoPart, LabelInfoContentType, labelInfo)
+
+	var edits []flatEdit
+	if part, ok := pkg.part("/" + LabelInfoPart); ok {
+		edits = append(edits, flatEdit{part.start, part.end, newPart})
+	} else {
+		edits = append(edits, flatEdit{pkg.end, pkg.end, newPart})
+	}
+	if rels, ok := pkg.part("/_rels/.rels"); ok && !rels.binary && rels.dataStart >= 0 {
+		data := pkg.data[rels.dataStart:rels.dataEnd]
+		if !bytes.Contains(data, []byte(LabelInfoRelationshipType)) {
+			i := bytes.LastIndex(data, []byte("</Relationships>"))
+			if i < 0 {
+				return nil, fmt.Errorf("unable to add the LabelInfo.xml relationship to /_rels/.rels")
+			}
+			id := ""
+			for n := 1; id == "" || bytes.Contains(data, []byte(`Id="`+id+`"`)); n++ {
+				id = fmt.Sprintf("rId%d", n)
+			}
+			rel := fmt.Sprintf(`<Relationship Id="%s" Type="%s" Target="%s"/>`, id, LabelInfoRelationshipType, LabelInfoPart)
+			offset := rels.dataStart + int64(i)
+			edits = append(edits, flatEdit{offset, offset, rel})
+		}
+	}
+
+	// apply edits back to front so earlier offsets stay valid
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	data := append([]byte{}, pkg.data...)
+	for _, e := range edits {
+		data = append(data[:e.start], append([]byte(e.data), data[e.end:]...)...)
+	}
+	return data, nil
+}
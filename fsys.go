@@ -0,0 +1,146 @@
+package sensitivity_labels
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing/fstest"
+)
+
+// WriteFS is a filesystem that can also be written, so the same code path
+// runs against the OS, an in-memory filesystem in tests or remote storage
+type WriteFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Remove(name string) error
+}
+
+// OSFS is a WriteFS rooted at a directory of the local filesystem,
+// names use forward slashes and are relative to Root
+type OSFS struct {
+	Root string
+}
+
+func (o OSFS) path(name string) string {
+	return LongPath(filepath.Join(o.Root, filepath.FromSlash(name)))
+}
+
+func (o OSFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Open(o.path(name))
+}
+
+func (o OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	return os.WriteFile(o.path(name), data, perm)
+}
+
+func (o OSFS) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	return os.Remove(o.path(name))
+}
+
+// MemFS is an in-memory WriteFS, safe for concurrent use
+type MemFS struct {
+	mu    sync.RWMutex
+	files fstest.MapFS
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{files: fstest.MapFS{}}
+}
+
+// Open returns a snapshot of the file, later writes are not visible through it
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(fstest.MapFS, len(m.files))
+	for n, f := range m.files {
+		snapshot[n] = f
+	}
+	return snapshot.Open(name)
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = &fstest.MapFile{Data: append([]byte{}, data...), Mode: perm}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// FSLabelReader is implemented by handlers that can read labels through an fs.FS
+type FSLabelReader interface {
+	ReadLabelsFS(fsys fs.FS, name string) (Labels, bool, error)
+}
+
+// FSLabelWriter is implemented by handlers that can write labels through a WriteFS
+type FSLabelWriter interface {
+	WriteLabelsFS(fsys WriteFS, name string, labels Labels) error
+}
+
+// FindHandlerFS returns the first registered handler recognizing a file of fsys
+func FindHandlerFS(fsys fs.FS, name string) (string, LabelReader, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", nil, err
+	}
+	header := make([]byte, sniffSize)
+	n, _ := f.Read(header)
+	f.Close()
+	return sniffHandler(name, header[:n])
+}
+
+// ListFilesFS returns the files of fsys under root matching the extensions
+func ListFilesFS(fsys fs.FS, root string, recursive bool, exts []string) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name != root && !recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), "~$") {
+			return nil
+		}
+		for _, ext := range exts {
+			if strings.EqualFold(path.Ext(name), ext) {
+				names = append(names, name)
+				break
+			}
+		}
+		return nil
+	})
+	return names, err
+}
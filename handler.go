@@ -0,0 +1,109 @@
+package sensitivity_labels
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// LabelReader is implemented by a format handler to detect and read labels of
+// its documents, ReadLabels also reports whether label metadata exists
+type LabelReader interface {
+	Sniff(filePath string, header []byte) bool
+	ReadLabels(filePath string) (Labels, bool, error)
+}
+
+// LabelWriter is implemented by handlers able to write labels, handlers
+// without it are read only
+type LabelWriter interface {
+	WriteLabels(filePath string, labels Labels) error
+}
+
+// bytes passed to Sniff
+const sniffSize = 512
+
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+var ErrReadOnlyFormat = errors.New("format handler does not support writing labels")
+
+type registeredHandler struct {
+	name    string
+	handler LabelReader
+}
+
+var handlersMu sync.RWMutex
+var handlers []registeredHandler
+
+// RegisterHandler adds a format handler, handlers are consulted in the order they
+// were registered and registering an existing name replaces that handler
+func RegisterHandler(name string, handler LabelReader) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	for i, h := range handlers {
+		if h.name == name {
+			handlers[i].handler = handler
+			return
+		}
+	}
+	handlers = append(handlers, registeredHandler{name, handler})
+}
+
+// Handlers returns the names of the registered handlers
+func Handlers() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	names := make([]string, len(handlers))
+	for i, h := range handlers {
+		names[i] = h.name
+	}
+	return names
+}
+
+// LookupHandler returns the handler registered under name
+func LookupHandler(name string) (LabelReader, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	for _, h := range handlers {
+		if h.name == name {
+			return h.handler, true
+		}
+	}
+	return nil, false
+}
+
+// FindHandler returns the first registered handler recognizing the file
+func FindHandler(filePath string) (string, LabelReader, error) {
+	f, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return "", nil, err
+	}
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, header)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	return sniffHandler(filePath, header[:n])
+}
+
+func sniffHandler(filePath string, header []byte) (string, LabelReader, error) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	for _, h := range handlers {
+		if h.handler.Sniff(filePath, header) {
+			return h.name, h.handler, nil
+		}
+	}
+	// a renamed file, e.g. a legacy .doc saved as .docx, is reported as such
+	if err := checkContainer(filePath, header); err != nil {
+		return "", nil, err
+	}
+	return "", nil, ErrUnsupportedFormat
+}
+
+func init() {
+	RegisterHandler("ooxml", &OOXMLHandler{MemoryThreshold: DefaultMemoryThreshold, Unzip: DefaultUnzipOptions})
+	RegisterHandler("flatopc", &FlatOPCHandler{})
+	RegisterHandler("email", &EmailHandler{})
+	RegisterHandler("tar", &TarHandler{})
+}
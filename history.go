@@ -0,0 +1,82 @@
+package sensitivity_labels
+
+import (
+	"regexp"
+	"sort"
+)
+
+// LabelHistoryEntry is a label application recorded in the legacy
+// MSIP_Label_<id>_<field> custom properties
+type LabelHistoryEntry struct {
+	LabelId     string
+	Name        string      `json:",omitempty"`
+	SiteId      string      `json:",omitempty"`
+	Enabled     string      `json:",omitempty"`
+	SetDate     string      `json:",omitempty"`
+	Method      string      `json:",omitempty"`
+	ActionId    string      `json:",omitempty"`
+	Owner       string      `json:",omitempty"` // only written by older clients
+	ContentBits ContentBits `json:",omitempty"`
+}
+
+var msipProperty = regexp.MustCompile(`^MSIP_Label_([0-9A-Fa-f-]{36})_(\w+)$`)
+
+// GetLabelHistory groups the MSIP_Label_* custom properties of an extracted
+// document by label, ordered by the date the label was set
+func GetLabelHistory(unzipDir string) ([]LabelHistoryEntry, error) {
+	var custom customProperties
+	if err := readXmlPart(unzipDir+"/docProps/custom.xml", &custom); err != nil {
+		return nil, err
+	}
+	var history []LabelHistoryEntry
+	index := map[string]int{}
+	for _, p := range custom.Properties {
+		m := msipProperty.FindStringSubmatch(p.Name)
+		if m == nil {
+			continue
+		}
+		id := NormalizeId(m[1])
+		i, ok := index[id]
+		if !ok {
+			i = len(history)
+			index[id] = i
+			history = append(history, LabelHistoryEntry{LabelId: id})
+		}
+		entry := &history[i]
+		value := p.Value.Text
+		switch m[2] {
+		case "Name":
+			entry.Name = value
+		case "SiteId":
+			entry.SiteId = value
+		case "Enabled":
+			entry.Enabled = value
+		case "SetDate":
+			entry.SetDate = value
+		case "Method":
+			entry.Method = value
+		case "ActionId":
+			entry.ActionId = value
+		case "Owner":
+			entry.Owner = value
+		case "ContentBits":
+			entry.ContentBits = ContentBits(value)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].SetDate < history[j].SetDate })
+	return history, nil
+}
+
+// LabelSetDate returns the latest setDate recorded for any of labels,
+// empty when the document carries no MSIP_Label_* properties for them
+func LabelSetDate(history []LabelHistoryEntry, labels []Label) string {
+	setDate := ""
+	for _, entry := range history {
+		for _, label := range labels {
+			if NormalizeId(label.Id) == entry.LabelId && entry.SetDate > setDate {
+				setDate = entry.SetDate
+			}
+		}
+	}
+	return setDate
+}
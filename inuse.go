@@ -0,0 +1,31 @@
+package sensitivity_labels
+
+import (
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// OwnerFilePaths returns the possible ~$ owner files Office creates next to an open
+// document, Word replaces the first two characters of long names instead of prefixing them
+func OwnerFilePaths(filePath string) []string {
+	dir, name := filepath.Split(filePath)
+	paths := []string{filepath.Join(dir, "~$"+name)}
+	if utf8.RuneCountInString(name) > 2 {
+		_, first := utf8.DecodeRuneInString(name)
+		_, second := utf8.DecodeRuneInString(name[first:])
+		paths = append(paths, filepath.Join(dir, "~$"+name[first+second:]))
+	}
+	return paths
+}
+
+// IsInUse reports whether the file is open in Office, either through an
+// owner file or (on Windows) another process holding an exclusive lock
+func IsInUse(filePath string) bool {
+	for _, ownerPath := range OwnerFilePaths(filePath) {
+		if _, err := os.Stat(LongPath(ownerPath)); err == nil {
+			return true
+		}
+	}
+	return isLocked(filePath)
+}
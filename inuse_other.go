@@ -0,0 +1,8 @@
+//go:build !windows
+
+package sensitivity_labels
+
+// isLocked always returns false, file locks are advisory outside of Windows
+func isLocked(filePath string) bool {
+	return false
+}
@@ -0,0 +1,25 @@
+package sensitivity_labels
+
+import "syscall"
+
+// isLocked opens the file without sharing, which fails while another process has it open
+func isLocked(filePath string) bool {
+	pathPtr, err := syscall.UTF16PtrFromString(LongPath(filePath))
+	if err != nil {
+		return false
+	}
+	h, err := syscall.CreateFile(
+		pathPtr,
+		syscall.GENERIC_READ,
+		0,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	if err != nil {
+		return err == errorSharingViolation || err == errorLockViolation
+	}
+	syscall.CloseHandle(h)
+	return false
+}
@@ -0,0 +1,97 @@
+package sensitivity_labels
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const LockFileName = ".labels.lock"
+
+// LockInfo is written to the lock file so other runs can report who holds it
+type LockInfo struct {
+	Pid     int    `json:"pid"`
+	Host    string `json:"host"`
+	RunId   string `json:"runId"`
+	Created string `json:"created"`
+}
+
+// Lock is a run-level lock file held in the target root
+type Lock struct {
+	Path string
+}
+
+var ErrLocked = errors.New("target is locked by another run")
+
+// LockPath returns the lock file location for a target, files are locked through their directory
+func LockPath(root string) string {
+	info, err := os.Stat(LongPath(root))
+	if err == nil && !info.IsDir() {
+		root = filepath.Dir(root)
+	}
+	return filepath.Join(root, LockFileName)
+}
+
+// AcquireLock creates the lock file for root, waiting up to wait for another run
+// to release it, force removes an existing lock (e.g. left by a crashed run)
+func AcquireLock(root, runId string, wait time.Duration, force bool) (*Lock, error) {
+	lockPath := LockPath(root)
+	if err := checkWrite(lockPath); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	info, err := json.Marshal(LockInfo{
+		Pid:     os.Getpid(),
+		Host:    host,
+		RunId:   runId,
+		Created: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if force {
+		os.Remove(LongPath(lockPath))
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := os.OpenFile(LongPath(lockPath), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(info)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(LongPath(lockPath))
+				return nil, err
+			}
+			return &Lock{Path: lockPath}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			holder, _ := ReadLock(lockPath)
+			return nil, fmt.Errorf("%w: %s (pid %d on %s, run %s since %s)",
+				ErrLocked, lockPath, holder.Pid, holder.Host, holder.RunId, holder.Created)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ReadLock returns the details of the run holding a lock file
+func ReadLock(lockPath string) (LockInfo, error) {
+	var info LockInfo
+	data, err := os.ReadFile(LongPath(lockPath))
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+func (l *Lock) Release() error {
+	return os.Remove(LongPath(l.Path))
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package sensitivity_labels
+
+// LongPath returns path unchanged, extended-length paths are only needed on Windows
+func LongPath(path string) string {
+	return path
+}
@@ -0,0 +1,22 @@
+package sensitivity_labels
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LongPath converts path to an extended-length \\?\ path so paths longer
+// than MAX_PATH (260 characters) can be opened, UNC paths become \\?\UNC\server\share
+func LongPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,49 @@
+package sensitivity_labels
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// MacroInfo reports VBA projects found in a document
+type MacroInfo struct {
+	HasMacros         bool
+	Parts             []string `json:",omitempty"`
+	ExtensionMismatch bool     `json:",omitempty"` // macros in a file whose extension is not macro-enabled
+}
+
+// extensions that Office opens with macros disabled
+var macroFreeExtensions = []string{".docx", ".dotx", ".xlsx", ".xltx", ".pptx", ".potx", ".ppsx"}
+
+// FindMacros looks for vbaProject.bin parts in an extracted document
+func FindMacros(unzipDir, filePath string) (MacroInfo, error) {
+	var info MacroInfo
+	root := LongPath(unzipDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.EqualFold(d.Name(), "vbaProject.bin") {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			info.Parts = append(info.Parts, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return info, err
+	}
+	info.HasMacros = len(info.Parts) > 0
+	if info.HasMacros {
+		ext := strings.ToLower(filepath.Ext(filePath))
+		for _, e := range macroFreeExtensions {
+			if ext == e {
+				info.ExtensionMismatch = true
+			}
+		}
+	}
+	return info, nil
+}
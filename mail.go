@@ -0,0 +1,89 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// MailOptions is the SMTP server and envelope used by SendMail, the server is host:port,
+// STARTTLS is used when the server offers it and User enables PLAIN authentication
+type MailOptions struct {
+	Server   string
+	From     string
+	To       []string
+	User     string
+	Password string
+}
+
+// MailAttachment is a file attached to a mail
+type MailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// SendMail sends a plain text mail with attachments
+func SendMail(opts MailOptions, subject, body string, attachments []MailAttachment) error {
+	if opts.Server == "" || opts.From == "" || len(opts.To) == 0 {
+		return fmt.Errorf("mail requires a server, a sender and recipients")
+	}
+	msg, err := buildMail(opts, subject, body, attachments)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if opts.User != "" {
+		host, _, err := net.SplitHostPort(opts.Server)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", opts.User, opts.Password, host)
+	}
+	return smtp.SendMail(opts.Server, auth, opts.From, opts.To, msg)
+}
+
+func buildMail(opts MailOptions, subject, body string, attachments []MailAttachment) ([]byte, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	boundary := "labels-" + hex.EncodeToString(b)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", opts.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(opts.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&msg, []byte(body))
+	for _, a := range attachments {
+		fmt.Fprintf(&msg, "--%s\r\n", boundary)
+		fmt.Fprintf(&msg, "Content-Type: %s\r\n", a.ContentType)
+		fmt.Fprintf(&msg, "Content-Disposition: attachment; filename=%q\r\n", a.Name)
+		fmt.Fprintf(&msg, "Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64Lines(&msg, a.Data)
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+	return msg.Bytes(), nil
+}
+
+// writeBase64Lines wraps base64 at 76 characters as MIME requires
+func writeBase64Lines(msg *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+}
@@ -0,0 +1,138 @@
+package sensitivity_labels
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ManifestEntry describes the change made to a single file
+type ManifestEntry struct {
+	FilePath     string  `json:"filePath"`
+	HashBefore   string  `json:"sha256Before"`
+	HashAfter    string  `json:"sha256After"`
+	LabelsBefore []Label `json:"labelsBefore"`
+	LabelsAfter  []Label `json:"labelsAfter"`
+}
+
+// Manifest is the list of changes made by a bulk set,
+// Signature covers the json encoding of Created and Entries
+type Manifest struct {
+	Created      string          `json:"created"`
+	Entries      []ManifestEntry `json:"entries"`
+	SignatureAlg string          `json:"signatureAlg,omitempty"`
+	Signature    string          `json:"signature,omitempty"`
+	Certificate  string          `json:"certificate,omitempty"`
+}
+
+// ErrUnsignedManifest is returned when verifying a manifest written without a signing key
+var ErrUnsignedManifest = errors.New("manifest is not signed")
+
+func NewManifest() *Manifest {
+	return &Manifest{
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Entries: []ManifestEntry{},
+	}
+}
+
+func (m *Manifest) Add(entry ManifestEntry) {
+	m.Entries = append(m.Entries, entry)
+}
+
+func (m *Manifest) payload() ([]byte, error) {
+	return json.Marshal(struct {
+		Created string          `json:"created"`
+		Entries []ManifestEntry `json:"entries"`
+	}{m.Created, m.Entries})
+}
+
+// SignHMAC signs the manifest with HMAC-SHA256 using the provided key
+func (m *Manifest) SignHMAC(key []byte) error {
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	m.SignatureAlg, m.Signature = signHMAC(payload, key)
+	m.Certificate = ""
+	return nil
+}
+
+// SignX509 signs the manifest with a PEM encoded private key (RSA, ECDSA or Ed25519),
+// certPEM is optional and embedded so verifiers know which key was used
+func (m *Manifest) SignX509(keyPEM, certPEM []byte) error {
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	alg, sig, err := signX509(payload, keyPEM)
+	if err != nil {
+		return err
+	}
+	m.SignatureAlg, m.Signature = alg, sig
+	m.Certificate = string(certPEM)
+	return nil
+}
+
+// VerifyHMAC reports whether the manifest signature matches the provided key
+func (m *Manifest) VerifyHMAC(key []byte) bool {
+	payload, err := m.payload()
+	if err != nil {
+		return false
+	}
+	return verifyHMAC(payload, key, m.SignatureAlg, m.Signature)
+}
+
+// VerifyX509 checks the manifest signature against the PEM certificate (or public key)
+// of the signer, the embedded certificate is never trusted, ErrSignature means the
+// manifest was altered after signing or signed with another key
+func (m *Manifest) VerifyX509(certPEM []byte) error {
+	if m.Signature == "" {
+		return ErrUnsignedManifest
+	}
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	return verifyX509(payload, certPEM, m.SignatureAlg, m.Signature)
+}
+
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Manifest) Write(path string) error {
+	jsonBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := checkWrite(path); err != nil {
+		return err
+	}
+	return os.WriteFile(LongPath(path), jsonBytes, 0644)
+}
+
+// HashFile returns the hex encoded SHA-256 of the file contents
+func HashFile(filePath string) (string, error) {
+	f, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
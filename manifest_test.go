@@ -0,0 +1,185 @@
+package sensitivity_labels
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testKeyPair returns a PEM private key and a self-signed PEM certificate
+// of the kind rsa, ecdsa or ed25519
+func testKeyPair(t *testing.T, kind string) ([]byte, []byte) {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch kind {
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+}
+
+func testManifest() *Manifest {
+	m := NewManifest()
+	m.Add(ManifestEntry{
+		FilePath:    "dir/report.docx",
+		HashBefore:  "aa",
+		HashAfter:   "bb",
+		LabelsAfter: []Label{{Id: "{11111111-1111-1111-1111-111111111111}", SiteId: "{33333333-3333-3333-3333-333333333333}"}},
+	})
+	return m
+}
+
+// manifestTampers alter a signed manifest in ways its signature must catch
+var manifestTampers = []struct {
+	name   string
+	tamper func(m *Manifest)
+}{
+	{"hash", func(m *Manifest) { m.Entries[0].HashAfter = "cc" }},
+	{"path", func(m *Manifest) { m.Entries[0].FilePath = "dir/other.docx" }},
+	{"label", func(m *Manifest) { m.Entries[0].LabelsAfter[0].Id = "{22222222-2222-2222-2222-222222222222}" }},
+	{"created", func(m *Manifest) { m.Created = "2020-01-01T00:00:00Z" }},
+	{"entry removed", func(m *Manifest) { m.Entries = m.Entries[:0] }},
+	{"entry added", func(m *Manifest) { m.Add(ManifestEntry{FilePath: "dir/added.docx"}) }},
+}
+
+func TestManifestHMAC(t *testing.T) {
+	key := []byte("manifest key")
+	m := testManifest()
+	if err := m.SignHMAC(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.VerifyHMAC(key) {
+		t.Fatal("VerifyHMAC() = false for the signing key")
+	}
+	if read.VerifyHMAC([]byte("other key")) {
+		t.Fatal("VerifyHMAC() = true for another key")
+	}
+	for _, tt := range manifestTampers {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered, err := ReadManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(tampered)
+			if tampered.VerifyHMAC(key) {
+				t.Fatal("VerifyHMAC() = true for a tampered manifest")
+			}
+		})
+	}
+}
+
+func TestManifestX509(t *testing.T) {
+	for _, kind := range []string{"rsa", "ecdsa", "ed25519"} {
+		t.Run(kind, func(t *testing.T) {
+			keyPEM, certPEM := testKeyPair(t, kind)
+			_, otherCertPEM := testKeyPair(t, kind)
+			m := testManifest()
+			if err := m.SignX509(keyPEM, certPEM); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "manifest.json")
+			if err := m.Write(path); err != nil {
+				t.Fatal(err)
+			}
+			read, err := ReadManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := read.VerifyX509(certPEM); err != nil {
+				t.Fatalf("VerifyX509() = %v for the signing certificate", err)
+			}
+			if err := read.VerifyX509(otherCertPEM); !errors.Is(err, ErrSignature) {
+				t.Fatalf("VerifyX509() = %v for another certificate, want ErrSignature", err)
+			}
+			for _, tt := range manifestTampers {
+				t.Run(tt.name, func(t *testing.T) {
+					tampered, err := ReadManifest(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					tt.tamper(tampered)
+					if err := tampered.VerifyX509(certPEM); !errors.Is(err, ErrSignature) {
+						t.Fatalf("VerifyX509() = %v for a tampered manifest, want ErrSignature", err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestManifestX509IgnoresEmbeddedCertificate(t *testing.T) {
+	keyPEM, certPEM := testKeyPair(t, "ecdsa")
+	forgedKeyPEM, forgedCertPEM := testKeyPair(t, "ecdsa")
+	m := testManifest()
+	if err := m.SignX509(keyPEM, certPEM); err != nil {
+		t.Fatal(err)
+	}
+	// re-signed with another key embedding its own certificate
+	m.Entries[0].HashAfter = "cc"
+	if err := m.SignX509(forgedKeyPEM, forgedCertPEM); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifyX509(certPEM); !errors.Is(err, ErrSignature) {
+		t.Fatalf("VerifyX509() = %v for a manifest re-signed by another key, want ErrSignature", err)
+	}
+}
+
+func TestManifestVerifyUnsigned(t *testing.T) {
+	_, certPEM := testKeyPair(t, "ecdsa")
+	m := testManifest()
+	if err := m.VerifyX509(certPEM); !errors.Is(err, ErrUnsignedManifest) {
+		t.Fatalf("VerifyX509() = %v, want ErrUnsignedManifest", err)
+	}
+	if m.VerifyHMAC([]byte("")) {
+		t.Fatal("VerifyHMAC() = true for an unsigned manifest")
+	}
+	hmacSigned := testManifest()
+	if err := hmacSigned.SignHMAC([]byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hmacSigned.VerifyX509(certPEM); !errors.Is(err, ErrSignature) {
+		t.Fatalf("VerifyX509() = %v for an HMAC signed manifest, want ErrSignature", err)
+	}
+}
@@ -0,0 +1,66 @@
+package sensitivity_labels
+
+import (
+	"os"
+)
+
+// DocumentProperties holds the core.xml and app.xml fields useful
+// next to labels, e.g. who created and last modified a document
+type DocumentProperties struct {
+	Title          string `json:",omitempty"`
+	Author         string `json:",omitempty"`
+	LastModifiedBy string `json:",omitempty"`
+	Created        string `json:",omitempty"`
+	Modified       string `json:",omitempty"`
+	Company        string `json:",omitempty"`
+	Application    string `json:",omitempty"`
+}
+
+// namespaces are ignored, only local element names are matched
+type coreProperties struct {
+	Title          string `xml:"title"`
+	Creator        string `xml:"creator"`
+	LastModifiedBy string `xml:"lastModifiedBy"`
+	Created        string `xml:"created"`
+	Modified       string `xml:"modified"`
+}
+
+type appProperties struct {
+	Company     string `xml:"Company"`
+	Application string `xml:"Application"`
+}
+
+// GetDocumentProperties reads docProps/core.xml and docProps/app.xml from an
+// extracted document, missing parts leave their fields empty
+func GetDocumentProperties(unzipDir string) (DocumentProperties, error) {
+	var props DocumentProperties
+	var core coreProperties
+	if err := readXmlPart(unzipDir+"/docProps/core.xml", &core); err != nil {
+		return props, err
+	}
+	var app appProperties
+	if err := readXmlPart(unzipDir+"/docProps/app.xml", &app); err != nil {
+		return props, err
+	}
+	props.Title = core.Title
+	props.Author = core.Creator
+	props.LastModifiedBy = core.LastModifiedBy
+	props.Created = core.Created
+	props.Modified = core.Modified
+	props.Company = app.Company
+	props.Application = app.Application
+	return props, nil
+}
+
+// readXmlPart unmarshals an extracted part, a missing part is not an error
+func readXmlPart(path string, v any) error {
+	f, err := os.Open(LongPath(path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return DecodeXML(f, path, v, DefaultXMLLimits)
+}
@@ -0,0 +1,50 @@
+package sensitivity_labels
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/WTFender/sensitivity_labels/opc"
+)
+
+// NormalizeLabels returns labels in the canonical form LabelInfo.xml is written in:
+// lowercase GUIDs (braced when written), trimmed values, 1/0 for enabled and removed
+// and contentBits as a plain decimal number
+func NormalizeLabels(labels []Label) Labels {
+	normalized := Labels{Labels: []Label{}}
+	for _, label := range labels {
+		label.Id = NormalizeId(label.Id)
+		label.SiteId = NormalizeId(label.SiteId)
+		label.Enabled = normalizeBool(label.Enabled)
+		label.Removed = normalizeBool(label.Removed)
+		label.Method = strings.TrimSpace(label.Method)
+		bits := strings.TrimSpace(string(label.ContentBits))
+		if n, err := strconv.ParseUint(bits, 10, 64); err == nil {
+			bits = strconv.FormatUint(n, 10)
+		}
+		label.ContentBits = ContentBits(bits)
+		normalized.Labels = append(normalized.Labels, label)
+	}
+	return normalized
+}
+
+func normalizeBool(value string) string {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "true":
+		return "1"
+	case "false":
+		return "0"
+	}
+	return value
+}
+
+// ReadLabelInfoXml returns the LabelInfo.xml part of a document as stored
+func ReadLabelInfoXml(filePath string) ([]byte, bool, error) {
+	pkg, err := opc.Open(LongPath(filePath))
+	if err != nil {
+		return nil, false, err
+	}
+	data, ok := pkg.Part(LabelInfoPart)
+	return data, ok, nil
+}
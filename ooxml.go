@@ -0,0 +1,192 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/WTFender/sensitivity_labels/opc"
+)
+
+// OOXMLHandler reads and writes docMetadata/LabelInfo.xml of Office Open XML documents,
+// documents up to MemoryThreshold bytes are processed in memory, larger
+// documents are extracted to TmpDir (the system default when empty) within the Unzip limits
+type OOXMLHandler struct {
+	MemoryThreshold int64
+	TmpDir          string
+	Unzip           UnzipOptions
+	Tmp             TmpOptions
+}
+
+const DefaultMemoryThreshold = 64 << 20
+
+// InMemory reports whether a document of size bytes is processed in memory
+func (h *OOXMLHandler) InMemory(size int64) bool {
+	return size <= h.MemoryThreshold
+}
+
+// inMemory stats the file, errors are left to the read
+func (h *OOXMLHandler) inMemory(filePath string) bool {
+	info, err := os.Stat(LongPath(filePath))
+	return err != nil || h.InMemory(info.Size())
+}
+
+// extract unzips a large document into a new temporary directory
+func (h *OOXMLHandler) extract(filePath string) (string, error) {
+	if h.Tmp.NoDisk {
+		return "", ErrNoDisk
+	}
+	if err := checkWrite(h.TmpDir); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(h.TmpDir, TmpDirName(filePath)+".*")
+	if err != nil {
+		return "", err
+	}
+	if err := CreateTmpDirWith(dir, filePath, "", h.Tmp); err != nil {
+		h.removeTmpDir(dir)
+		return "", err
+	}
+	if err := UnzipWith(filePath, dir, h.Unzip); err != nil {
+		h.removeTmpDir(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+func (h *OOXMLHandler) removeTmpDir(dir string) {
+	RemoveTmpDir(dir, h.Tmp)
+}
+
+var ooxmlExtensions = []string{".docx", ".docm", ".dotx", ".dotm", ".xlsx", ".xlsm", ".xltx", ".xltm", ".xlam", ".pptx", ".pptm", ".potx", ".potm", ".ppsx", ".ppsm", ".ppam"}
+
+// zip local file header
+var zipMagic = []byte("PK\x03\x04")
+
+// name of the first zip entry of packages written by Office
+var contentTypesEntry = []byte(opc.ContentTypesPart)
+
+// Sniff accepts zip files with an Office extension and, so renamed documents are
+// still read, zip files starting with [Content_Types].xml
+func (h *OOXMLHandler) Sniff(filePath string, header []byte) bool {
+	if !bytes.HasPrefix(header, zipMagic) {
+		return false
+	}
+	if ExpectedContainer(filepath.Ext(filePath)) == ContainerZip {
+		return true
+	}
+	// the first entry name follows the 30 byte local file header
+	return len(header) >= 30+len(contentTypesEntry) && bytes.Equal(header[30:30+len(contentTypesEntry)], contentTypesEntry)
+}
+
+// LabelInfo.xml content type and package relationship written by Office
+const (
+	LabelInfoContentType      = "application/vnd.ms-office.classificationlabels+xml"
+	LabelInfoRelationshipType = "http://schemas.microsoft.com/office/2020/02/relationships/classificationlabels"
+)
+
+func (h *OOXMLHandler) ReadLabels(filePath string) (Labels, bool, error) {
+	var labels Labels
+	if !h.inMemory(filePath) {
+		dir, err := h.extract(filePath)
+		if err != nil {
+			return labels, false, err
+		}
+		defer h.removeTmpDir(dir)
+		exists, labelInfoPath := CheckLabelInfoPath(dir)
+		if !exists {
+			return labels, false, nil
+		}
+		labels, err := ParseLabelInfoFile(labelInfoPath)
+		return labels, true, err
+	}
+	pkg, err := opc.OpenWith(LongPath(filePath), h.Unzip)
+	if err != nil {
+		return labels, false, err
+	}
+	return readLabelInfoPart(pkg)
+}
+
+// ReadLabelsFS reads labels of a document in fsys, always in memory
+func (h *OOXMLHandler) ReadLabelsFS(fsys fs.FS, name string) (Labels, bool, error) {
+	pkg, err := opc.OpenFSWith(fsys, name, h.Unzip)
+	if err != nil {
+		return Labels{}, false, err
+	}
+	return readLabelInfoPart(pkg)
+}
+
+func readLabelInfoPart(pkg *opc.Package) (Labels, bool, error) {
+	data, ok := pkg.Part(LabelInfoPart)
+	if !ok {
+		return Labels{}, false, nil
+	}
+	labels, err := ParseLabelInfo(data)
+	return labels, true, err
+}
+
+// WriteLabelsFS writes labels to a document in fsys
+func (h *OOXMLHandler) WriteLabelsFS(fsys WriteFS, name string, labels Labels) error {
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	pkg, err := opc.OpenFSWith(fsys, name, h.Unzip)
+	if err != nil {
+		return err
+	}
+	buf := bytes.Buffer{}
+	if err := writeLabelInfoPart(pkg, &buf, labels); err != nil {
+		return err
+	}
+	return fsys.WriteFile(name, buf.Bytes(), 0644)
+}
+
+func (h *OOXMLHandler) WriteLabels(filePath string, labels Labels) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	if !h.inMemory(filePath) {
+		dir, err := h.extract(filePath)
+		if err != nil {
+			return err
+		}
+		defer h.removeTmpDir(dir)
+		_, labelInfoPath := CheckLabelInfoPath(dir)
+		return SetLabels(dir, filePath, labelInfoPath, labels)
+	}
+	buf := bytes.Buffer{}
+	if err := setLabelsTo(filePath, &buf, labels, h.Unzip); err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, buf.Bytes())
+}
+
+// SetLabelsTo writes a relabeled copy of the document at src to w,
+// src is never modified
+func SetLabelsTo(src string, w io.Writer, labels Labels) error {
+	return setLabelsTo(src, w, labels, DefaultUnzipOptions)
+}
+
+func setLabelsTo(src string, w io.Writer, labels Labels, limits UnzipOptions) error {
+	pkg, err := opc.OpenWith(LongPath(src), limits)
+	if err != nil {
+		return err
+	}
+	return writeLabelInfoPart(pkg, w, labels)
+}
+
+func writeLabelInfoPart(pkg *opc.Package, w io.Writer, labels Labels) error {
+	SetLabelInfoPart(pkg, labels)
+	if _, err := pkg.AddRelationship("", LabelInfoRelationshipType, LabelInfoPart); err != nil {
+		return err
+	}
+	_, err := pkg.WriteTo(w)
+	return err
+}
+
+// SetLabelInfoPart writes labels to the LabelInfo.xml part of a package
+func SetLabelInfoPart(pkg *opc.Package, labels Labels) {
+	pkg.SetPart(LabelInfoPart, []byte(templateLabelInfoXml(labels)), LabelInfoContentType)
+}
@@ -0,0 +1,450 @@
+// Package opc reads and writes Open Packaging Conventions packages
+// (docx, xlsx, pptx) in memory, keeping content types and relationships
+// consistent as parts are added and removed
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ContentTypesPart = "[Content_Types].xml"
+	contentTypesNs   = "http://schemas.openxmlformats.org/package/2006/content-types"
+	relationshipsNs  = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
+
+// Package is an OPC package loaded into memory, part names have no leading slash
+type Package struct {
+	parts        map[string][]byte
+	order        []string
+	contentTypes contentTypes
+	// original [Content_Types].xml, written unchanged unless a content type changed
+	contentTypesData []byte
+}
+
+type contentTypes struct {
+	XMLName   xml.Name   `xml:"Types"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Defaults  []Default  `xml:"Default"`
+	Overrides []Override `xml:"Override"`
+}
+
+type Default struct {
+	Extension   string `xml:"Extension,attr"`
+	ContentType string `xml:"ContentType,attr"`
+}
+
+type Override struct {
+	PartName    string `xml:"PartName,attr"`
+	ContentType string `xml:"ContentType,attr"`
+}
+
+// Relationship from a source part (or the package when the source is "") to a target
+type Relationship struct {
+	Id         string `xml:"Id,attr"`
+	Type       string `xml:"Type,attr"`
+	Target     string `xml:"Target,attr"`
+	TargetMode string `xml:"TargetMode,attr,omitempty"`
+}
+
+type relationships struct {
+	XMLName       xml.Name       `xml:"Relationships"`
+	Xmlns         string         `xml:"xmlns,attr"`
+	Relationships []Relationship `xml:"Relationship"`
+}
+
+// Limits bound what a package may decompress to, so crafted archives can't
+// exhaust the memory or disk of the scanning host, 0 disables a limit
+type Limits struct {
+	MaxBytes int64 // total uncompressed bytes
+	MaxDepth int   // directory levels of an entry name
+}
+
+// DefaultLimits are well above any real Office document
+var DefaultLimits = Limits{MaxBytes: 4 << 30, MaxDepth: 32}
+
+// ErrUnsafeArchive is returned for entries that escape the package, symlink and
+// duplicate entries, and archives exceeding the Limits
+var ErrUnsafeArchive = errors.New("unsafe archive")
+
+// Open reads the package at path within DefaultLimits
+func Open(path string) (*Package, error) {
+	return OpenWith(path, DefaultLimits)
+}
+
+// OpenWith reads the package at path within limits
+func OpenWith(path string, limits Limits) (*Package, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadWith(bytes.NewReader(data), int64(len(data)), limits)
+}
+
+// OpenFS reads the package name from fsys within DefaultLimits
+func OpenFS(fsys fs.FS, name string) (*Package, error) {
+	return OpenFSWith(fsys, name, DefaultLimits)
+}
+
+// OpenFSWith reads the package name from fsys within limits
+func OpenFSWith(fsys fs.FS, name string, limits Limits) (*Package, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadWith(bytes.NewReader(data), int64(len(data)), limits)
+}
+
+// Read loads a package from a zip archive within DefaultLimits
+func Read(r io.ReaderAt, size int64) (*Package, error) {
+	return ReadWith(r, size, DefaultLimits)
+}
+
+// ReadWith loads a package from a zip archive, rejecting the entries an extraction
+// would reject and decompressing no more than limits allow
+func ReadWith(r io.ReaderAt, size int64, limits Limits) (*Package, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	p := &Package{parts: map[string][]byte{}}
+	// entry names seen so far, case-insensitive as on Windows
+	seen := map[string]bool{}
+	var read int64
+	for _, f := range zr.File {
+		name, err := CheckEntry(f, limits)
+		if err != nil {
+			return nil, err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%w: duplicate entry %s", ErrUnsafeArchive, f.Name)
+		}
+		seen[strings.ToLower(name)] = true
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		// the declared sizes may lie, count what is actually decompressed
+		var content io.Reader = rc
+		if limits.MaxBytes > 0 {
+			content = io.LimitReader(rc, limits.MaxBytes-read+1)
+		}
+		data, err := io.ReadAll(content)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		read += int64(len(data))
+		if limits.MaxBytes > 0 && read > limits.MaxBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes decompressed", ErrUnsafeArchive, limits.MaxBytes)
+		}
+		if name == ContentTypesPart {
+			if err := xml.Unmarshal(data, &p.contentTypes); err != nil {
+				return nil, fmt.Errorf("%s: %w", ContentTypesPart, err)
+			}
+			p.contentTypesData = data
+			continue
+		}
+		p.parts[name] = data
+		p.order = append(p.order, name)
+	}
+	if p.contentTypes.XMLName.Local == "" {
+		return nil, fmt.Errorf("missing %s, not an OPC package", ContentTypesPart)
+	}
+	return p, nil
+}
+
+// New returns a package of parts described by contentTypes, e.g. to update the
+// content types and relationships of an extracted package without loading its parts
+func New(contentTypes []byte, parts map[string][]byte) (*Package, error) {
+	p := &Package{parts: map[string][]byte{}, contentTypesData: contentTypes}
+	if err := xml.Unmarshal(contentTypes, &p.contentTypes); err != nil {
+		return nil, fmt.Errorf("%s: %w", ContentTypesPart, err)
+	}
+	for name, data := range parts {
+		p.SetPart(name, data, "")
+	}
+	return p, nil
+}
+
+// CheckEntry returns the normalized name of a zip entry, rejecting symlinks,
+// names EntryName rejects and names nested deeper than limits.MaxDepth
+func CheckEntry(f *zip.File, limits Limits) (string, error) {
+	name, err := EntryName(f.Name)
+	if err != nil {
+		return "", err
+	}
+	if f.Mode()&fs.ModeSymlink != 0 {
+		return "", fmt.Errorf("%w: symlink entry %s", ErrUnsafeArchive, f.Name)
+	}
+	if limits.MaxDepth > 0 && strings.Count(name, "/") > limits.MaxDepth {
+		return "", fmt.Errorf("%w: entry %s is nested deeper than %d directories", ErrUnsafeArchive, f.Name, limits.MaxDepth)
+	}
+	return name, nil
+}
+
+// EntryName normalizes a zip entry name to a relative slash separated path, zip tools
+// on Windows may write backslashes, absolute names, drive letters and UNC paths are
+// rejected on every OS so an archive extracts the same everywhere, only whole ".."
+// segments are rejected so names such as a..b.png are kept
+func EntryName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || (len(slashed) >= 2 && slashed[1] == ':') {
+		return "", fmt.Errorf("%w: absolute entry %s", ErrUnsafeArchive, name)
+	}
+	clean := path.Clean(slashed)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: illegal file path: %s", ErrUnsafeArchive, name)
+	}
+	// a colon names an alternate data stream or device on Windows
+	if filepath.VolumeName(filepath.FromSlash(clean)) != "" || (os.PathSeparator == '\\' && strings.Contains(clean, ":")) {
+		return "", fmt.Errorf("%w: illegal file path: %s", ErrUnsafeArchive, name)
+	}
+	return clean, nil
+}
+
+// Parts returns the part names in archive order, [Content_Types].xml is not a part
+func (p *Package) Parts() []string {
+	return append([]string{}, p.order...)
+}
+
+// Part returns the content of a part
+func (p *Package) Part(name string) ([]byte, bool) {
+	data, ok := p.parts[strings.TrimPrefix(name, "/")]
+	return data, ok
+}
+
+// SetPart adds or replaces a part, contentType registers an override
+// for the part unless empty or already covered by a default
+func (p *Package) SetPart(name string, data []byte, contentType string) {
+	name = strings.TrimPrefix(name, "/")
+	if _, ok := p.parts[name]; !ok {
+		p.order = append(p.order, name)
+	}
+	p.parts[name] = data
+	if contentType != "" && p.ContentType(name) != contentType {
+		p.removeOverride(name)
+		p.contentTypes.Overrides = append(p.contentTypes.Overrides, Override{PartName: "/" + name, ContentType: contentType})
+		p.contentTypesData = nil
+	}
+}
+
+// DeletePart removes a part with its content type override, its own
+// relationships and every relationship targeting it
+func (p *Package) DeletePart(name string) error {
+	name = strings.TrimPrefix(name, "/")
+	if _, ok := p.parts[name]; !ok {
+		return nil
+	}
+	delete(p.parts, name)
+	for i, n := range p.order {
+		if n == name {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	p.removeOverride(name)
+	if relsPart := relationshipsPart(name); relsPart != "" {
+		delete(p.parts, relsPart)
+		for i, n := range p.order {
+			if n == relsPart {
+				p.order = append(p.order[:i], p.order[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, part := range p.Parts() {
+		if !strings.HasSuffix(part, ".rels") {
+			continue
+		}
+		source := sourcePart(part)
+		rels, err := p.Relationships(source)
+		if err != nil {
+			return err
+		}
+		kept := rels[:0]
+		for _, rel := range rels {
+			if rel.TargetMode == "External" || ResolveTarget(source, rel.Target) != name {
+				kept = append(kept, rel)
+			}
+		}
+		if len(kept) != len(rels) {
+			if err := p.setRelationships(source, kept); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ContentType returns the content type of a part from its override or extension default
+func (p *Package) ContentType(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	for _, o := range p.contentTypes.Overrides {
+		if strings.EqualFold(strings.TrimPrefix(o.PartName, "/"), name) {
+			return o.ContentType
+		}
+	}
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	for _, d := range p.contentTypes.Defaults {
+		if strings.EqualFold(d.Extension, ext) {
+			return d.ContentType
+		}
+	}
+	return ""
+}
+
+func (p *Package) removeOverride(name string) {
+	overrides := p.contentTypes.Overrides[:0]
+	for _, o := range p.contentTypes.Overrides {
+		if !strings.EqualFold(strings.TrimPrefix(o.PartName, "/"), name) {
+			overrides = append(overrides, o)
+		} else {
+			p.contentTypesData = nil
+		}
+	}
+	p.contentTypes.Overrides = overrides
+}
+
+// relationshipsPart returns the .rels part of a source part, "" for the package
+func relationshipsPart(source string) string {
+	if source == "" {
+		return "_rels/.rels"
+	}
+	dir, file := path.Split(source)
+	return dir + "_rels/" + file + ".rels"
+}
+
+// sourcePart is the inverse of relationshipsPart
+func sourcePart(relsPart string) string {
+	dir, file := path.Split(relsPart)
+	dir = strings.TrimSuffix(strings.TrimSuffix(dir, "/"), "_rels")
+	return dir + strings.TrimSuffix(file, ".rels")
+}
+
+// ResolveTarget returns the part name a relationship target of source points to
+func ResolveTarget(source, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(path.Clean(target), "/")
+	}
+	return strings.TrimPrefix(path.Join(path.Dir("/"+source), target), "/")
+}
+
+// Relationships returns the relationships of source, "" for package relationships
+func (p *Package) Relationships(source string) ([]Relationship, error) {
+	data, ok := p.parts[relationshipsPart(source)]
+	if !ok {
+		return nil, nil
+	}
+	var rels relationships
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return nil, fmt.Errorf("%s: %w", relationshipsPart(source), err)
+	}
+	return rels.Relationships, nil
+}
+
+func (p *Package) setRelationships(source string, rels []Relationship) error {
+	data, err := xml.Marshal(relationships{Xmlns: relationshipsNs, Relationships: rels})
+	if err != nil {
+		return err
+	}
+	p.SetPart(relationshipsPart(source), append([]byte(xml.Header), data...), "")
+	return nil
+}
+
+// AddRelationship adds a relationship from source unless one of the same type
+// already targets the part, returns the relationship id
+func (p *Package) AddRelationship(source, relType, target string) (string, error) {
+	rels, err := p.Relationships(source)
+	if err != nil {
+		return "", err
+	}
+	ids := map[string]bool{}
+	for _, rel := range rels {
+		if rel.Type == relType && ResolveTarget(source, rel.Target) == ResolveTarget(source, target) {
+			return rel.Id, nil
+		}
+		ids[rel.Id] = true
+	}
+	id := ""
+	for i := len(rels) + 1; id == "" || ids[id]; i++ {
+		id = fmt.Sprintf("rId%d", i)
+	}
+	rels = append(rels, Relationship{Id: id, Type: relType, Target: target})
+	return id, p.setRelationships(source, rels)
+}
+
+// ContentTypes returns [Content_Types].xml, unchanged unless a content type changed
+func (p *Package) ContentTypes() ([]byte, error) {
+	if p.contentTypesData != nil {
+		return p.contentTypesData, nil
+	}
+	p.contentTypes.Xmlns = contentTypesNs
+	data, err := xml.Marshal(p.contentTypes)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// WriteTo writes the package as a zip archive, [Content_Types].xml first
+func (p *Package) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	zw := zip.NewWriter(cw)
+	contentTypes, err := p.ContentTypes()
+	if err != nil {
+		return cw.n, err
+	}
+	if err := writePart(zw, ContentTypesPart, contentTypes); err != nil {
+		return cw.n, err
+	}
+	for _, name := range p.order {
+		if err := writePart(zw, name, p.parts[name]); err != nil {
+			return cw.n, err
+		}
+	}
+	err = zw.Close()
+	return cw.n, err
+}
+
+// Save writes the package to path
+func (p *Package) Save(path string) error {
+	buf := bytes.Buffer{}
+	if _, err := p.WriteTo(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writePart(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,135 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+const testContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="xml" ContentType="application/xml"/></Types>`
+
+// testEntry is a zip entry written by buildZip
+type testEntry struct {
+	name string
+	data string
+	mode fs.FileMode
+}
+
+func buildZip(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEntryName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // empty when the name is rejected
+	}{
+		{"word/document.xml", "word/document.xml"},
+		{"word/media/a..b.png", "word/media/a..b.png"},
+		{"word/./document.xml", "word/document.xml"},
+		{`word\document.xml`, "word/document.xml"},
+		{"word/../docProps/app.xml", "docProps/app.xml"},
+		{"../evil", ""},
+		{`..\evil`, ""},
+		{"word/../../evil", ""},
+		{"..", ""},
+		{".", ""},
+		{"/etc/passwd", ""},
+		{`\evil`, ""},
+		{`C:\evil`, ""},
+		{"C:evil", ""},
+		{`\\server\share\evil`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EntryName(tt.name)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnsafeArchive) {
+					t.Fatalf("EntryName(%q) = %q, %v, want ErrUnsafeArchive", tt.name, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("EntryName(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWith(t *testing.T) {
+	limits := Limits{MaxBytes: 1024, MaxDepth: 4}
+	contentTypes := testEntry{name: ContentTypesPart, data: testContentTypes}
+	tests := []struct {
+		name    string
+		entries []testEntry
+		wantErr string // empty when the package is read
+		part    string // part expected in the package
+	}{
+		{"valid", []testEntry{contentTypes, {name: "word/document.xml", data: "<doc/>"}}, "", "word/document.xml"},
+		{"dots in name", []testEntry{contentTypes, {name: "word/media/a..b.png", data: "png"}}, "", "word/media/a..b.png"},
+		{"backslashes", []testEntry{contentTypes, {name: `word\document.xml`, data: "<doc/>"}}, "", "word/document.xml"},
+		{"directory entry", []testEntry{contentTypes, {name: "word/"}, {name: "word/document.xml", data: "<doc/>"}}, "", "word/document.xml"},
+		{"parent directory", []testEntry{contentTypes, {name: "../evil.xml", data: "x"}}, "illegal file path", ""},
+		{"absolute", []testEntry{contentTypes, {name: "/evil.xml", data: "x"}}, "absolute entry", ""},
+		{"drive letter", []testEntry{contentTypes, {name: `C:\evil.xml`, data: "x"}}, "absolute entry", ""},
+		{"duplicate", []testEntry{contentTypes, {name: "word/document.xml"}, {name: "word/document.xml"}}, "duplicate entry", ""},
+		{"duplicate case", []testEntry{contentTypes, {name: "word/document.xml"}, {name: "Word/Document.xml"}}, "duplicate entry", ""},
+		{"duplicate backslash", []testEntry{contentTypes, {name: "word/document.xml"}, {name: `word\document.xml`}}, "duplicate entry", ""},
+		{"symlink", []testEntry{contentTypes, {name: "word/link.xml", data: "/etc/passwd", mode: fs.ModeSymlink | 0777}}, "symlink entry", ""},
+		{"too deep", []testEntry{contentTypes, {name: "a/b/c/d/e/f.xml", data: "x"}}, "nested deeper", ""},
+		{"oversized entry", []testEntry{contentTypes, {name: "word/big.bin", data: strings.Repeat("a", 2048)}}, "bytes decompressed", ""},
+		{"oversized total", []testEntry{contentTypes, {name: "a.bin", data: strings.Repeat("a", 600)}, {name: "b.bin", data: strings.Repeat("b", 600)}}, "bytes decompressed", ""},
+		{"no content types", []testEntry{{name: "word/document.xml", data: "<doc/>"}}, "not an OPC package", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildZip(t, tt.entries)
+			p, err := ReadWith(bytes.NewReader(data), int64(len(data)), limits)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadWith() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadWith() error = %v", err)
+			}
+			if _, ok := p.Part(tt.part); !ok {
+				t.Fatalf("part %s missing, parts %v", tt.part, p.Parts())
+			}
+		})
+	}
+}
+
+func TestReadWithoutLimits(t *testing.T) {
+	data := buildZip(t, []testEntry{
+		{name: ContentTypesPart, data: testContentTypes},
+		{name: "word/big.bin", data: strings.Repeat("a", 4096)},
+	})
+	if _, err := ReadWith(bytes.NewReader(data), int64(len(data)), Limits{}); err != nil {
+		t.Fatalf("ReadWith() error = %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package sensitivity_labels
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Ownership is who a share or directory belongs to, joined into results and
+// aggregates so findings can be routed to the right people
+type Ownership struct {
+	Prefix     string `json:"prefix"`
+	Department string `json:"department,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	Steward    string `json:"steward,omitempty"`
+}
+
+// OwnershipMap maps path prefixes to their ownership
+type OwnershipMap struct {
+	Entries []Ownership
+}
+
+// ReadOwnershipMap reads a CSV file with a prefix,department,owner,steward header,
+// columns may be in any order and only prefix is required
+func ReadOwnershipMap(mapPath string) (*OwnershipMap, error) {
+	f, err := os.Open(LongPath(mapPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", mapPath, err)
+	}
+	columns := map[string]int{}
+	// Excel writes a byte order mark before the first column
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["prefix"]; !ok {
+		return nil, fmt.Errorf("%s: missing prefix column", mapPath)
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	m := &OwnershipMap{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mapPath, err)
+		}
+		entry := Ownership{
+			Prefix:     field(record, "prefix"),
+			Department: field(record, "department"),
+			Owner:      field(record, "owner"),
+			Steward:    field(record, "steward"),
+		}
+		if entry.Prefix != "" {
+			m.Entries = append(m.Entries, entry)
+		}
+	}
+	return m, nil
+}
+
+// Lookup returns the ownership of the longest prefix containing filePath,
+// prefixes match whole path components and ignore case
+func (m *OwnershipMap) Lookup(filePath string) *Ownership {
+	if m == nil {
+		return nil
+	}
+	key := exclusionKey(filePath)
+	var match *Ownership
+	matchLen := -1
+	for i, entry := range m.Entries {
+		prefix := strings.TrimSuffix(exclusionKey(entry.Prefix), "/")
+		if key != prefix && !strings.HasPrefix(key, prefix+"/") {
+			continue
+		}
+		if len(prefix) > matchLen {
+			match, matchLen = &m.Entries[i], len(prefix)
+		}
+	}
+	return match
+}
@@ -0,0 +1,121 @@
+package sensitivity_labels
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/WTFender/sensitivity_labels/opc"
+)
+
+// the label part written by SetLabels
+const LabelInfoPart = "docMetadata/LabelInfo.xml"
+
+// PartHash records a package part that differs between two versions of a document,
+// an empty hash means the part did not exist
+type PartHash struct {
+	Part   string `json:"part"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// HashParts returns the sha256 of the uncompressed content of every part in a document
+func HashParts(filePath string) (map[string]string, error) {
+	r, err := zip.OpenReader(LongPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	hashes := map[string]string{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		hashes[f.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes, nil
+}
+
+// ComparePartHashes lists the parts added, removed or modified, sorted by part name
+func ComparePartHashes(before, after map[string]string) []PartHash {
+	var changes []PartHash
+	for part, hash := range before {
+		if after[part] != hash {
+			changes = append(changes, PartHash{Part: part, Before: hash, After: after[part]})
+		}
+	}
+	for part, hash := range after {
+		if _, ok := before[part]; !ok {
+			changes = append(changes, PartHash{Part: part, After: hash})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Part < changes[j].Part })
+	return changes
+}
+
+// isBookkeepingPart reports OPC parts that may change along with the label part
+func isBookkeepingPart(part string) bool {
+	return part == "[Content_Types].xml" || path.Ext(part) == ".rels"
+}
+
+// VerifyParts hashes the rewritten document and checks that only the allowed parts
+// and OPC bookkeeping changed, the changes are returned as evidence either way
+func VerifyParts(filePath string, before map[string]string, allowed []string) ([]PartHash, error) {
+	after, err := HashParts(filePath)
+	if err != nil {
+		return nil, err
+	}
+	changes := ComparePartHashes(before, after)
+	var unexpected []string
+	for _, change := range changes {
+		if isBookkeepingPart(change.Part) || containsPart(allowed, change.Part) {
+			continue
+		}
+		unexpected = append(unexpected, change.Part)
+	}
+	if len(unexpected) > 0 {
+		return changes, fmt.Errorf("unexpected parts changed: %s", strings.Join(unexpected, ", "))
+	}
+	return changes, nil
+}
+
+// VerifyLabels reopens a rewritten document and checks that it is a readable package
+// carrying exactly the labels written
+func VerifyLabels(filePath string, want []Label) error {
+	pkg, err := opc.Open(LongPath(filePath))
+	if err != nil {
+		return fmt.Errorf("rewritten document does not open: %w", err)
+	}
+	labels, _, err := readLabelInfoPart(pkg)
+	if err != nil {
+		return fmt.Errorf("rewritten %s does not parse: %w", LabelInfoPart, err)
+	}
+	if !SameLabels(labels.Labels, want) {
+		return fmt.Errorf("labels read back differ from the labels written")
+	}
+	return nil
+}
+
+func containsPart(parts []string, part string) bool {
+	for _, p := range parts {
+		if p == part {
+			return true
+		}
+	}
+	return false
+}
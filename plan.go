@@ -0,0 +1,151 @@
+package sensitivity_labels
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// plan actions
+const (
+	PlanKeep    = "keep"
+	PlanRelabel = "relabel"
+	PlanRemove  = "remove"
+)
+
+// PlanEntry is the reviewed outcome for a single file, Hash pins the contents
+// the plan was made for so apply never rewrites a file that changed since
+type PlanEntry struct {
+	Path     string  `json:"path"`
+	Hash     string  `json:"sha256"`
+	Action   string  `json:"action"`
+	Reason   string  `json:"reason,omitempty"`
+	Current  []Label `json:"current"`
+	Proposed []Label `json:"proposed"`
+}
+
+// Plan lists every file of a scan with its current and proposed labels,
+// Signature is added by an approver and covers everything but itself
+type Plan struct {
+	Created      string      `json:"created"`
+	RunId        string      `json:"runId"`
+	Root         string      `json:"root"` // path the plan was made for
+	Entries      []PlanEntry `json:"entries"`
+	Approved     string      `json:"approved,omitempty"`
+	SignatureAlg string      `json:"signatureAlg,omitempty"`
+	Signature    string      `json:"signature,omitempty"`
+	Certificate  string      `json:"certificate,omitempty"`
+}
+
+// ErrUnsignedPlan is returned when verifying a plan nobody approved
+var ErrUnsignedPlan = errors.New("plan is not signed")
+
+func NewPlan(runId, root string) *Plan {
+	return &Plan{
+		Created: time.Now().UTC().Format(time.RFC3339),
+		RunId:   runId,
+		Root:    root,
+		Entries: []PlanEntry{},
+	}
+}
+
+func (p *Plan) Add(entry PlanEntry) {
+	p.Entries = append(p.Entries, entry)
+}
+
+// Changes returns the entries apply would write
+func (p *Plan) Changes() []PlanEntry {
+	var changes []PlanEntry
+	for _, entry := range p.Entries {
+		if entry.Action != PlanKeep {
+			changes = append(changes, entry)
+		}
+	}
+	return changes
+}
+
+func (p *Plan) payload() ([]byte, error) {
+	return json.Marshal(struct {
+		Created  string      `json:"created"`
+		RunId    string      `json:"runId"`
+		Root     string      `json:"root"`
+		Entries  []PlanEntry `json:"entries"`
+		Approved string      `json:"approved"`
+	}{p.Created, p.RunId, p.Root, p.Entries, p.Approved})
+}
+
+// SignHMAC approves the plan with HMAC-SHA256 using the provided key
+func (p *Plan) SignHMAC(key []byte) error {
+	p.Approved = time.Now().UTC().Format(time.RFC3339)
+	payload, err := p.payload()
+	if err != nil {
+		return err
+	}
+	p.SignatureAlg, p.Signature = signHMAC(payload, key)
+	p.Certificate = ""
+	return nil
+}
+
+// SignX509 approves the plan with a PEM encoded private key (RSA, ECDSA or Ed25519),
+// certPEM is optional and embedded so verifiers know which key was used
+func (p *Plan) SignX509(keyPEM, certPEM []byte) error {
+	p.Approved = time.Now().UTC().Format(time.RFC3339)
+	payload, err := p.payload()
+	if err != nil {
+		return err
+	}
+	alg, sig, err := signX509(payload, keyPEM)
+	if err != nil {
+		return err
+	}
+	p.SignatureAlg, p.Signature = alg, sig
+	p.Certificate = string(certPEM)
+	return nil
+}
+
+// Verify checks the approval against an HMAC key or the PEM certificate (or public key)
+// of the approver, the embedded certificate is never trusted, ErrSignature means
+// the plan was modified after approval or signed by someone else
+func (p *Plan) Verify(hmacKey, pubPEM []byte) error {
+	if p.Signature == "" {
+		return ErrUnsignedPlan
+	}
+	payload, err := p.payload()
+	if err != nil {
+		return err
+	}
+	if p.SignatureAlg == SignatureHMAC {
+		if hmacKey == nil || !verifyHMAC(payload, hmacKey, p.SignatureAlg, p.Signature) {
+			return ErrSignature
+		}
+		return nil
+	}
+	if pubPEM == nil {
+		return ErrSignature
+	}
+	return verifyX509(payload, pubPEM, p.SignatureAlg, p.Signature)
+}
+
+func (p *Plan) Write(path string) error {
+	jsonBytes, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := checkWrite(path); err != nil {
+		return err
+	}
+	return os.WriteFile(LongPath(path), jsonBytes, 0644)
+}
+
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
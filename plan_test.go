@@ -0,0 +1,136 @@
+package sensitivity_labels
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testPlan() *Plan {
+	p := NewPlan("run-1", "dir")
+	label := Label{Id: "{11111111-1111-1111-1111-111111111111}", SiteId: "{33333333-3333-3333-3333-333333333333}"}
+	p.Add(PlanEntry{Path: "dir/report.docx", Hash: "aa", Action: PlanRelabel, Current: []Label{}, Proposed: []Label{label}})
+	p.Add(PlanEntry{Path: "dir/budget.xlsx", Hash: "bb", Action: PlanKeep, Current: []Label{label}, Proposed: []Label{label}})
+	return p
+}
+
+// planTampers alter an approved plan in ways its signature must catch
+var planTampers = []struct {
+	name   string
+	tamper func(p *Plan)
+}{
+	{"action", func(p *Plan) { p.Entries[1].Action = PlanRemove }},
+	{"proposed label", func(p *Plan) { p.Entries[0].Proposed[0].Id = "{22222222-2222-2222-2222-222222222222}" }},
+	{"hash", func(p *Plan) { p.Entries[0].Hash = "cc" }},
+	{"path", func(p *Plan) { p.Entries[0].Path = "dir/other.docx" }},
+	{"root", func(p *Plan) { p.Root = "other" }},
+	{"approved", func(p *Plan) { p.Approved = "2020-01-01T00:00:00Z" }},
+	{"entry added", func(p *Plan) { p.Add(PlanEntry{Path: "dir/added.docx", Action: PlanRemove}) }},
+	{"entry removed", func(p *Plan) { p.Entries = p.Entries[:1] }},
+}
+
+// writePlan writes a plan to a json file and returns its path
+func writePlan(t *testing.T, p *Plan) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := p.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readPlan(t *testing.T, path string) *Plan {
+	t.Helper()
+	p, err := ReadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPlanHMAC(t *testing.T) {
+	key := []byte("approver key")
+	p := testPlan()
+	if err := p.SignHMAC(key); err != nil {
+		t.Fatal(err)
+	}
+	path := writePlan(t, p)
+	if err := readPlan(t, path).Verify(key, nil); err != nil {
+		t.Fatalf("Verify() = %v for the approver key", err)
+	}
+	if err := readPlan(t, path).Verify([]byte("other key"), nil); !errors.Is(err, ErrSignature) {
+		t.Fatalf("Verify() = %v for another key, want ErrSignature", err)
+	}
+	if err := readPlan(t, path).Verify(nil, nil); !errors.Is(err, ErrSignature) {
+		t.Fatalf("Verify() = %v without a key, want ErrSignature", err)
+	}
+	_, certPEM := testKeyPair(t, "ecdsa")
+	if err := readPlan(t, path).Verify(nil, certPEM); !errors.Is(err, ErrSignature) {
+		t.Fatalf("Verify() = %v with a certificate for an HMAC approval, want ErrSignature", err)
+	}
+	for _, tt := range planTampers {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := readPlan(t, path)
+			tt.tamper(tampered)
+			if err := tampered.Verify(key, nil); !errors.Is(err, ErrSignature) {
+				t.Fatalf("Verify() = %v for a tampered plan, want ErrSignature", err)
+			}
+		})
+	}
+}
+
+func TestPlanX509(t *testing.T) {
+	for _, kind := range []string{"rsa", "ecdsa", "ed25519"} {
+		t.Run(kind, func(t *testing.T) {
+			keyPEM, certPEM := testKeyPair(t, kind)
+			_, otherCertPEM := testKeyPair(t, kind)
+			p := testPlan()
+			if err := p.SignX509(keyPEM, certPEM); err != nil {
+				t.Fatal(err)
+			}
+			path := writePlan(t, p)
+			if err := readPlan(t, path).Verify(nil, certPEM); err != nil {
+				t.Fatalf("Verify() = %v for the approver certificate", err)
+			}
+			if err := readPlan(t, path).Verify(nil, otherCertPEM); !errors.Is(err, ErrSignature) {
+				t.Fatalf("Verify() = %v for another certificate, want ErrSignature", err)
+			}
+			if err := readPlan(t, path).Verify([]byte("key"), nil); !errors.Is(err, ErrSignature) {
+				t.Fatalf("Verify() = %v with an HMAC key for an X.509 approval, want ErrSignature", err)
+			}
+			for _, tt := range planTampers {
+				t.Run(tt.name, func(t *testing.T) {
+					tampered := readPlan(t, path)
+					tt.tamper(tampered)
+					if err := tampered.Verify(nil, certPEM); !errors.Is(err, ErrSignature) {
+						t.Fatalf("Verify() = %v for a tampered plan, want ErrSignature", err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestPlanX509IgnoresEmbeddedCertificate(t *testing.T) {
+	keyPEM, certPEM := testKeyPair(t, "ecdsa")
+	forgedKeyPEM, forgedCertPEM := testKeyPair(t, "ecdsa")
+	p := testPlan()
+	if err := p.SignX509(keyPEM, certPEM); err != nil {
+		t.Fatal(err)
+	}
+	// approved again by another key embedding its own certificate
+	p.Entries[1].Action = PlanRemove
+	if err := p.SignX509(forgedKeyPEM, forgedCertPEM); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Verify(nil, certPEM); !errors.Is(err, ErrSignature) {
+		t.Fatalf("Verify() = %v for a plan approved by another key, want ErrSignature", err)
+	}
+}
+
+func TestPlanVerifyUnsigned(t *testing.T) {
+	_, certPEM := testKeyPair(t, "ecdsa")
+	if err := testPlan().Verify([]byte("key"), certPEM); !errors.Is(err, ErrUnsignedPlan) {
+		t.Fatalf("Verify() = %v, want ErrUnsignedPlan", err)
+	}
+}
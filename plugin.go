@@ -0,0 +1,158 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// external plugins are executables speaking JSON over stdin/stdout,
+// every call runs the plugin once with a single PluginRequest on stdin
+// and expects a single PluginResponse on stdout
+
+// PluginRequest methods: describe, read, write and sink
+type PluginRequest struct {
+	Method   string  `json:"method"`
+	FilePath string  `json:"filePath,omitempty"`
+	Labels   []Label `json:"labels,omitempty"`
+}
+
+type PluginResponse struct {
+	Name       string   `json:"name,omitempty"`       // describe
+	Type       string   `json:"type,omitempty"`       // describe: handler or sink
+	Extensions []string `json:"extensions,omitempty"` // describe: files the handler reads
+	Writable   bool     `json:"writable,omitempty"`   // describe: the handler supports write
+	Labels     []Label  `json:"labels,omitempty"`     // read
+	LabelInfo  bool     `json:"labelInfo,omitempty"`  // read
+	Error      string   `json:"error,omitempty"`
+}
+
+const (
+	PluginTypeHandler = "handler"
+	PluginTypeSink    = "sink"
+)
+
+// CallPlugin runs the plugin executable with the request
+func CallPlugin(path string, req PluginRequest) (PluginResponse, error) {
+	var resp PluginResponse
+	in, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return resp, fmt.Errorf("plugin %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return resp, fmt.Errorf("plugin %s: invalid response: %w", path, err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("plugin %s: %s", path, resp.Error)
+	}
+	return resp, nil
+}
+
+// DescribePlugin asks a plugin for its name, type and the files it handles
+func DescribePlugin(path string) (PluginResponse, error) {
+	resp, err := CallPlugin(path, PluginRequest{Method: "describe"})
+	if err != nil {
+		return resp, err
+	}
+	if resp.Type != PluginTypeHandler && resp.Type != PluginTypeSink {
+		return resp, fmt.Errorf("plugin %s: unknown type %q, expected handler or sink", path, resp.Type)
+	}
+	if resp.Name == "" {
+		resp.Name = filepath.Base(path)
+	}
+	return resp, nil
+}
+
+// PluginReader is a read only format handler implemented by an external plugin
+type PluginReader struct {
+	Path       string
+	Extensions []string
+}
+
+// PluginHandler is a PluginReader that also writes labels
+type PluginHandler struct {
+	PluginReader
+}
+
+// NewPluginHandler returns a handler for a described plugin,
+// plugins that aren't writable don't implement LabelWriter
+func NewPluginHandler(path string, desc PluginResponse) LabelReader {
+	r := PluginReader{Path: path, Extensions: desc.Extensions}
+	if desc.Writable {
+		return &PluginHandler{r}
+	}
+	return &r
+}
+
+func (h *PluginReader) Sniff(filePath string, header []byte) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, e := range h.Extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *PluginReader) ReadLabels(filePath string) (Labels, bool, error) {
+	resp, err := CallPlugin(h.Path, PluginRequest{Method: "read", FilePath: filePath})
+	if err != nil {
+		return Labels{}, false, err
+	}
+	return Labels{Labels: resp.Labels}, resp.LabelInfo, nil
+}
+
+func (h *PluginHandler) WriteLabels(filePath string, labels Labels) error {
+	_, err := CallPlugin(h.Path, PluginRequest{Method: "write", FilePath: filePath, Labels: labels.Labels})
+	return err
+}
+
+// PluginSink streams results to a long running plugin as JSON lines on stdin,
+// the first line is a PluginRequest with the sink method
+type PluginSink struct {
+	Name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+}
+
+func StartPluginSink(path, name string) (*PluginSink, error) {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	s := &PluginSink{Name: name, cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin)}
+	if err := s.Send(PluginRequest{Method: "sink"}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PluginSink) Send(v any) error {
+	return s.enc.Encode(v)
+}
+
+// Close ends the stream and waits for the plugin to exit
+func (s *PluginSink) Close() error {
+	s.stdin.Close()
+	return s.cmd.Wait()
+}
@@ -0,0 +1,121 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeId strips braces and whitespace and lowercases a GUID so
+// "{ABC-123}" and "abc-123" compare equal
+func NormalizeId(id string) string {
+	id = strings.TrimSpace(id)
+	id = strings.TrimPrefix(id, "{")
+	id = strings.TrimSuffix(id, "}")
+	return strings.ToLower(id)
+}
+
+func containsId(ids []string, id string) bool {
+	id = NormalizeId(id)
+	for _, i := range ids {
+		if NormalizeId(i) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// FindForbiddenLabels returns the labels whose ID is in denyLabels
+// or whose siteId is in denyTenants
+func FindForbiddenLabels(labels []Label, denyLabels, denyTenants []string) []Label {
+	var forbidden []Label
+	for _, label := range labels {
+		if containsId(denyLabels, label.Id) || containsId(denyTenants, label.SiteId) {
+			forbidden = append(forbidden, label)
+		}
+	}
+	return forbidden
+}
+
+// FindTenantMismatches returns the labels whose siteId is not the expected tenant
+func FindTenantMismatches(labels []Label, expectedTenant string) []Label {
+	var mismatched []Label
+	if expectedTenant == "" {
+		return mismatched
+	}
+	for _, label := range labels {
+		if NormalizeId(label.SiteId) != NormalizeId(expectedTenant) {
+			mismatched = append(mismatched, label)
+		}
+	}
+	return mismatched
+}
+
+// RetagLabels rewrites only the siteId of each label to the provided tenant,
+// leaving every other attribute untouched
+func RetagLabels(labels []Label, tenantId string) Labels {
+	retagged := Labels{Labels: []Label{}}
+	for _, label := range labels {
+		label.SiteId = NormalizeId(tenantId)
+		retagged.Labels = append(retagged.Labels, label)
+	}
+	return retagged
+}
+
+// FindDuplicateLabels describes label IDs listed more than once,
+// noting entries that disagree on enabled or removed
+func FindDuplicateLabels(labels []Label) []string {
+	var problems []string
+	for _, group := range groupLabels(labels) {
+		if len(group) < 2 {
+			continue
+		}
+		problem := fmt.Sprintf("label %s appears %d times", NormalizeId(group[0].Id), len(group))
+		for _, label := range group[1:] {
+			if isTrue(label.Enabled) != isTrue(group[0].Enabled) || isTrue(label.Removed) != isTrue(group[0].Removed) {
+				problem += " with conflicting enabled/removed values"
+				break
+			}
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// DedupeLabels keeps a single entry per label ID in order of first appearance,
+// the first active (enabled, not removed) entry wins, otherwise the first entry
+func DedupeLabels(labels []Label) Labels {
+	deduped := Labels{Labels: []Label{}}
+	for _, group := range groupLabels(labels) {
+		keep := group[0]
+		for _, label := range group {
+			if isTrue(label.Enabled) && !isTrue(label.Removed) {
+				keep = label
+				break
+			}
+		}
+		deduped.Labels = append(deduped.Labels, keep)
+	}
+	return deduped
+}
+
+// groupLabels groups labels by ID in order of first appearance
+func groupLabels(labels []Label) [][]Label {
+	var groups [][]Label
+	index := map[string]int{}
+	for _, label := range labels {
+		id := NormalizeId(label.Id)
+		i, ok := index[id]
+		if !ok {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], label)
+	}
+	return groups
+}
+
+// LabelInfo.xml uses 1/0, older clients wrote true/false
+func isTrue(value string) bool {
+	return value == "1" || strings.EqualFold(value, "true")
+}
@@ -0,0 +1,37 @@
+package sensitivity_labels
+
+import (
+	"os"
+	"time"
+)
+
+// FileAttributes are the properties of a file that relabeling should not change
+type FileAttributes struct {
+	ModTime time.Time
+	// platform specific, see preserve_windows.go and preserve_other.go
+	platform platformAttributes
+}
+
+// CaptureAttributes records the timestamps, ownership and attributes of a file
+func CaptureAttributes(filePath string) (FileAttributes, error) {
+	info, err := os.Stat(LongPath(filePath))
+	if err != nil {
+		return FileAttributes{}, err
+	}
+	return FileAttributes{
+		ModTime:  info.ModTime(),
+		platform: capturePlatformAttributes(info),
+	}, nil
+}
+
+// RestoreAttributes reapplies attributes captured before the file was rewritten
+func RestoreAttributes(filePath string, attrs FileAttributes) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	err := os.Chtimes(LongPath(filePath), time.Now(), attrs.ModTime)
+	if err != nil {
+		return err
+	}
+	return restorePlatformAttributes(LongPath(filePath), attrs)
+}
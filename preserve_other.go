@@ -0,0 +1,37 @@
+//go:build !windows
+
+package sensitivity_labels
+
+import (
+	"os"
+	"syscall"
+)
+
+type platformAttributes struct {
+	uid, gid int
+	ok       bool
+}
+
+func capturePlatformAttributes(info os.FileInfo) platformAttributes {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return platformAttributes{}
+	}
+	return platformAttributes{uid: int(stat.Uid), gid: int(stat.Gid), ok: true}
+}
+
+// restores ownership, only attempted when it changed since chown usually requires root
+func restorePlatformAttributes(filePath string, attrs FileAttributes) error {
+	if !attrs.platform.ok {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	current := capturePlatformAttributes(info)
+	if current.uid == attrs.platform.uid && current.gid == attrs.platform.gid {
+		return nil
+	}
+	return os.Chown(filePath, attrs.platform.uid, attrs.platform.gid)
+}
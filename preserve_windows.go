@@ -0,0 +1,57 @@
+package sensitivity_labels
+
+import (
+	"os"
+	"syscall"
+)
+
+type platformAttributes struct {
+	creationTime   syscall.Filetime
+	lastAccessTime syscall.Filetime
+	fileAttributes uint32
+	ok             bool
+}
+
+func capturePlatformAttributes(info os.FileInfo) platformAttributes {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return platformAttributes{}
+	}
+	return platformAttributes{
+		creationTime:   data.CreationTime,
+		lastAccessTime: data.LastAccessTime,
+		fileAttributes: data.FileAttributes,
+		ok:             true,
+	}
+}
+
+// restores the created time and file attributes (hidden, archive, read-only...),
+// ACLs are kept since the file is rewritten in place
+func restorePlatformAttributes(filePath string, attrs FileAttributes) error {
+	if !attrs.platform.ok {
+		return nil
+	}
+	pathPtr, err := syscall.UTF16PtrFromString(filePath)
+	if err != nil {
+		return err
+	}
+	h, err := syscall.CreateFile(
+		pathPtr,
+		syscall.FILE_WRITE_ATTRIBUTES,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS,
+		0,
+	)
+	if err != nil {
+		return err
+	}
+	modTime := syscall.NsecToFiletime(attrs.ModTime.UnixNano())
+	err = syscall.SetFileTime(h, &attrs.platform.creationTime, &attrs.platform.lastAccessTime, &modTime)
+	syscall.CloseHandle(h)
+	if err != nil {
+		return err
+	}
+	return syscall.SetFileAttributes(pathPtr, attrs.platform.fileAttributes)
+}
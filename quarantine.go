@@ -0,0 +1,79 @@
+package sensitivity_labels
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// QuarantineFile moves (or copies) filePath into quarantineDir, keeping its path relative
+// to root so files can be restored to their original location, existing files are never replaced
+func QuarantineFile(filePath, root, quarantineDir string, move bool) (string, error) {
+	relPath, err := filepath.Rel(root, filePath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(os.PathSeparator)) {
+		relPath = strings.TrimPrefix(filepath.Clean(filePath), filepath.VolumeName(filePath))
+		relPath = strings.TrimLeft(relPath, `/\`)
+	}
+	quarantinePath := filepath.Join(quarantineDir, relPath)
+	if err := checkWrite(quarantinePath); err != nil {
+		return "", err
+	}
+	if move {
+		if err := checkWrite(filePath); err != nil {
+			return "", err
+		}
+	}
+	if _, err := os.Lstat(LongPath(quarantinePath)); err == nil {
+		return "", fmt.Errorf("%s already exists", quarantinePath)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(LongPath(filepath.Dir(quarantinePath)), 0755); err != nil {
+		return "", err
+	}
+	if move {
+		// renaming fails across volumes, fall back to copy and remove
+		if err := os.Rename(LongPath(filePath), LongPath(quarantinePath)); err == nil {
+			return quarantinePath, nil
+		}
+	}
+	if err := copyFile(filePath, quarantinePath); err != nil {
+		return "", err
+	}
+	if move {
+		if err := os.Remove(LongPath(filePath)); err != nil {
+			return quarantinePath, err
+		}
+	}
+	return quarantinePath, nil
+}
+
+// copyFile copies src to a new file dst, keeping the mode and modification time
+func copyFile(src, dst string) error {
+	in, err := os.Open(LongPath(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(LongPath(dst), os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(LongPath(dst))
+		return err
+	}
+	return os.Chtimes(LongPath(dst), info.ModTime(), info.ModTime())
+}
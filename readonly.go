@@ -0,0 +1,33 @@
+package sensitivity_labels
+
+import "os"
+
+// IsReadOnly reports whether the file is missing the owner write permission,
+// on Windows this is the read-only attribute
+func IsReadOnly(filePath string) (bool, error) {
+	info, err := os.Stat(LongPath(filePath))
+	if err != nil {
+		return false, err
+	}
+	return info.Mode().Perm()&0200 == 0, nil
+}
+
+// ClearReadOnly makes the file writable and returns a function
+// restoring the original permissions
+func ClearReadOnly(filePath string) (func() error, error) {
+	if err := checkWrite(filePath); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(LongPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	mode := info.Mode().Perm()
+	err = os.Chmod(LongPath(filePath), mode|0200)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return os.Chmod(LongPath(filePath), mode)
+	}, nil
+}
@@ -0,0 +1,30 @@
+package sensitivity_labels
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// read-only mode turns every library code path that would open a file for writing,
+// including extraction to a temporary directory, into an ErrWriteDenied error
+var readOnlyMode atomic.Bool
+
+var ErrWriteDenied = errors.New("write denied in read-only mode")
+
+// SetReadOnlyMode guarantees nothing is written while on, e.g. for audited inventory scans
+func SetReadOnlyMode(on bool) {
+	readOnlyMode.Store(on)
+}
+
+func ReadOnlyMode() bool {
+	return readOnlyMode.Load()
+}
+
+// checkWrite fails in read-only mode before path is opened for writing
+func checkWrite(path string) error {
+	if readOnlyMode.Load() {
+		return fmt.Errorf("%w: %s", ErrWriteDenied, path)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package sensitivity_labels
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+)
+
+// reasons a file could not be processed, so each bucket gets the right remediation
+const (
+	ReasonLegacyBinary = "legacy-binary" // .doc, .xls or .ppt binary format: convert to Office Open XML
+	ReasonRTF          = "rtf"           // Rich Text Format: convert to .docx
+	ReasonEncrypted    = "encrypted"     // password protected or IRM encrypted package: decrypt first
+	ReasonCorruptZip   = "corrupt-zip"   // damaged or truncated zip package: restore from backup
+	ReasonPDF          = "pdf"           // PDF document: label with a PDF aware tool
+	ReasonUnknown      = "unknown"       // no handler recognizes the contents
+)
+
+var rtfMagic = []byte(`{\rtf`)
+
+// FailureReason classifies why a file failed to be read from its contents, err is
+// the error of the failure, files that don't fall in a bucket return "" unless err
+// is ErrUnsupportedFormat which returns ReasonUnknown
+func FailureReason(filePath string, err error) string {
+	data, readErr := os.ReadFile(LongPath(filePath))
+	if readErr != nil {
+		return unknownReason(err)
+	}
+	return FailureReasonBytes(data, err)
+}
+
+// FailureReasonBytes is FailureReason of a document read into memory
+func FailureReasonBytes(data []byte, err error) string {
+	switch DetectContainer(data) {
+	case ContainerCFB:
+		return cfbReason(data)
+	case ContainerPDF:
+		return ReasonPDF
+	case ContainerZip:
+		if _, zipErr := zip.NewReader(bytes.NewReader(data), int64(len(data))); zipErr != nil {
+			return ReasonCorruptZip
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimPrefix(data, utf8BOM), rtfMagic) {
+		return ReasonRTF
+	}
+	return unknownReason(err)
+}
+
+func unknownReason(err error) string {
+	if errors.Is(err, ErrUnsupportedFormat) {
+		return ReasonUnknown
+	}
+	return ""
+}
+
+// cfbReason tells encrypted Office Open XML packages, stored in a compound file with
+// EncryptionInfo and EncryptedPackage streams, from legacy binary documents
+func cfbReason(data []byte) string {
+	c, err := openCFB(data)
+	if err != nil {
+		return ReasonLegacyBinary
+	}
+	for _, e := range c.children(c.entries[0]) {
+		if strings.EqualFold(e.name, "EncryptedPackage") {
+			return ReasonEncrypted
+		}
+		// MAPI properties of .msg emails
+		if strings.HasPrefix(strings.ToLower(e.name), "__substg1.0_") {
+			return ""
+		}
+	}
+	return ReasonLegacyBinary
+}
@@ -0,0 +1,28 @@
+package sensitivity_labels
+
+import "time"
+
+// RetryOptions controls how often transiently locked files are retried,
+// the delay doubles after every attempt
+type RetryOptions struct {
+	Retries int
+	Delay   time.Duration
+	// called before each retry, e.g. for logging
+	OnRetry func(attempt int, err error)
+}
+
+// Retry calls fn until it succeeds, returns an error that is not transient
+// or the retries are exhausted
+func Retry(opts RetryOptions, fn func() error) error {
+	delay := opts.Delay
+	err := fn()
+	for attempt := 1; attempt <= opts.Retries && err != nil && IsTransientError(err); attempt++ {
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package sensitivity_labels
+
+import (
+	"errors"
+	"syscall"
+)
+
+// IsTransientError reports whether err is caused by another process
+// briefly holding the file
+func IsTransientError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, syscall.ETXTBSY)
+}
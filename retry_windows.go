@@ -0,0 +1,19 @@
+package sensitivity_labels
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// IsTransientError reports whether err is caused by another process
+// (antivirus, sync clients, Office) briefly holding the file open
+func IsTransientError(err error) bool {
+	return errors.Is(err, errorSharingViolation) ||
+		errors.Is(err, errorLockViolation) ||
+		errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+}
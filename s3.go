@@ -0,0 +1,236 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Storage is a Storage of an S3 bucket (or an S3 compatible store through Endpoint),
+// requests are signed with AWS signature version 4 when AccessKeyId is set
+type S3Storage struct {
+	Bucket          string
+	Region          string
+	Endpoint        string // default https://s3.<region>.amazonaws.com, buckets are addressed by path
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+}
+
+// NewS3Storage returns the storage of a bucket with the credentials, region and endpoint of
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL
+func NewS3Storage(bucket string) *S3Storage {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return &S3Storage{
+		Bucket:          bucket,
+		Region:          region,
+		Endpoint:        strings.TrimSuffix(endpoint, "/"),
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Client:          &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// openS3 opens s3://bucket/prefix
+func openS3(u *url.URL) (Storage, string, error) {
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("%s: missing bucket, expected s3://bucket/prefix", u)
+	}
+	return NewS3Storage(u.Host), strings.TrimPrefix(u.Path, "/"), nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3Storage) List(prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list s3://%s/%s: %w", s.Bucket, prefix, err)
+		}
+		for _, c := range result.Contents {
+			if !strings.HasSuffix(c.Key, "/") {
+				objects = append(objects, StorageObject{Name: c.Key, Size: c.Size, Modified: c.LastModified})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *S3Storage) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Write(name string, data []byte) error {
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPut, name, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Stat(name string) (StorageObject, error) {
+	resp, err := s.do(http.MethodHead, name, nil, nil)
+	if err != nil {
+		return StorageObject{}, err
+	}
+	resp.Body.Close()
+	return objectInfo(name, resp), nil
+}
+
+func (s *S3Storage) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := "/" + s3Escape(s.Bucket, false)
+	if key != "" {
+		path += "/" + s3Escape(key, false)
+	}
+	req, err := http.NewRequest(method, s.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = s3Query(query)
+	req.ContentLength = int64(len(body))
+	s.sign(req, path, req.URL.RawQuery, body, time.Now().UTC())
+	return storageDo(s.Client, req, "s3://"+s.Bucket+"/"+key)
+}
+
+// sign adds the AWS signature version 4 headers
+func (s *S3Storage) sign(req *http.Request, path, rawQuery string, body []byte, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.SessionToken)
+	}
+	if s.AccessKeyId == "" {
+		// public buckets take unsigned requests
+		return
+	}
+	headers := [][2]string{{"host", req.URL.Host}, {"x-amz-content-sha256", payloadHash}, {"x-amz-date", amzDate}}
+	if s.SessionToken != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", s.SessionToken})
+	}
+	canonical, signedHeaders := sigV4CanonicalRequest(req.Method, path, rawQuery, headers, payloadHash)
+	scope := now.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+	key := sigV4SigningKey(s.SecretAccessKey, now.Format("20060102"), s.Region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(key, sigV4StringToSign(amzDate, scope, canonical)))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyId, scope, signedHeaders, signature))
+}
+
+// sigV4CanonicalRequest is the canonical request of signature version 4, headers are
+// lowercase names with their values sorted by name, all of them are signed
+func sigV4CanonicalRequest(method, path, rawQuery string, headers [][2]string, payloadHash string) (string, string) {
+	var canonicalHeaders strings.Builder
+	names := make([]string, 0, len(headers))
+	for _, h := range headers {
+		canonicalHeaders.WriteString(h[0] + ":" + strings.TrimSpace(h[1]) + "\n")
+		names = append(names, h[0])
+	}
+	signedHeaders := strings.Join(names, ";")
+	return strings.Join([]string{method, path, rawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n"), signedHeaders
+}
+
+// sigV4StringToSign hashes the canonical request into the string to sign for scope
+func sigV4StringToSign(amzDate, scope, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	return "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+}
+
+// sigV4SigningKey derives the key of a date (YYYYMMDD), region and service from the secret access key
+func sigV4SigningKey(secret, date, region, service string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Query is the canonical query string of signature version 4: sorted and escaped
+func s3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent encodes everything but unreserved characters, and slashes
+// unless escapeSlash is set
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
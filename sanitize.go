@@ -0,0 +1,191 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SanitizeOptions selects the metadata Sanitize strips from a document
+type SanitizeOptions struct {
+	Authors          bool // core.xml creator and lastModifiedBy, app.xml Company and Manager
+	Comments         bool // comment parts and their anchors
+	TrackChanges     bool // the track changes setting, existing revisions are kept
+	CustomProperties bool // custom.xml properties, MSIP_Label_* properties are kept
+}
+
+// ParseSanitizeOptions parses a comma separated list of
+// authors, comments, track-changes and custom-properties
+func ParseSanitizeOptions(csv string) (SanitizeOptions, error) {
+	var opts SanitizeOptions
+	for _, item := range strings.Split(csv, ",") {
+		switch strings.TrimSpace(item) {
+		case "authors":
+			opts.Authors = true
+		case "comments":
+			opts.Comments = true
+		case "track-changes":
+			opts.TrackChanges = true
+		case "custom-properties":
+			opts.CustomProperties = true
+		case "":
+		default:
+			return opts, fmt.Errorf("unknown metadata %q, expected authors, comments, track-changes or custom-properties", item)
+		}
+	}
+	return opts, nil
+}
+
+var authorElements = []string{"creator", "lastModifiedBy", "Company", "Manager"}
+
+// comment parts of word, excel and powerpoint documents
+var commentPart = regexp.MustCompile(`^(word/comments[A-Za-z]*\.xml|word/people\.xml|xl/comments\d*\.xml|xl/threadedComments/.*\.xml|xl/persons/.*\.xml|ppt/comments/.*\.xml|ppt/commentAuthors\.xml|ppt/authors\.xml)$`)
+var commentAnchor = regexp.MustCompile(`<w:comment(RangeStart|RangeEnd|Reference)[^>]*/>`)
+var trackRevisions = regexp.MustCompile(`<w:trackRevisions\b[^>]*/>`)
+var customProperty = regexp.MustCompile(`(?s)<property\b[^>]*\bname="([^"]*)"[^>]*>.*?</property>|<property\b[^>]*\bname="([^"]*)"[^>]*/>`)
+
+// Sanitize strips the selected metadata from an extracted document,
+// returns a description of every change made
+func Sanitize(unzipDir string, opts SanitizeOptions) ([]string, error) {
+	if err := checkWrite(unzipDir); err != nil {
+		return nil, err
+	}
+	var changes []string
+	edit := func(part string, fn func(string) string) error {
+		path := filepath.Join(unzipDir, part)
+		data, err := os.ReadFile(LongPath(path))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		content := fn(string(data))
+		if content == string(data) {
+			return nil
+		}
+		changes = append(changes, "rewrite "+part)
+		return os.WriteFile(LongPath(path), []byte(content), 0644)
+	}
+
+	if opts.Authors {
+		err := edit("docProps/core.xml", clearAuthorElements)
+		if err == nil {
+			err = edit("docProps/app.xml", clearAuthorElements)
+		}
+		if err != nil {
+			return changes, err
+		}
+	}
+	if opts.Comments {
+		removed, err := removeParts(unzipDir, commentPart)
+		if err != nil {
+			return changes, err
+		}
+		for _, part := range removed {
+			changes = append(changes, "remove "+part)
+		}
+		if len(removed) > 0 {
+			err = edit("word/document.xml", func(s string) string {
+				return commentAnchor.ReplaceAllString(s, "")
+			})
+			if err != nil {
+				return changes, err
+			}
+		}
+	}
+	if opts.TrackChanges {
+		err := edit("word/settings.xml", func(s string) string {
+			return trackRevisions.ReplaceAllString(s, "")
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+	if opts.CustomProperties {
+		err := edit("docProps/custom.xml", func(s string) string {
+			return customProperty.ReplaceAllStringFunc(s, func(p string) string {
+				m := customProperty.FindStringSubmatch(p)
+				if strings.HasPrefix(m[1]+m[2], "MSIP_Label_") {
+					return p
+				}
+				return ""
+			})
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// clearAuthorElements empties the content of author elements, namespace prefixes are kept
+func clearAuthorElements(s string) string {
+	for _, name := range authorElements {
+		re := regexp.MustCompile(`(<(?:\w+:)?` + name + `\b[^>/]*>)[^<]*(</(?:\w+:)?` + name + `>)`)
+		s = re.ReplaceAllString(s, "$1$2")
+	}
+	return s
+}
+
+// removeParts deletes the matching parts along with their
+// relationships and content type overrides
+func removeParts(unzipDir string, match *regexp.Regexp) ([]string, error) {
+	var removed []string
+	err := filepath.WalkDir(LongPath(unzipDir), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(LongPath(unzipDir), path)
+		if err != nil {
+			return err
+		}
+		part := filepath.ToSlash(rel)
+		if match.MatchString(part) {
+			removed = append(removed, part)
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil || len(removed) == 0 {
+		return removed, err
+	}
+
+	// drop relationships targeting removed parts from every .rels file
+	err = filepath.WalkDir(LongPath(unzipDir), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".rels") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		content := string(data)
+		for _, part := range removed {
+			name := regexp.QuoteMeta(filepath.Base(part))
+			re := regexp.MustCompile(`<Relationship\b[^>]*Target="[^"]*` + name + `"[^>]*/>`)
+			content = re.ReplaceAllString(content, "")
+		}
+		if content == string(data) {
+			return nil
+		}
+		return os.WriteFile(path, []byte(content), 0644)
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	contentTypesPath := LongPath(filepath.Join(unzipDir, "[Content_Types].xml"))
+	data, err := os.ReadFile(contentTypesPath)
+	if err != nil {
+		return removed, err
+	}
+	content := string(data)
+	for _, part := range removed {
+		re := regexp.MustCompile(`<Override\b[^>]*PartName="/` + regexp.QuoteMeta(part) + `"[^>]*/>`)
+		content = re.ReplaceAllString(content, "")
+	}
+	return removed, os.WriteFile(contentTypesPath, []byte(content), 0644)
+}
@@ -0,0 +1,187 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Scanner reads (and optionally updates) the labels of every matching file under a path
+// through the registered format handlers, callbacks report progress as files complete
+type Scanner struct {
+	Extensions []string
+	Recursive  bool
+
+	// FS scans a virtual filesystem instead of the OS, paths are then fs.FS names
+	// and only handlers implementing FSLabelReader (and FSLabelWriter) are used
+	FS WriteFS
+
+	// Update decides on new labels for a file, returning false leaves it untouched,
+	// nil only reads labels
+	Update func(fl Result) (Labels, bool)
+
+	OnFileStart func(filePath string)
+	OnFileDone  func(fl Result)
+	OnError     func(filePath string, err error)
+	OnProgress  func(done, total int)
+}
+
+func NewScanner(extensions []string) *Scanner {
+	return &Scanner{Extensions: extensions}
+}
+
+// Scan processes every file, per-file errors are recorded on the results
+// and passed to OnError, only failing to list files returns an error
+func (s *Scanner) Scan(path string) ([]Result, error) {
+	filePaths, err := s.ListFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(filePaths))
+	for i, filePath := range filePaths {
+		if s.OnFileStart != nil {
+			s.OnFileStart(filePath)
+		}
+		start := time.Now()
+		fl, err := s.ScanFile(filePath)
+		fl.DurationMs = time.Since(start).Milliseconds()
+		if info, statErr := s.stat(filePath); statErr == nil {
+			fl.Bytes = info.Size()
+			fl.Modified = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		if err != nil {
+			fl.Error = err.Error()
+			if s.OnError != nil {
+				s.OnError(filePath, err)
+			}
+		}
+		results = append(results, fl)
+		if s.OnFileDone != nil {
+			s.OnFileDone(fl)
+		}
+		if s.OnProgress != nil {
+			s.OnProgress(i+1, len(filePaths))
+		}
+	}
+	return results, nil
+}
+
+func (s *Scanner) stat(filePath string) (fs.FileInfo, error) {
+	if s.FS != nil {
+		return fs.Stat(s.FS, filePath)
+	}
+	return os.Stat(LongPath(filePath))
+}
+
+// ScanFile reads the labels of a single file and applies Update
+func (s *Scanner) ScanFile(filePath string) (Result, error) {
+	fl := Result{FilePath: filePath, Labels: []Label{}}
+	var name string
+	var handler LabelReader
+	var err error
+	if s.FS != nil {
+		name, handler, err = FindHandlerFS(s.FS, filePath)
+	} else {
+		name, handler, err = FindHandler(filePath)
+	}
+	if err != nil {
+		return fl, err
+	}
+	fl.Handler = name
+	labels, labelInfo, err := s.readLabels(handler, filePath)
+	if err != nil {
+		return fl, err
+	}
+	fl.LabelInfo = labelInfo
+	if labels.Labels != nil {
+		fl.Labels = labels.Labels
+	}
+	if s.Update == nil {
+		return fl, nil
+	}
+	newLabels, ok := s.Update(fl)
+	if !ok {
+		return fl, nil
+	}
+	if err := s.writeLabels(handler, filePath, newLabels); err != nil {
+		return fl, fmt.Errorf("%s: %w", name, err)
+	}
+	fl.LabelInfo = true
+	fl.Labels = newLabels.Labels
+	return fl, nil
+}
+
+func (s *Scanner) readLabels(handler LabelReader, filePath string) (Labels, bool, error) {
+	if s.FS == nil {
+		return handler.ReadLabels(filePath)
+	}
+	reader, ok := handler.(FSLabelReader)
+	if !ok {
+		return Labels{}, false, ErrUnsupportedFormat
+	}
+	return reader.ReadLabelsFS(s.FS, filePath)
+}
+
+func (s *Scanner) writeLabels(handler LabelReader, filePath string, labels Labels) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	if s.FS == nil {
+		if writer, ok := handler.(LabelWriter); ok {
+			return writer.WriteLabels(filePath, labels)
+		}
+		return ErrReadOnlyFormat
+	}
+	if writer, ok := handler.(FSLabelWriter); ok {
+		return writer.WriteLabelsFS(s.FS, filePath, labels)
+	}
+	return ErrReadOnlyFormat
+}
+
+// ListFiles returns the matching files under path, path may be a single file
+func (s *Scanner) ListFiles(path string) ([]string, error) {
+	if s.FS != nil {
+		info, err := fs.Stat(s.FS, path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{path}, nil
+		}
+		return ListFilesFS(s.FS, path, s.Recursive, s.Extensions)
+	}
+	info, err := os.Stat(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var filePaths []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && !s.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// skip ~$ owner files Office creates next to open documents
+		if strings.HasPrefix(d.Name(), "~$") {
+			return nil
+		}
+		for _, ext := range s.Extensions {
+			if strings.EqualFold(filepath.Ext(p), ext) {
+				filePaths = append(filePaths, p)
+				break
+			}
+		}
+		return nil
+	})
+	return filePaths, err
+}
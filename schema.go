@@ -0,0 +1,11 @@
+package sensitivity_labels
+
+import _ "embed"
+
+// OutputSchemaVersion is bumped whenever the JSON output changes incompatibly
+const OutputSchemaVersion = 1
+
+// OutputSchema is the JSON Schema of the CLI --json output
+//
+//go:embed schema/output.schema.json
+var OutputSchema []byte
@@ -4,22 +4,27 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/WTFender/sensitivity_labels/opc"
 )
 
 func ExitError(e error) {
-	fmt.Println(e.Error())
-	os.Exit(1)
+	fmt.Fprintln(os.Stderr, e.Error())
+	os.Exit(ExitFatal)
 }
 
 func Zip(dir string) (io.Reader, error) {
 	buf := bytes.Buffer{}
 	w := zip.NewWriter(&buf)
+	dir = LongPath(dir)
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -31,6 +36,10 @@ func Zip(dir string) (io.Reader, error) {
 		if err != nil {
 			return err
 		}
+		// the extraction marker is not part of the document
+		if relPath == TmpMarkerName {
+			return nil
+		}
 		zipPath := filepath.ToSlash(relPath)
 		f, err := w.Create(zipPath)
 		if err != nil {
@@ -56,76 +65,182 @@ func Zip(dir string) (io.Reader, error) {
 	return &buf, nil
 }
 
+// LabelInfoXml returns the LabelInfo.xml document written for labels
+func LabelInfoXml(labels Labels) string {
+	return templateLabelInfoXml(labels)
+}
+
 func templateLabelInfoXml(labels Labels) string {
 	xmlStr := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>`
 	xmlStr += `<clbl:labelList xmlns:clbl="http://schemas.microsoft.com/office/2020/mipLabelMetadata">`
 	for _, label := range labels.Labels {
 		xmlStr += fmt.Sprintf(
-			`<clbl:label id="{%s}" enabled="%s" method="%s" siteId="{%s}" contentBits="%s" removed="%s"/>`,
-			label.Id,
+			`<clbl:label id="{%s}" enabled="%s" method="%s" siteId="{%s}" contentBits="%s" removed="%s"%s/>`,
+			strings.Trim(label.Id, "{}"),
 			label.Enabled,
 			label.Method,
-			label.SiteId,
+			strings.Trim(label.SiteId, "{}"),
 			label.ContentBits,
 			label.Removed,
+			templateExtraAttrs(label.Extra),
 		)
 	}
 	xmlStr += `</clbl:labelList>`
 	return xmlStr
 }
 
+// templateExtraAttrs writes extra attributes back in name order,
+// namespaced attributes declare their own prefix
+func templateExtraAttrs(extra ExtraAttrs) string {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := ""
+	for i, key := range keys {
+		attr := extra[key]
+		value := bytes.Buffer{}
+		xml.EscapeText(&value, []byte(attr.Value))
+		if attr.Namespace == "" {
+			attrs += fmt.Sprintf(` %s="%s"`, attr.Name, value.String())
+			continue
+		}
+		namespace := bytes.Buffer{}
+		xml.EscapeText(&namespace, []byte(attr.Namespace))
+		attrs += fmt.Sprintf(` xmlns:x%d="%s" x%d:%s="%s"`, i, namespace.String(), i, attr.Name, value.String())
+	}
+	return attrs
+}
+
 func SetLabelInfoXml(filePath string, labels Labels) error {
-	err := os.WriteFile(filePath, []byte(templateLabelInfoXml(labels)), 0644)
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	// unlabeled documents have no docMetadata directory yet
+	err := os.MkdirAll(LongPath(filepath.Dir(filePath)), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(LongPath(filePath), []byte(templateLabelInfoXml(labels)), 0644)
 	if err != nil {
-		fmt.Println("warn: error writing " + filePath)
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, "warn: error writing "+filePath)
+		fmt.Fprintln(os.Stderr, err)
 	}
 	return err
 }
 
+// SetLabels writes newLabels to the package extracted to unzipDir and repacks it to
+// filePath, the same package writeLabelInfoPart writes in memory
 func SetLabels(unzipDir, filePath, labelInfoPath string, newLabels Labels) error {
 	err := SetLabelInfoXml(labelInfoPath, newLabels)
 	if err != nil {
 		return err
 	}
-	zip, err := Zip(unzipDir)
+	if err := registerLabelInfoPart(unzipDir); err != nil {
+		return err
+	}
+	return Repack(unzipDir, filePath)
+}
+
+// registerLabelInfoPart adds the content type override and package relationship
+// without which Office ignores LabelInfo.xml to the package extracted to unzipDir
+func registerLabelInfoPart(unzipDir string) error {
+	contentTypesPath := filepath.Join(unzipDir, opc.ContentTypesPart)
+	relsPath := filepath.Join(unzipDir, "_rels", ".rels")
+	contentTypes, err := os.ReadFile(LongPath(contentTypesPath))
 	if err != nil {
 		return err
 	}
-	zipBytes, err := io.ReadAll(zip)
+	parts := map[string][]byte{}
+	rels, err := os.ReadFile(LongPath(relsPath))
+	if err == nil {
+		parts["_rels/.rels"] = rels
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	pkg, err := opc.New(contentTypes, parts)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(filePath, zipBytes, 0644)
+	pkg.SetPart(LabelInfoPart, nil, LabelInfoContentType)
+	if _, err := pkg.AddRelationship("", LabelInfoRelationshipType, LabelInfoPart); err != nil {
+		return err
+	}
+	updated, err := pkg.ContentTypes()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(updated, contentTypes) {
+		if err := os.WriteFile(LongPath(contentTypesPath), updated, 0644); err != nil {
+			return err
+		}
+	}
+	updated, _ = pkg.Part("_rels/.rels")
+	if bytes.Equal(updated, rels) {
+		return nil
+	}
+	if err := os.MkdirAll(LongPath(filepath.Dir(relsPath)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(LongPath(relsPath), updated, 0644)
+}
+
+// Repack writes the extracted document in unzipDir back to filePath
+func Repack(unzipDir, filePath string) error {
+	if err := checkWrite(filePath); err != nil {
+		return err
+	}
+	zip, err := Zip(unzipDir)
 	if err != nil {
 		return err
 	}
+	zipBytes, err := io.ReadAll(zip)
 	if err != nil {
 		return err
 	}
-	return nil
+	return writeFileAtomic(filePath, zipBytes)
 }
 
+// GetLabelInfoXml returns the labels of an extracted LabelInfo.xml, errors are ignored,
+// use ParseLabelInfoFile to tell malformed XML from a document without labels
 func GetLabelInfoXml(filePath string) Labels {
-	var labels Labels
-	xmlFile, err := os.Open(filePath)
-	if err != nil {
-		fmt.Println(err)
+	labels, err := ParseLabelInfoFile(filePath)
+	var xmlErr *XMLError
+	if err != nil && !errors.As(err, &xmlErr) {
+		fmt.Fprintln(os.Stderr, err)
 	}
-	byteValue, _ := io.ReadAll(xmlFile)
-	xml.Unmarshal(byteValue, &labels)
-	xmlFile.Close()
 	return labels
 }
 
 func CheckLabelInfoPath(dirPath string) (bool, string) {
 	labelInfoPath := dirPath + "/docMetadata/LabelInfo.xml"
-	_, err := os.Stat(labelInfoPath)
+	_, err := os.Stat(LongPath(labelInfoPath))
 	return (err == nil), labelInfoPath
 }
 
+// UnzipOptions limits what Unzip extracts and what in-memory reads decompress,
+// so crafted archives can't exhaust the disk or memory of the scanning host
+type UnzipOptions = opc.Limits
+
+// DefaultUnzipOptions are well above any real Office document
+var DefaultUnzipOptions = opc.DefaultLimits
+
+// ErrUnsafeArchive is returned for entries that escape the destination, symlink and
+// duplicate entries, and archives exceeding the UnzipOptions limits
+var ErrUnsafeArchive = opc.ErrUnsafeArchive
+
 func Unzip(src, dest string) error {
-	r, err := zip.OpenReader(src)
+	return UnzipWith(src, dest, DefaultUnzipOptions)
+}
+
+// UnzipWith extracts src into dest, rejecting symlink and duplicate entries
+// and enforcing the limits of opts
+func UnzipWith(src, dest string, opts UnzipOptions) error {
+	if err := checkWrite(dest); err != nil {
+		return err
+	}
+	r, err := zip.OpenReader(LongPath(src))
 	if err != nil {
 		return err
 	}
@@ -135,10 +250,27 @@ func Unzip(src, dest string) error {
 		}
 	}()
 
+	dest = LongPath(dest)
 	os.MkdirAll(dest, 0755)
 
+	// entry names seen so far, case-insensitive as on Windows
+	seen := map[string]bool{}
+	var written int64
+
 	// Closure to address file descriptors issue with all the deferred .Close() methods
 	extractAndWriteFile := func(f *zip.File) error {
+		// the same checks as in-memory reads, see opc.ReadWith
+		name, err := opc.CheckEntry(f, opts)
+		if err != nil {
+			return err
+		}
+		if !f.FileInfo().IsDir() {
+			if seen[strings.ToLower(name)] {
+				return fmt.Errorf("%w: duplicate entry %s", ErrUnsafeArchive, f.Name)
+			}
+			seen[strings.ToLower(name)] = true
+		}
+
 		rc, err := f.Open()
 		if err != nil {
 			return err
@@ -149,11 +281,11 @@ func Unzip(src, dest string) error {
 			}
 		}()
 
-		path := filepath.Join(dest, f.Name)
+		path := filepath.Join(dest, filepath.FromSlash(name))
 
 		// Check for ZipSlip (Directory traversal)
 		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", path)
+			return fmt.Errorf("%w: illegal file path: %s", ErrUnsafeArchive, f.Name)
 		}
 
 		if f.FileInfo().IsDir() {
@@ -170,10 +302,19 @@ func Unzip(src, dest string) error {
 				}
 			}()
 
-			_, err = io.Copy(f, rc)
+			// the declared sizes may lie, count what is actually decompressed
+			var content io.Reader = rc
+			if opts.MaxBytes > 0 {
+				content = io.LimitReader(rc, opts.MaxBytes-written+1)
+			}
+			n, err := io.Copy(f, content)
+			written += n
 			if err != nil {
 				return err
 			}
+			if opts.MaxBytes > 0 && written > opts.MaxBytes {
+				return fmt.Errorf("%w: more than %d bytes extracted", ErrUnsafeArchive, opts.MaxBytes)
+			}
 		}
 		return nil
 	}
@@ -200,6 +341,10 @@ func isExtensionFile(file os.FileInfo, exts []string) bool {
 func filterFilesByExtension(files []os.FileInfo, exts []string) []os.FileInfo {
 	var filteredFiles []os.FileInfo
 	for _, file := range files {
+		// skip ~$ owner files Office creates next to open documents
+		if strings.HasPrefix(file.Name(), "~$") {
+			continue
+		}
 		if isExtensionFile(file, exts) {
 			filteredFiles = append(filteredFiles, file)
 		}
@@ -211,7 +356,7 @@ func ListExtensionFiles(dir string, recursive bool, exts []string) []os.FileInfo
 	var files []fs.FileInfo
 
 	if !recursive {
-		items, err := os.ReadDir(dir)
+		items, err := os.ReadDir(LongPath(dir))
 		if err != nil {
 			ExitError(err)
 		}
@@ -228,7 +373,7 @@ func ListExtensionFiles(dir string, recursive bool, exts []string) []os.FileInfo
 
 	} else {
 		// recursively list files
-		err := filepath.Walk(dir,
+		err := filepath.Walk(LongPath(dir),
 			func(path string, info os.FileInfo, err error) error {
 				if err != nil {
 					ExitError(err)
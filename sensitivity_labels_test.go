@@ -0,0 +1,123 @@
+package sensitivity_labels
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEntry is a zip entry written by writeZip
+type testEntry struct {
+	name string
+	data string
+	mode fs.FileMode
+}
+
+// writeZip builds a zip of entries in dir and returns its path
+func writeZip(t *testing.T, dir string, entries []testEntry) string {
+	t.Helper()
+	zipPath := filepath.Join(dir, "test.docx")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+func TestUnzipWith(t *testing.T) {
+	opts := UnzipOptions{MaxBytes: 1024, MaxDepth: 4}
+	tests := []struct {
+		name    string
+		entries []testEntry
+		wantErr string // empty when the archive is extracted
+		file    string // file expected below the extraction directory
+	}{
+		{"valid", []testEntry{{name: "word/document.xml", data: "<doc/>"}}, "", "word/document.xml"},
+		{"dots in name", []testEntry{{name: "word/media/a..b.png", data: "png"}}, "", "word/media/a..b.png"},
+		{"backslashes", []testEntry{{name: `word\document.xml`, data: "<doc/>"}}, "", "word/document.xml"},
+		{"parent directory", []testEntry{{name: "../evil.xml", data: "x"}}, "illegal file path", ""},
+		{"nested parent directory", []testEntry{{name: "word/../../evil.xml", data: "x"}}, "illegal file path", ""},
+		{"backslash parent directory", []testEntry{{name: `..\evil.xml`, data: "x"}}, "illegal file path", ""},
+		{"absolute", []testEntry{{name: "/tmp/evil.xml", data: "x"}}, "absolute entry", ""},
+		{"drive letter", []testEntry{{name: `C:\evil.xml`, data: "x"}}, "absolute entry", ""},
+		{"duplicate", []testEntry{{name: "word/document.xml"}, {name: "word/document.xml"}}, "duplicate entry", ""},
+		{"duplicate case", []testEntry{{name: "word/document.xml"}, {name: "WORD/document.xml"}}, "duplicate entry", ""},
+		{"symlink", []testEntry{{name: "word/link.xml", data: "/etc/passwd", mode: fs.ModeSymlink | 0777}}, "symlink entry", ""},
+		{"too deep", []testEntry{{name: "a/b/c/d/e/f.xml", data: "x"}}, "nested deeper", ""},
+		{"oversized entry", []testEntry{{name: "word/big.bin", data: strings.Repeat("a", 2048)}}, "more than 1024 bytes", ""},
+		{"oversized total", []testEntry{{name: "a.bin", data: strings.Repeat("a", 600)}, {name: "b.bin", data: strings.Repeat("b", 600)}}, "more than 1024 bytes", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := writeZip(t, dir, tt.entries)
+			dest := filepath.Join(dir, "out")
+			err := UnzipWith(src, dest, opts)
+			if _, statErr := os.Stat(filepath.Join(dir, "evil.xml")); statErr == nil {
+				t.Fatal("entry written outside the extraction directory")
+			}
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrUnsafeArchive) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UnzipWith() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnzipWith() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(tt.file))); err != nil {
+				t.Fatalf("%s not extracted: %v", tt.file, err)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.docx")
+	if err := os.WriteFile(path, []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("relabeled")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "relabeled" {
+		t.Fatalf("file = %q, %v, want the new content", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v, %v, want the original 0600", info.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("directory has %d entries, want the temporary file removed", len(entries))
+	}
+
+	SetReadOnlyMode(true)
+	defer SetReadOnlyMode(false)
+	if err := writeFileAtomic(path, []byte("denied")); !errors.Is(err, ErrWriteDenied) {
+		t.Fatalf("writeFileAtomic() in read-only mode = %v, want ErrWriteDenied", err)
+	}
+}
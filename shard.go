@@ -0,0 +1,30 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseShard parses an --shard value, "3/8" is the third of eight slices
+func ParseShard(value string) (index, count int, err error) {
+	i, n, ok := strings.Cut(value, "/")
+	index, errIndex := strconv.Atoi(strings.TrimSpace(i))
+	count, errCount := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || errIndex != nil || errCount != nil || count < 1 || index < 1 || index > count {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected <index>/<count> such as 3/8", value)
+	}
+	return index, count, nil
+}
+
+// InShard reports whether a file belongs to slice index (1 based) of count, files are
+// assigned by a hash of their path relative to the scanned root, with forward slashes
+// and ignoring case, so every job assigns a file to the same slice whatever the
+// machine, drive letter or mount point the tree is scanned from
+func InShard(relPath string, index, count int) bool {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(filepath.ToSlash(relPath))))
+	return int(h.Sum64()%uint64(count)) == index-1
+}
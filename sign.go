@@ -0,0 +1,118 @@
+package sensitivity_labels
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+)
+
+// signature algorithms of manifests and plans
+const (
+	SignatureHMAC    = "HMAC-SHA256"
+	SignatureRSA     = "RSA-SHA256"
+	SignatureECDSA   = "ECDSA-SHA256"
+	SignatureEd25519 = "Ed25519"
+)
+
+// ErrSignature is returned for signatures that don't match the payload or key
+var ErrSignature = errors.New("signature verification failed")
+
+func signHMAC(payload, key []byte) (string, string) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return SignatureHMAC, hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyHMAC(payload, key []byte, alg, signature string) bool {
+	if alg != SignatureHMAC {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// signX509 signs payload with a PEM encoded private key (RSA, ECDSA or Ed25519)
+func signX509(payload, keyPEM []byte) (string, string, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return "", "", errors.New("unable to decode PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+			key = rsaKey
+		} else if ecKey, ecErr := x509.ParseECPrivateKey(block.Bytes); ecErr == nil {
+			key = ecKey
+		} else {
+			return "", "", err
+		}
+	}
+	digest := sha256.Sum256(payload)
+	var alg string
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		alg = SignatureRSA
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		alg = SignatureECDSA
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest[:])
+	case ed25519.PrivateKey:
+		alg = SignatureEd25519
+		sig = ed25519.Sign(k, payload)
+	default:
+		return "", "", errors.New("unsupported private key type")
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return alg, base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// verifyX509 checks a signature made by signX509 against a PEM certificate or public key
+func verifyX509(payload, pubPEM []byte, alg, signature string) error {
+	block, _ := pem.Decode(pubPEM)
+	if block == nil {
+		return errors.New("unable to decode PEM certificate or public key")
+	}
+	var pub any
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		pub = cert.PublicKey
+	} else if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrSignature
+	}
+	digest := sha256.Sum256(payload)
+	ok := false
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		ok = alg == SignatureRSA && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		ok = alg == SignatureECDSA && ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		ok = alg == SignatureEd25519 && ed25519.Verify(k, payload, sig)
+	default:
+		return errors.New("unsupported public key type")
+	}
+	if !ok {
+		return ErrSignature
+	}
+	return nil
+}
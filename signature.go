@@ -0,0 +1,33 @@
+package sensitivity_labels
+
+import (
+	"archive/zip"
+	"path"
+	"strings"
+)
+
+// XML digital signatures of an OOXML package are stored under _xmlsignatures/,
+// rewriting any signed part invalidates them
+const signaturesDir = "_xmlsignatures/"
+
+// FindSignatures returns the signature parts of a document, read from the
+// zip directory without extracting anything
+func FindSignatures(filePath string) ([]string, error) {
+	r, err := zip.OpenReader(LongPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var parts []string
+	for _, f := range r.File {
+		name := strings.TrimPrefix(strings.ReplaceAll(f.Name, "\\", "/"), "/")
+		if f.FileInfo().IsDir() || !strings.HasPrefix(strings.ToLower(name), signaturesDir) {
+			continue
+		}
+		// sig1.xml, sig2.xml, ... next to origin.sigs and their relationships
+		if strings.EqualFold(path.Ext(name), ".xml") && !strings.Contains(name, "/_rels/") {
+			parts = append(parts, name)
+		}
+	}
+	return parts, nil
+}
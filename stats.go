@@ -0,0 +1,62 @@
+package sensitivity_labels
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// DocumentStatistics are size and complexity counts of a document,
+// counts that don't apply to the document type are zero
+type DocumentStatistics struct {
+	Pages           int
+	Words           int
+	Sheets          int
+	Slides          int
+	EmbeddedObjects int
+	Media           int
+}
+
+type appStatistics struct {
+	Pages  int `xml:"Pages"`
+	Words  int `xml:"Words"`
+	Slides int `xml:"Slides"`
+}
+
+// GetDocumentStatistics reads the counts Office saves in docProps/app.xml
+// and counts sheets, embedded objects and media in the package
+func GetDocumentStatistics(unzipDir string) (DocumentStatistics, error) {
+	var stats DocumentStatistics
+	var app appStatistics
+	if err := readXmlPart(unzipDir+"/docProps/app.xml", &app); err != nil {
+		return stats, err
+	}
+	stats.Pages = app.Pages
+	stats.Words = app.Words
+	stats.Slides = app.Slides
+
+	root := LongPath(unzipDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		part := filepath.ToSlash(rel)
+		switch {
+		case strings.HasPrefix(part, "xl/worksheets/") && strings.HasSuffix(part, ".xml"):
+			stats.Sheets++
+		case strings.Contains(part, "/embeddings/"):
+			stats.EmbeddedObjects++
+		case strings.Contains(part, "/media/"):
+			stats.Media++
+		case strings.HasPrefix(part, "ppt/slides/") && strings.HasSuffix(part, ".xml") && app.Slides == 0:
+			// app.xml may be missing or stale
+			stats.Slides++
+		}
+		return nil
+	})
+	return stats, err
+}
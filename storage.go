@@ -0,0 +1,187 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StorageObject is a document of a Storage
+type StorageObject struct {
+	Name     string // slash separated, relative to the root of the storage
+	Size     int64
+	Modified time.Time
+}
+
+// Storage is a source and destination of documents: the local disk, object stores
+// or file shares, so the same scan runs against any of them
+type Storage interface {
+	// List returns the objects whose names start with prefix, at any depth
+	List(prefix string) ([]StorageObject, error)
+	Open(name string) (io.ReadCloser, error)
+	Write(name string, data []byte) error
+	Stat(name string) (StorageObject, error)
+}
+
+// StorageOpener opens the storage a URL refers to and returns the path of the URL
+// within the storage
+type StorageOpener func(u *url.URL) (Storage, string, error)
+
+var storagesMu sync.RWMutex
+var storages = map[string]StorageOpener{}
+
+// RegisterStorage adds a storage backend for URLs of scheme, e.g. s3://bucket/prefix,
+// registering an existing scheme replaces that backend
+func RegisterStorage(scheme string, open StorageOpener) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+	storages[strings.ToLower(scheme)] = open
+}
+
+// Storages returns the URL schemes of the registered storage backends
+func Storages() []string {
+	storagesMu.RLock()
+	defer storagesMu.RUnlock()
+	schemes := make([]string, 0, len(storages))
+	for scheme := range storages {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// IsStorageURL reports whether a source is a URL of a registered storage backend
+func IsStorageURL(source string) bool {
+	scheme, _, ok := strings.Cut(source, "://")
+	if !ok {
+		return false
+	}
+	storagesMu.RLock()
+	defer storagesMu.RUnlock()
+	_, ok = storages[strings.ToLower(scheme)]
+	return ok
+}
+
+// OpenStorage opens the storage backend of a URL, prefix is the path of the URL
+// within the storage without its leading slash
+func OpenStorage(rawURL string) (Storage, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	storagesMu.RLock()
+	open, ok := storages[strings.ToLower(u.Scheme)]
+	storagesMu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("no storage backend for %s URLs", u.Scheme)
+	}
+	return open(u)
+}
+
+// ReadObject reads an object into memory, objects larger than maxBytes fail (0 for no limit)
+func ReadObject(s Storage, name string, maxBytes int64) ([]byte, error) {
+	r, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	body := io.Reader(r)
+	if maxBytes > 0 {
+		body = io.LimitReader(r, maxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%s: document larger than %d bytes", name, maxBytes)
+	}
+	return data, nil
+}
+
+// DiskStorage is a Storage of a directory of the local filesystem
+type DiskStorage struct {
+	Root string
+}
+
+func (d DiskStorage) path(name string) string {
+	return LongPath(JoinPath(d.Root, name))
+}
+
+func (d DiskStorage) List(prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	err := filepath.WalkDir(LongPath(d.Root), func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(LongPath(d.Root), p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StorageObject{Name: name, Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+func (d DiskStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(d.path(name))
+}
+
+func (d DiskStorage) Write(name string, data []byte) error {
+	if err := checkWrite(name); err != nil {
+		return err
+	}
+	return os.WriteFile(d.path(name), data, 0644)
+}
+
+func (d DiskStorage) Stat(name string) (StorageObject, error) {
+	info, err := os.Stat(d.path(name))
+	if err != nil {
+		return StorageObject{}, err
+	}
+	return StorageObject{Name: name, Size: info.Size(), Modified: info.ModTime()}, nil
+}
+
+// storageDo sends a request of a remote storage, missing objects are fs.ErrNotExist
+func storageDo(client *http.Client, req *http.Request, name string) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(req.Method), Path: name, Err: fs.ErrNotExist}
+	}
+	return nil, fmt.Errorf("%s %s: %s", req.Method, name, resp.Status)
+}
+
+// objectInfo reads the size and modification time of a HEAD or GET response
+func objectInfo(name string, resp *http.Response) StorageObject {
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return StorageObject{Name: name, Size: resp.ContentLength, Modified: modified}
+}
+
+func init() {
+	RegisterStorage("s3", openS3)
+	RegisterStorage("azblob", openAzureBlob)
+}
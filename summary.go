@@ -0,0 +1,269 @@
+package sensitivity_labels
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary aggregates the results of a scan, label and tenant counts are keyed by
+// normalized ID and count files, not label entries
+type Summary struct {
+	Files      int            `json:"files"`
+	Labeled    int            `json:"labeled"`
+	Unlabeled  int            `json:"unlabeled"`
+	Errors     int            `json:"errors"`
+	Skipped    int            `json:"skipped"`
+	Bytes      int64          `json:"bytes"`
+	DurationMs int64          `json:"durationMs"`
+	Labels     map[string]int `json:"labels"`
+	Tenants    map[string]int `json:"tenants"`
+	ErrorsBy   map[string]int `json:"errorsByCategory,omitempty"`
+	Reasons    map[string]int `json:"errorsByReason,omitempty"`
+}
+
+// Summarize counts results, duration is the wall time of the scan
+func Summarize(results []Result, duration time.Duration) Summary {
+	s := Summary{
+		Files:      len(results),
+		DurationMs: duration.Milliseconds(),
+		Labels:     map[string]int{},
+		Tenants:    map[string]int{},
+	}
+	for _, fl := range results {
+		s.Bytes += fl.Bytes
+		switch {
+		case fl.Error != "":
+			s.Errors++
+			if s.ErrorsBy == nil {
+				s.ErrorsBy = map[string]int{}
+			}
+			s.ErrorsBy[fl.ErrorCategory]++
+			if fl.Reason != "" {
+				if s.Reasons == nil {
+					s.Reasons = map[string]int{}
+				}
+				s.Reasons[fl.Reason]++
+			}
+		case fl.Skipped != "":
+			s.Skipped++
+		case len(fl.Labels) > 0:
+			s.Labeled++
+		default:
+			s.Unlabeled++
+		}
+		seen := map[string]bool{}
+		for _, label := range fl.Labels {
+			id, siteId := NormalizeId(label.Id), NormalizeId(label.SiteId)
+			if !seen["l"+id] {
+				seen["l"+id] = true
+				s.Labels[id]++
+			}
+			if !seen["t"+siteId] {
+				seen["t"+siteId] = true
+				s.Tenants[siteId]++
+			}
+		}
+	}
+	return s
+}
+
+// Group is the rollup of the results sharing a --group-by key
+type Group struct {
+	Key       string     `json:"key"`
+	Files     int        `json:"files"`
+	Labeled   int        `json:"labeled"`
+	Unlabeled int        `json:"unlabeled"`
+	Errors    int        `json:"errors"`
+	Bytes     int64      `json:"bytes"`
+	Ownership *Ownership `json:"ownership,omitempty"` // of a directory key
+}
+
+// dimensions accepted by GroupResults
+const (
+	GroupByLabel      = "label"
+	GroupByTenant     = "tenant"
+	GroupByDirectory  = "directory"
+	GroupByExtension  = "extension"
+	GroupByDepartment = "department" // from the Ownership of each result
+	GroupByOwner      = "owner"
+)
+
+// NoGroupKey groups files without labels when grouping by label or tenant,
+// and files without ownership when grouping by department or owner
+const NoGroupKey = "(none)"
+
+// GroupResults rolls results up by label, tenant, directory, extension, department or owner, ordered by key,
+// directories are relative to root and cut to depth components (the top-level folder with 1),
+// a file carrying several labels counts once in each of their groups
+func GroupResults(results []Result, by, root string, depth int) ([]Group, error) {
+	switch by {
+	case GroupByLabel, GroupByTenant, GroupByDirectory, GroupByExtension, GroupByDepartment, GroupByOwner:
+	default:
+		return nil, fmt.Errorf("invalid group-by %q, expected label, tenant, directory, extension, department or owner", by)
+	}
+	groups := map[string]*Group{}
+	for _, fl := range results {
+		for _, key := range groupKeys(fl, by, root, depth) {
+			g, ok := groups[key]
+			if !ok {
+				g = &Group{Key: key}
+				groups[key] = g
+			}
+			g.Files++
+			g.Bytes += fl.Bytes
+			switch {
+			case fl.Error != "":
+				g.Errors++
+			case len(fl.Labels) > 0:
+				g.Labeled++
+			case fl.Skipped == "":
+				g.Unlabeled++
+			}
+		}
+	}
+	sorted := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted, nil
+}
+
+func groupKeys(fl Result, by, root string, depth int) []string {
+	switch by {
+	case GroupByLabel, GroupByTenant:
+		var keys []string
+		seen := map[string]bool{}
+		for _, label := range fl.Labels {
+			key := NormalizeId(label.Id)
+			if by == GroupByTenant {
+				key = NormalizeId(label.SiteId)
+			}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			keys = append(keys, NoGroupKey)
+		}
+		return keys
+	case GroupByExtension:
+		return []string{strings.ToLower(filepath.Ext(fl.FilePath))}
+	case GroupByDepartment, GroupByOwner:
+		key := ""
+		if fl.Ownership != nil {
+			key = fl.Ownership.Department
+			if by == GroupByOwner {
+				key = fl.Ownership.Owner
+			}
+		}
+		if key == "" {
+			key = NoGroupKey
+		}
+		return []string{key}
+	}
+	dir, err := filepath.Rel(root, filepath.Dir(fl.FilePath))
+	if err != nil {
+		dir = filepath.Dir(fl.FilePath)
+	}
+	parts := strings.Split(filepath.ToSlash(dir), "/")
+	if depth > 0 && len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return []string{strings.Join(parts, "/")}
+}
+
+// TopFiles lists the unlabeled files remediation should start with
+type TopFiles struct {
+	Largest []Result `json:"largest"`
+	Recent  []Result `json:"recent"`
+}
+
+// TopUnlabeled returns the n largest and the n most recently modified unlabeled files,
+// files that failed or were skipped are left out
+func TopUnlabeled(results []Result, n int) TopFiles {
+	var unlabeled []Result
+	for _, fl := range results {
+		if len(fl.Labels) == 0 && fl.Error == "" && fl.Skipped == "" {
+			unlabeled = append(unlabeled, fl)
+		}
+	}
+	top := func(less func(a, b Result) bool) []Result {
+		sorted := append([]Result{}, unlabeled...)
+		sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+		if len(sorted) > n {
+			sorted = sorted[:n]
+		}
+		return sorted
+	}
+	return TopFiles{
+		Largest: top(func(a, b Result) bool { return a.Bytes > b.Bytes }),
+		// RFC 3339 UTC timestamps sort as strings
+		Recent: top(func(a, b Result) bool { return a.Modified > b.Modified }),
+	}
+}
+
+// DirectoryRollup totals a directory and everything below it
+type DirectoryRollup struct {
+	Directory     string     `json:"directory"`
+	Files         int        `json:"files"`
+	Labeled       int        `json:"labeled"`
+	Unlabeled     int        `json:"unlabeled"`
+	Errors        int        `json:"errors"`
+	DominantLabel string     `json:"dominantLabel,omitempty"` // label carried by the most files
+	DominantFiles int        `json:"dominantFiles,omitempty"`
+	Ownership     *Ownership `json:"ownership,omitempty"`
+}
+
+// RollupDirectories returns a rollup for every directory holding results under root,
+// including their parents up to root, ordered by directory
+func RollupDirectories(results []Result, root string) []DirectoryRollup {
+	rollups := map[string]*DirectoryRollup{}
+	labels := map[string]map[string]int{}
+	for _, fl := range results {
+		dir := groupKeys(fl, GroupByDirectory, root, 0)[0]
+		for {
+			r, ok := rollups[dir]
+			if !ok {
+				r = &DirectoryRollup{Directory: dir}
+				rollups[dir] = r
+				labels[dir] = map[string]int{}
+			}
+			r.Files++
+			switch {
+			case fl.Error != "":
+				r.Errors++
+			case len(fl.Labels) > 0:
+				r.Labeled++
+			case fl.Skipped == "":
+				r.Unlabeled++
+			}
+			for _, key := range groupKeys(fl, GroupByLabel, root, 0) {
+				if key != NoGroupKey {
+					labels[dir][key]++
+				}
+			}
+			parent := path.Dir(dir)
+			if dir == "." || dir == ".." || strings.HasPrefix(dir, "../") || parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	sorted := make([]DirectoryRollup, 0, len(rollups))
+	for dir, r := range rollups {
+		for id, n := range labels[dir] {
+			if n > r.DominantFiles || (n == r.DominantFiles && id < r.DominantLabel) {
+				r.DominantLabel, r.DominantFiles = id, n
+			}
+		}
+		sorted = append(sorted, *r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Directory < sorted[j].Directory })
+	return sorted
+}
@@ -0,0 +1,78 @@
+package sensitivity_labels
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// TarHandler recognizes tar and gzip compressed tar archives, archives carry no
+// label of their own, their documents are read with WalkTar
+type TarHandler struct{}
+
+var gzipMagic = []byte{0x1F, 0x8B}
+
+// ustar magic at offset 257 of the first header
+const tarMagicOffset = 257
+
+func (h *TarHandler) Sniff(filePath string, header []byte) bool {
+	name := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return len(header) > tarMagicOffset+5 && string(header[tarMagicOffset:tarMagicOffset+5]) == "ustar"
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return bytes.HasPrefix(header, gzipMagic)
+	}
+	return false
+}
+
+func (h *TarHandler) ReadLabels(filePath string) (Labels, bool, error) {
+	return Labels{}, false, nil
+}
+
+// WalkTar streams the regular files of a tar or tar.gz archive to fn, nothing is
+// extracted to disk, r is only valid during the call
+func WalkTar(filePath string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// links, devices and directories have no contents of their own
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// TarEntryName cleans an entry name for display, entries are never written to disk
+func TarEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,165 @@
+package sensitivity_labels
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// marker file written into every extraction directory so
+// directories left behind by crashed runs can be found later
+const TmpMarkerName = ".labels-tmp.json"
+
+type TmpMarker struct {
+	RunId   string `json:"runId"`
+	Pid     int    `json:"pid"`
+	Host    string `json:"host"`
+	Source  string `json:"source"`
+	Created string `json:"created"`
+}
+
+// TmpDirName returns the extraction directory name for source, derived from a hash
+// of its path so it stays short and ASCII whatever characters the file name contains
+func TmpDirName(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return "_" + hex.EncodeToString(sum[:8])
+}
+
+// TmpOptions controls how extracted document content is kept on disk,
+// labeled documents are sensitive by definition
+type TmpOptions struct {
+	Private bool // extraction directories are only accessible to the current user (0700)
+	Shred   bool // extracted files are overwritten with zeros before they are removed
+	NoDisk  bool // extraction fails with ErrNoDisk, documents are only processed in memory
+}
+
+var ErrNoDisk = errors.New("document content would be written to disk")
+
+// CreateTmpDir creates an extraction directory for source and writes its marker
+func CreateTmpDir(dir, source, runId string) error {
+	return CreateTmpDirWith(dir, source, runId, TmpOptions{})
+}
+
+// CreateTmpDirWith creates an extraction directory as CreateTmpDir with opts applied
+func CreateTmpDirWith(dir, source, runId string, opts TmpOptions) error {
+	if opts.NoDisk {
+		return ErrNoDisk
+	}
+	if err := checkWrite(dir); err != nil {
+		return err
+	}
+	perm := os.FileMode(0755)
+	if opts.Private {
+		perm = 0700
+	}
+	err := os.MkdirAll(LongPath(dir), perm)
+	if err != nil {
+		return err
+	}
+	// an existing directory keeps its permissions otherwise
+	if opts.Private {
+		if err := os.Chmod(LongPath(dir), perm); err != nil {
+			return err
+		}
+	}
+	host, _ := os.Hostname()
+	data, err := json.Marshal(TmpMarker{
+		RunId:   runId,
+		Pid:     os.Getpid(),
+		Host:    host,
+		Source:  source,
+		Created: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(LongPath(filepath.Join(dir, TmpMarkerName)), data, 0644)
+}
+
+// RemoveTmpDir removes an extraction directory, with opts.Shred its files are
+// overwritten first, the directory is removed even if shredding fails
+func RemoveTmpDir(dir string, opts TmpOptions) error {
+	var shredErr error
+	if opts.Shred {
+		shredErr = shredFiles(dir)
+	}
+	if err := os.RemoveAll(LongPath(dir)); err != nil {
+		return err
+	}
+	return shredErr
+}
+
+// shredFiles overwrites every regular file under dir with zeros and flushes it to disk,
+// filesystems that copy on write or SSD wear leveling may still keep the old blocks
+func shredFiles(dir string) error {
+	return filepath.WalkDir(LongPath(dir), func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// extracted entries may be read only
+		os.Chmod(path, 0600)
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(f, zeros{}, info.Size())
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+// zeros is an endless reader of zero bytes
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+func ReadTmpMarker(dir string) (TmpMarker, error) {
+	var marker TmpMarker
+	data, err := os.ReadFile(LongPath(filepath.Join(dir, TmpMarkerName)))
+	if err != nil {
+		return marker, err
+	}
+	err = json.Unmarshal(data, &marker)
+	return marker, err
+}
+
+// FindTmpDirs returns the extraction directories directly under root,
+// directories without a marker are never returned
+func FindTmpDirs(root string) ([]string, error) {
+	entries, err := os.ReadDir(LongPath(root))
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if _, err := os.Stat(LongPath(filepath.Join(dir, TmpMarkerName))); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
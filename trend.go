@@ -0,0 +1,166 @@
+package sensitivity_labels
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ReadResults reads results saved with --json (the results array or the report object)
+// or as NDJSON with one result per line, e.g. from a sink plugin
+func ReadResults(path string) ([]Result, error) {
+	data, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	var results []Result
+	if bytes.HasPrefix(data, []byte("[")) {
+		err := json.Unmarshal(data, &results)
+		return results, err
+	}
+	var report struct {
+		Results *[]Result `json:"results"`
+	}
+	if json.Unmarshal(data, &report) == nil && report.Results != nil {
+		return *report.Results, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var fl Result
+		if err := json.Unmarshal(scanner.Bytes(), &fl); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		results = append(results, fl)
+	}
+	return results, scanner.Err()
+}
+
+// Trend compares two scans of the same tree, files are matched by path
+type Trend struct {
+	FilesBefore    int          `json:"filesBefore"`
+	FilesAfter     int          `json:"filesAfter"`
+	CoverageBefore float64      `json:"coverageBefore"` // percent of files carrying a label
+	CoverageAfter  float64      `json:"coverageAfter"`
+	NewlyLabeled   []string     `json:"newlyLabeled"`
+	NewlyUnlabeled []string     `json:"newlyUnlabeled"`
+	Relabeled      []string     `json:"relabeled"` // labeled in both with different labels
+	Added          []string     `json:"added"`     // only in the later scan
+	Removed        []string     `json:"removed"`   // only in the earlier scan
+	LabelShifts    []LabelShift `json:"labelShifts"`
+	TenantShifts   []LabelShift `json:"tenantShifts"`
+}
+
+// LabelShift is the change in the number of files carrying a label (or tenant)
+type LabelShift struct {
+	Id     string `json:"id"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Change int    `json:"change"`
+}
+
+// CompareResults builds the trend from an earlier and a later scan,
+// files that failed in either scan are only counted in the totals
+func CompareResults(before, after []Result) Trend {
+	t := Trend{
+		FilesBefore:    len(before),
+		FilesAfter:     len(after),
+		CoverageBefore: coverage(before),
+		CoverageAfter:  coverage(after),
+		NewlyLabeled:   []string{},
+		NewlyUnlabeled: []string{},
+		Relabeled:      []string{},
+		Added:          []string{},
+		Removed:        []string{},
+	}
+	earlier := map[string]Result{}
+	for _, fl := range before {
+		earlier[fl.FilePath] = fl
+	}
+	later := map[string]bool{}
+	for _, fl := range after {
+		later[fl.FilePath] = true
+		prev, ok := earlier[fl.FilePath]
+		switch {
+		case !ok:
+			t.Added = append(t.Added, fl.FilePath)
+		case prev.Error != "" || fl.Error != "":
+		case len(prev.Labels) == 0 && len(fl.Labels) > 0:
+			t.NewlyLabeled = append(t.NewlyLabeled, fl.FilePath)
+		case len(prev.Labels) > 0 && len(fl.Labels) == 0:
+			t.NewlyUnlabeled = append(t.NewlyUnlabeled, fl.FilePath)
+		case !sameLabelIds(prev.Labels, fl.Labels):
+			t.Relabeled = append(t.Relabeled, fl.FilePath)
+		}
+	}
+	for _, fl := range before {
+		if !later[fl.FilePath] {
+			t.Removed = append(t.Removed, fl.FilePath)
+		}
+	}
+	s1, s2 := Summarize(before, 0), Summarize(after, 0)
+	t.LabelShifts = shifts(s1.Labels, s2.Labels)
+	t.TenantShifts = shifts(s1.Tenants, s2.Tenants)
+	return t
+}
+
+func coverage(results []Result) float64 {
+	if len(results) == 0 {
+		return 0
+	}
+	s := Summarize(results, 0)
+	return 100 * float64(s.Labeled) / float64(s.Files)
+}
+
+func sameLabelIds(a, b []Label) bool {
+	ids := map[string]int{}
+	for _, label := range a {
+		ids[NormalizeId(label.Id)]++
+	}
+	for _, label := range b {
+		ids[NormalizeId(label.Id)]--
+	}
+	for _, n := range ids {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// shifts lists every ID counted in either scan, largest change first
+func shifts(before, after map[string]int) []LabelShift {
+	ids := map[string]bool{}
+	for id := range before {
+		ids[id] = true
+	}
+	for id := range after {
+		ids[id] = true
+	}
+	list := []LabelShift{}
+	for id := range ids {
+		list = append(list, LabelShift{Id: id, Before: before[id], After: after[id], Change: after[id] - before[id]})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		ci, cj := abs(list[i].Change), abs(list[j].Change)
+		if ci != cj {
+			return ci > cj
+		}
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -2,23 +2,87 @@ package sensitivity_labels
 
 import "encoding/xml"
 
-type FileLabel struct {
-	FilePath  string
-	LabelInfo bool
-	Labels    []Label
+// Result is the outcome of processing a single file, consumed by every
+// output format and sink
+type Result struct {
+	FilePath        string
+	Root            string `json:",omitempty"` // path given on the command line, set when scanning several
+	Parent          string `json:",omitempty"` // email or document a nested document was read from, FilePath is <parent>!/<name>
+	Target          string `json:",omitempty"` // DFS folder target the file was read from when FilePath is a DFS namespace path
+	LabelInfo       bool
+	Labels          []Label
+	Handler         string                 `json:",omitempty"` // format handler used
+	Bytes           int64                  `json:",omitempty"` // size of the file
+	Modified        string                 `json:",omitempty"` // modification time of the file, RFC 3339
+	SetDate         string                 `json:",omitempty"` // when the current label was set, with --timestamps
+	HashBefore      string                 `json:",omitempty"` // sha256 of the file before it was rewritten
+	HashAfter       string                 `json:",omitempty"` // sha256 of the file after it was rewritten
+	DurationMs      int64                  // time spent processing the file
+	Warnings        []string               `json:",omitempty"`
+	ForbiddenLabels []Label                `json:",omitempty"`
+	TenantMismatch  []Label                `json:",omitempty"`
+	Skipped         string                 `json:",omitempty"`
+	Error           string                 `json:",omitempty"`
+	ErrorCategory   string                 `json:",omitempty"`
+	Reason          string                 `json:",omitempty"` // why the file could not be processed, see FailureReason
+	Metadata        *DocumentProperties    `json:",omitempty"` // set with --metadata
+	Invalid         []string               `json:",omitempty"` // LabelInfo.xml problems found with --validate
+	History         []LabelHistoryEntry    `json:",omitempty"` // set with --history
+	Statistics      *DocumentStatistics    `json:",omitempty"` // set with --stats
+	Classification  []ClassificationMarker `json:",omitempty"` // set with --classification
+	Macros          *MacroInfo             `json:",omitempty"` // set with --macros
+	Ownership       *Ownership             `json:",omitempty"` // set with --ownership
+	Signatures      []string               `json:",omitempty"` // digital signature parts, invalidated by relabeling
+	Sanitized       []string               `json:",omitempty"` // parts changed by sanitize
+	Diff            *LabelDiff             `json:",omitempty"` // set on dry-run
 }
 
+// FileLabel is the previous name of Result
+type FileLabel = Result
+
 type Labels struct {
 	XMLName xml.Name `xml:"labelList"`
 	Labels  []Label  `xml:"label"`
 }
 
 type Label struct {
-	XMLName     xml.Name `xml:"label"`
-	Id          string   `xml:"id,attr"`
-	SiteId      string   `xml:"siteId,attr"`
-	Enabled     string   `xml:"enabled,attr"`
-	Method      string   `xml:"method,attr"`
-	ContentBits string   `xml:"contentBits,attr"`
-	Removed     string   `xml:"removed,attr"`
+	XMLName     xml.Name    `xml:"label"`
+	Id          string      `xml:"id,attr"`
+	SiteId      string      `xml:"siteId,attr"`
+	Enabled     string      `xml:"enabled,attr"`
+	Method      string      `xml:"method,attr"`
+	ContentBits ContentBits `xml:"contentBits,attr"`
+	Removed     string      `xml:"removed,attr"`
+	Extra       ExtraAttrs  `xml:",any,attr" json:",omitempty"`
+
+	// names resolved from a config, never written to LabelInfo.xml
+	Name       string `xml:"-" json:",omitempty"`
+	TenantName string `xml:"-" json:",omitempty"`
+}
+
+// ExtraAttr is a label attribute without a dedicated Label field
+type ExtraAttr struct {
+	Name      string
+	Namespace string `json:",omitempty"`
+	Value     string
+}
+
+// ExtraAttrs holds the remaining attributes of a label element keyed by name,
+// namespaced attributes are keyed by namespace and name
+type ExtraAttrs map[string]ExtraAttr
+
+func (e *ExtraAttrs) UnmarshalXMLAttr(attr xml.Attr) error {
+	// namespace declarations are not label attributes
+	if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+		return nil
+	}
+	if *e == nil {
+		*e = ExtraAttrs{}
+	}
+	key := attr.Name.Local
+	if attr.Name.Space != "" {
+		key = attr.Name.Space + ":" + attr.Name.Local
+	}
+	(*e)[key] = ExtraAttr{Name: attr.Name.Local, Namespace: attr.Name.Space, Value: attr.Value}
+	return nil
 }
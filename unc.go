@@ -0,0 +1,40 @@
+package sensitivity_labels
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// IsUNC reports whether path is a \\server\share network path, forward slashes included
+func IsUNC(path string) bool {
+	return len(path) > 2 && isSeparator(path[0]) && isSeparator(path[1]) && !isSeparator(path[2])
+}
+
+func isSeparator(c byte) bool {
+	return c == '/' || c == filepath.Separator
+}
+
+// JoinPath joins a directory and a slash separated path below it with the separator
+// of the platform, unlike filepath.Join dir is kept as given (./docs stays ./docs) so
+// results start with the path on the command line, \\server\share\dir included
+func JoinPath(dir, rel string) string {
+	rel = filepath.FromSlash(rel)
+	if rel == "." || rel == "" {
+		return dir
+	}
+	trimmed := strings.TrimRight(dir, "/"+string(filepath.Separator))
+	if trimmed == "" || strings.HasSuffix(trimmed, ":") {
+		// filesystem roots, / and C:\, and drive relative C: paths
+		return dir + rel
+	}
+	return trimmed + string(filepath.Separator) + rel
+}
+
+// ResolveDFS returns the folder target a DFS namespace path (\\contoso.com\dfs\finance)
+// currently refers to (\\fs01\finance), other paths are returned unchanged
+func ResolveDFS(path string) (string, error) {
+	if !IsUNC(path) {
+		return path, nil
+	}
+	return resolveDFS(filepath.Clean(path))
+}
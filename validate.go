@@ -0,0 +1,127 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+const LabelMetadataNamespace = "http://schemas.microsoft.com/office/2020/mipLabelMetadata"
+
+var guidPattern = regexp.MustCompile(`^\{?[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}?$`)
+
+// ValidateLabelInfo checks a LabelInfo.xml file against the mipLabelMetadata
+// structure and returns every problem found, nil when the file is valid
+func ValidateLabelInfo(filePath string) ([]string, error) {
+	f, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return validateLabelInfo(f), nil
+}
+
+// ValidateLabelInfoXml validates LabelInfo.xml content
+func ValidateLabelInfoXml(data []byte) []string {
+	return validateLabelInfo(bytes.NewReader(data))
+}
+
+// validateLabelInfo decodes LabelInfo.xml within DefaultXMLLimits
+func validateLabelInfo(r io.Reader) []string {
+	var v labelInfoValidator
+	err := DecodeXML(r, LabelInfoPart, &v, DefaultXMLLimits)
+	if err != nil && !v.root && errors.Is(err, io.ErrUnexpectedEOF) {
+		return append(v.problems, "missing labelList element")
+	}
+	if err != nil {
+		return append(v.problems, "malformed xml: "+err.Error())
+	}
+	return v.problems
+}
+
+// labelInfoValidator collects the problems of the LabelInfo.xml element it is decoded from
+type labelInfoValidator struct {
+	root     bool
+	problems []string
+}
+
+func (v *labelInfoValidator) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v.root = true
+	if start.Name.Space != LabelMetadataNamespace || start.Name.Local != "labelList" {
+		v.problems = append(v.problems, fmt.Sprintf("root element is %s, expected labelList in %s", formatName(start.Name), LabelMetadataNamespace))
+	}
+	depth := 1
+	numLabels := 0
+	for depth > 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 2 {
+				break
+			}
+			if t.Name.Space == LabelMetadataNamespace && t.Name.Local == "label" {
+				numLabels++
+				v.problems = append(v.problems, validateLabelAttrs(numLabels, t.Attr)...)
+			} else if t.Name.Space == LabelMetadataNamespace {
+				v.problems = append(v.problems, "unexpected element "+formatName(t.Name))
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+// validateLabelAttrs checks the attributes of the nth label element
+func validateLabelAttrs(n int, attrs []xml.Attr) []string {
+	var problems []string
+	values := map[string]string{}
+	for _, attr := range attrs {
+		if attr.Name.Space == "" {
+			values[attr.Name.Local] = attr.Value
+		}
+	}
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("label %d: ", n)+fmt.Sprintf(format, args...))
+	}
+	for _, name := range []string{"id", "siteId", "enabled", "method"} {
+		if _, ok := values[name]; !ok {
+			problem("missing %s attribute", name)
+		}
+	}
+	for _, name := range []string{"id", "siteId"} {
+		if v, ok := values[name]; ok && !guidPattern.MatchString(v) {
+			problem("%s %q is not a GUID", name, v)
+		}
+	}
+	for _, name := range []string{"enabled", "removed"} {
+		if v, ok := values[name]; ok && v != "0" && v != "1" {
+			problem("%s %q is not 0 or 1", name, v)
+		}
+	}
+	if v, ok := values["method"]; ok && v != "Standard" && v != "Privileged" {
+		problem("method %q is not Standard or Privileged", v)
+	}
+	if v, ok := values["contentBits"]; ok {
+		if _, err := strconv.ParseUint(v, 10, 64); err != nil {
+			problem("contentBits %q is not a number", v)
+		}
+	}
+	return problems
+}
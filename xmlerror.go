@@ -0,0 +1,130 @@
+package sensitivity_labels
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// XMLError is a part that is not well-formed XML or not the expected document,
+// Line and Offset locate where decoding stopped
+type XMLError struct {
+	Part   string
+	Line   int
+	Offset int64
+	Err    error
+}
+
+func (e *XMLError) Error() string {
+	return fmt.Sprintf("%s: line %d, offset %d: %v", e.Part, e.Line, e.Offset, e.Err)
+}
+
+func (e *XMLError) Unwrap() error {
+	return e.Err
+}
+
+func newXMLError(part string, d *xml.Decoder, err error) *XMLError {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	line, _ := d.InputPos()
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line = syntaxErr.Line
+	}
+	return &XMLError{Part: part, Line: line, Offset: d.InputOffset(), Err: err}
+}
+
+// XMLLimits caps a single XML part while it is decoded, protecting the scanner
+// against huge or deeply nested parts embedded in crafted documents, 0 disables a limit
+type XMLLimits struct {
+	MaxBytes int64 // bytes read from the part
+	MaxDepth int   // element nesting
+}
+
+// DefaultXMLLimits are well above any label or document properties part
+var DefaultXMLLimits = XMLLimits{MaxBytes: 8 << 20, MaxDepth: 32}
+
+var ErrXMLLimit = errors.New("xml part exceeds limits")
+
+// DecodeXML streams an XML part from r into v within limits,
+// decoding errors and exceeded limits are returned as *XMLError
+func DecodeXML(r io.Reader, part string, v any, limits XMLLimits) error {
+	if limits.MaxBytes > 0 {
+		r = &xmlLimitReader{r: r, n: limits.MaxBytes}
+	}
+	d := xml.NewDecoder(r)
+	dec := d
+	if limits.MaxDepth > 0 {
+		dec = xml.NewTokenDecoder(&xmlDepthLimiter{d: d, max: limits.MaxDepth})
+	}
+	if err := dec.Decode(v); err != nil {
+		return newXMLError(part, d, err)
+	}
+	return nil
+}
+
+// xmlLimitReader fails with ErrXMLLimit once more than n bytes are read
+type xmlLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *xmlLimitReader) Read(b []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("%w: larger than the byte limit", ErrXMLLimit)
+	}
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("%w: larger than the byte limit", ErrXMLLimit)
+	}
+	return n, err
+}
+
+// xmlDepthLimiter fails with ErrXMLLimit on elements nested deeper than max
+type xmlDepthLimiter struct {
+	d     *xml.Decoder
+	max   int
+	depth int
+}
+
+func (l *xmlDepthLimiter) Token() (xml.Token, error) {
+	tok, err := l.d.Token()
+	switch tok.(type) {
+	case xml.StartElement:
+		l.depth++
+		if l.depth > l.max {
+			return nil, fmt.Errorf("%w: elements nested deeper than %d", ErrXMLLimit, l.max)
+		}
+	case xml.EndElement:
+		l.depth--
+	}
+	return tok, err
+}
+
+// ParseLabelInfo parses LabelInfo.xml content, on malformed XML the labels
+// decoded before the error are returned along with an *XMLError
+func ParseLabelInfo(data []byte) (Labels, error) {
+	var labels Labels
+	err := DecodeXML(bytes.NewReader(data), LabelInfoPart, &labels, DefaultXMLLimits)
+	return labels, err
+}
+
+// ParseLabelInfoFile parses an extracted LabelInfo.xml as ParseLabelInfo
+func ParseLabelInfoFile(filePath string) (Labels, error) {
+	var labels Labels
+	f, err := os.Open(LongPath(filePath))
+	if err != nil {
+		return labels, err
+	}
+	defer f.Close()
+	err = DecodeXML(f, LabelInfoPart, &labels, DefaultXMLLimits)
+	return labels, err
+}